@@ -0,0 +1,311 @@
+// Package chart draws spending charts as PNG images: pie charts of shares
+// and bar or line charts over time. Everything is drawn in process with the
+// standard image packages and a fixed bitmap font, so no chart service or
+// font file is needed.
+package chart
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"sort"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Size of every chart in pixels.
+const (
+	Width  = 800
+	Height = 450
+)
+
+// maxSlices is how many slices a pie shows; smaller values beyond it are
+// merged into one "Other" slice.
+const maxSlices = 8
+
+// ErrNoData is returned when there is nothing above zero to draw.
+var ErrNoData = errors.New("nothing to chart")
+
+// Value is one slice, bar or point of a chart.
+type Value struct {
+	Label  string
+	Amount float64
+}
+
+var (
+	background = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	ink        = color.RGBA{0x33, 0x33, 0x33, 0xFF}
+	gridColor  = color.RGBA{0xE0, 0xE0, 0xE0, 0xFF}
+	// accent is the M-PESA green the bot uses on its embeds.
+	accent = color.RGBA{0x43, 0xB0, 0x2A, 0xFF}
+	// palette colours the slices of a pie, one per slice up to maxSlices.
+	palette = []color.RGBA{
+		accent,
+		{0x1F, 0x77, 0xB4, 0xFF},
+		{0xFF, 0x7F, 0x0E, 0xFF},
+		{0xD6, 0x27, 0x28, 0xFF},
+		{0x94, 0x67, 0xBD, 0xFF},
+		{0x8C, 0x56, 0x4B, 0xFF},
+		{0xE3, 0x77, 0xC2, 0xFF},
+		{0x7F, 0x7F, 0x7F, 0xFF},
+	}
+)
+
+var face = basicfont.Face7x13
+
+// Pie draws each value's share of the total, largest first and clockwise
+// from twelve o'clock, with a legend of amounts and percentages.
+func Pie(title string, values []Value) ([]byte, error) {
+	slices := pieSlices(values)
+	var total float64
+	for _, slice := range slices {
+		total += slice.Amount
+	}
+	if total <= 0 {
+		return nil, ErrNoData
+	}
+
+	img := newCanvas(title)
+	bounds := make([]float64, len(slices))
+	var sum float64
+	for i, slice := range slices {
+		sum += slice.Amount
+		bounds[i] = sum / total
+	}
+
+	const cx, cy, r = 230, 250, 170
+	for y := cy - r; y <= cy+r; y++ {
+		for x := cx - r; x <= cx+r; x++ {
+			dx, dy := float64(x-cx)+0.5, float64(y-cy)+0.5
+			if dx*dx+dy*dy > r*r {
+				continue
+			}
+			angle := math.Atan2(dx, -dy)
+			if angle < 0 {
+				angle += 2 * math.Pi
+			}
+			share := angle / (2 * math.Pi)
+			i := sort.SearchFloat64s(bounds, share)
+			img.SetRGBA(x, y, palette[min(i, len(slices)-1)])
+		}
+	}
+
+	for i, slice := range slices {
+		y := 90 + i*32
+		fill(img, image.Rect(460, y, 476, y+16), palette[i])
+		drawText(img, 486, y+12, fmt.Sprintf("%s  Ksh%.2f (%.0f%%)", slice.Label, slice.Amount, slice.Amount/total*100), ink)
+	}
+	return encode(img)
+}
+
+// pieSlices drops values that are not above zero and sorts the rest largest
+// first, merging those beyond maxSlices into "Other".
+func pieSlices(values []Value) []Value {
+	var slices []Value
+	for _, v := range values {
+		if v.Amount > 0 {
+			slices = append(slices, v)
+		}
+	}
+	sort.SliceStable(slices, func(i, j int) bool { return slices[i].Amount > slices[j].Amount })
+	if len(slices) <= maxSlices {
+		return slices
+	}
+	other := Value{Label: "Other"}
+	for _, v := range slices[maxSlices-1:] {
+		other.Amount += v.Amount
+	}
+	return append(slices[:maxSlices-1], other)
+}
+
+// Bar draws one bar per value, in order.
+func Bar(title string, values []Value) ([]byte, error) {
+	p, err := newPlot(title, values)
+	if err != nil {
+		return nil, err
+	}
+	barWidth := max(1, p.slot*7/10)
+	for i, v := range values {
+		if v.Amount <= 0 {
+			continue
+		}
+		x := p.x(i)
+		fill(p.img, image.Rect(x-barWidth/2, p.y(v.Amount), x-barWidth/2+barWidth, p.bottom), accent)
+	}
+	return encode(p.img)
+}
+
+// Line draws the values as points joined in order.
+func Line(title string, values []Value) ([]byte, error) {
+	p, err := newPlot(title, values)
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i < len(values); i++ {
+		drawLine(p.img, p.x(i-1), p.y(values[i-1].Amount), p.x(i), p.y(values[i].Amount), accent)
+	}
+	for i, v := range values {
+		fillCircle(p.img, p.x(i), p.y(v.Amount), 3, accent)
+	}
+	return encode(p.img)
+}
+
+// plot is the area inside the axes of a bar or line chart, split into one
+// slot per value.
+type plot struct {
+	img                      *image.RGBA
+	left, top, right, bottom int
+	slot                     int
+	// ceiling is the amount at the top of the vertical axis.
+	ceiling float64
+}
+
+// gridLines is how many horizontal lines divide the vertical axis.
+const gridLines = 4
+
+func newPlot(title string, values []Value) (*plot, error) {
+	var highest float64
+	for _, v := range values {
+		highest = math.Max(highest, v.Amount)
+	}
+	if highest <= 0 {
+		return nil, ErrNoData
+	}
+
+	p := &plot{img: newCanvas(title), left: 90, top: 60, right: Width - 30, bottom: Height - 50}
+	p.slot = max(1, (p.right-p.left)/len(values))
+	p.ceiling = niceCeiling(highest)
+
+	for i := 0; i <= gridLines; i++ {
+		amount := p.ceiling * float64(i) / gridLines
+		y := p.y(amount)
+		fill(p.img, image.Rect(p.left, y, p.right, y+1), gridColor)
+		label := axisLabel(amount)
+		drawText(p.img, p.left-10-textWidth(label), y+4, label, ink)
+	}
+	fill(p.img, image.Rect(p.left, p.top, p.left+1, p.bottom+1), ink)
+	fill(p.img, image.Rect(p.left, p.bottom, p.right, p.bottom+1), ink)
+
+	// Label every nth slot so neighbouring labels do not overlap
+	widest := 0
+	for _, v := range values {
+		widest = max(widest, textWidth(v.Label))
+	}
+	every := max(1, (widest+10+p.slot-1)/p.slot)
+	for i, v := range values {
+		if i%every == 0 {
+			drawText(p.img, p.x(i)-textWidth(v.Label)/2, p.bottom+20, v.Label, ink)
+		}
+	}
+	return p, nil
+}
+
+// x is the centre of slot i.
+func (p *plot) x(i int) int {
+	return p.left + p.slot*i + p.slot/2
+}
+
+func (p *plot) y(amount float64) int {
+	return p.bottom - int(amount/p.ceiling*float64(p.bottom-p.top))
+}
+
+// niceCeiling rounds v up to 1, 2, 2.5 or 5 times a power of ten, so the
+// grid lines fall on round amounts.
+func niceCeiling(v float64) float64 {
+	magnitude := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, step := range []float64{1, 2, 2.5, 5, 10} {
+		if step*magnitude >= v {
+			return step * magnitude
+		}
+	}
+	return 10 * magnitude
+}
+
+// axisLabel shortens thousands, e.g. "Ksh12.5k".
+func axisLabel(amount float64) string {
+	if amount >= 1000 {
+		return fmt.Sprintf("Ksh%gk", math.Round(amount/100)/10)
+	}
+	return fmt.Sprintf("Ksh%g", amount)
+}
+
+func newCanvas(title string) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	drawText(img, 20, 30, title, ink)
+	return img
+}
+
+func encode(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode chart: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func fill(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func fillCircle(img *image.RGBA, cx, cy, r int, c color.RGBA) {
+	for y := -r; y <= r; y++ {
+		for x := -r; x <= r; x++ {
+			if x*x+y*y <= r*r {
+				img.SetRGBA(cx+x, cy+y, c)
+			}
+		}
+	}
+}
+
+// drawLine draws a two pixel wide line between the points.
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA) {
+	steps := max(abs(x1-x0), abs(y1-y0), 1)
+	for i := 0; i <= steps; i++ {
+		x := x0 + (x1-x0)*i/steps
+		y := y0 + (y1-y0)*i/steps
+		fill(img, image.Rect(x, y, x+2, y+2), c)
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// drawText writes s with its baseline at y.
+func drawText(img *image.RGBA, x, y int, s string, c color.Color) {
+	d := font.Drawer{Dst: img, Src: image.NewUniform(c), Face: face, Dot: fixed.P(x, y)}
+	d.DrawString(printable(s))
+}
+
+func textWidth(s string) int {
+	return font.MeasureString(face, printable(s)).Round()
+}
+
+// printable replaces the characters the bitmap font lacks: dashes and the
+// "·" separating a title from its period become "-", anything else "?".
+func printable(s string) string {
+	runes := []rune(s)
+	for i, r := range runes {
+		if _, ok := face.GlyphAdvance(r); ok {
+			continue
+		}
+		if unicode.Is(unicode.Pd, r) || r == '·' {
+			runes[i] = '-'
+		} else {
+			runes[i] = '?'
+		}
+	}
+	return string(runes)
+}
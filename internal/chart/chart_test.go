@@ -0,0 +1,97 @@
+package chart
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"testing"
+)
+
+// decoder checks what a chart function returned and decodes the PNG.
+func decoder(t *testing.T) func(data []byte, err error) image.Image {
+	return func(data []byte, err error) image.Image {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("render: %v", err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if size := img.Bounds().Size(); size.X != Width || size.Y != Height {
+			t.Fatalf("expected a %dx%d image, got %v", Width, Height, size)
+		}
+		return img
+	}
+}
+
+func TestPie(t *testing.T) {
+	img := decoder(t)(Pie("Spending", []Value{{"travel", 100}, {"food", 300}}))
+
+	// Largest first, clockwise from twelve o'clock: food covers the right half
+	// and a quarter past it, travel the last quarter
+	if got := img.At(230+100, 250); got != accent {
+		t.Fatalf("expected food on the right, got %v", got)
+	}
+	if got := img.At(230-100, 250-20); got != palette[1] {
+		t.Fatalf("expected travel on the upper left, got %v", got)
+	}
+
+	if _, err := Pie("Spending", []Value{{"food", 0}}); !errors.Is(err, ErrNoData) {
+		t.Fatalf("expected ErrNoData, got %v", err)
+	}
+}
+
+func TestPieSlicesMergeTheSmallestIntoOther(t *testing.T) {
+	var values []Value
+	for i := 1; i <= 10; i++ {
+		values = append(values, Value{Label: string(rune('a' + i - 1)), Amount: float64(i)})
+	}
+	values = append(values, Value{Label: "refund", Amount: -5})
+
+	slices := pieSlices(values)
+	if len(slices) != maxSlices {
+		t.Fatalf("expected %d slices, got %+v", maxSlices, slices)
+	}
+	if slices[0] != (Value{"j", 10}) {
+		t.Fatalf("expected the largest slice first, got %+v", slices[0])
+	}
+	// 1, 2 and 3 were merged
+	if last := slices[len(slices)-1]; last != (Value{"Other", 6}) {
+		t.Fatalf("expected the smallest slices merged, got %+v", last)
+	}
+}
+
+func TestBarAndLine(t *testing.T) {
+	values := []Value{{"Sep 1", 120}, {"Sep 2", 0}, {"Sep 3", 480}}
+
+	img := decoder(t)(Bar("Daily spending", values))
+	p, _ := newPlot("", values)
+	if p.ceiling != 500 {
+		t.Fatalf("expected the axis to end at 500, got %v", p.ceiling)
+	}
+	if got := img.At(p.x(2), p.bottom-10); got != accent {
+		t.Fatalf("expected a bar for Sep 3, got %v", got)
+	}
+	if got := img.At(p.x(1), p.bottom-10); got == accent {
+		t.Fatal("expected no bar for Sep 2")
+	}
+
+	img = decoder(t)(Line("Daily spending", values))
+	if got := img.At(p.x(2), p.y(480)); got != accent {
+		t.Fatalf("expected a point for Sep 3, got %v", got)
+	}
+
+	if _, err := Bar("Daily spending", nil); !errors.Is(err, ErrNoData) {
+		t.Fatalf("expected ErrNoData, got %v", err)
+	}
+}
+
+func TestAxisLabel(t *testing.T) {
+	for amount, want := range map[float64]string{0: "Ksh0", 250: "Ksh250", 1000: "Ksh1k", 12500: "Ksh12.5k"} {
+		if got := axisLabel(amount); got != want {
+			t.Fatalf("axisLabel(%v) = %q, want %q", amount, got, want)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+// Package classify suggests categories for new transactions from the ones
+// already filed. It is a multinomial naive Bayes classifier over recipient
+// words, paybill account, amount bucket and time of day, kept in memory and
+// updated as transactions are saved, corrected or deleted.
+package classify
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/NgigiN/wallet/internal/storage"
+)
+
+// Classifier is safe for concurrent use.
+type Classifier struct {
+	mu sync.RWMutex
+	// learned remembers what each transaction contributed, so a correction
+	// can take the old category back out.
+	learned map[string]example
+	// docs counts training examples per category.
+	docs map[string]int
+	// features counts feature occurrences per category, with totals per
+	// category in featureTotals.
+	features      map[string]map[string]int
+	featureTotals map[string]int
+	vocabulary    map[string]int
+}
+
+type example struct {
+	category string
+	features []string
+}
+
+func New() *Classifier {
+	return &Classifier{
+		learned:       make(map[string]example),
+		docs:          make(map[string]int),
+		features:      make(map[string]map[string]int),
+		featureTotals: make(map[string]int),
+		vocabulary:    make(map[string]int),
+	}
+}
+
+// Reset retrains from scratch on the given transactions.
+func (c *Classifier) Reset(transactions []storage.Transaction) {
+	fresh := New()
+	for _, tx := range transactions {
+		fresh.observe(tx)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.learned, c.docs, c.features, c.featureTotals, c.vocabulary =
+		fresh.learned, fresh.docs, fresh.features, fresh.featureTotals, fresh.vocabulary
+}
+
+// Observe learns the current category of tx, replacing whatever was learned
+// from it before. Pending transactions (empty or "uncategorized") are only
+// forgotten, since they carry no label.
+func (c *Classifier) Observe(tx storage.Transaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.observe(tx)
+}
+
+// Forget removes a transaction from the model, e.g. once it is deleted.
+func (c *Classifier) Forget(transactionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.forget(transactionID)
+}
+
+func (c *Classifier) observe(tx storage.Transaction) {
+	c.forget(tx.TransactionID)
+	if tx.Category == "" || tx.Category == "uncategorized" {
+		return
+	}
+
+	ex := example{category: tx.Category, features: Features(tx)}
+	c.learned[tx.TransactionID] = ex
+	c.docs[ex.category]++
+	if c.features[ex.category] == nil {
+		c.features[ex.category] = make(map[string]int)
+	}
+	for _, f := range ex.features {
+		c.features[ex.category][f]++
+		c.featureTotals[ex.category]++
+		c.vocabulary[f]++
+	}
+}
+
+func (c *Classifier) forget(transactionID string) {
+	ex, ok := c.learned[transactionID]
+	if !ok {
+		return
+	}
+	delete(c.learned, transactionID)
+
+	if c.docs[ex.category]--; c.docs[ex.category] == 0 {
+		delete(c.docs, ex.category)
+	}
+	for _, f := range ex.features {
+		c.features[ex.category][f]--
+		c.featureTotals[ex.category]--
+		if c.vocabulary[f]--; c.vocabulary[f] == 0 {
+			delete(c.vocabulary, f)
+		}
+	}
+	if c.docs[ex.category] == 0 {
+		delete(c.features, ex.category)
+		delete(c.featureTotals, ex.category)
+	}
+}
+
+// Predict returns the most likely category for tx and the model's
+// confidence in it, between 0 and 1. It returns an empty category when no
+// filed transaction shares a recipient word or account with tx, since the
+// amount and time alone say little.
+func (c *Classifier) Predict(tx storage.Transaction) (category string, confidence float64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.docs) == 0 {
+		return "", 0
+	}
+	features := Features(tx)
+	seen := false
+	for _, f := range features {
+		if !strings.HasPrefix(f, "amount:") && !strings.HasPrefix(f, "hour:") && c.vocabulary[f] > 0 {
+			seen = true
+			break
+		}
+	}
+	if !seen {
+		return "", 0
+	}
+
+	total := 0
+	for _, n := range c.docs {
+		total += n
+	}
+	vocabulary := float64(len(c.vocabulary))
+
+	// Log-probabilities with add-one smoothing
+	scores := make(map[string]float64, len(c.docs))
+	best := math.Inf(-1)
+	for class, n := range c.docs {
+		score := math.Log(float64(n) / float64(total))
+		for _, f := range features {
+			score += math.Log(float64(c.features[class][f]+1) / (float64(c.featureTotals[class]) + vocabulary))
+		}
+		scores[class] = score
+		if score > best || (score == best && class < category) {
+			best, category = score, class
+		}
+	}
+
+	// Softmax, shifted by the best score to stay in range
+	var sum float64
+	for _, score := range scores {
+		sum += math.Exp(score - best)
+	}
+	return category, 1 / sum
+}
+
+// Features extracts the tokens the classifier learns from.
+func Features(tx storage.Transaction) []string {
+	var features []string
+	words := strings.FieldsFunc(strings.ToLower(tx.Recipient), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		if len(word) > 1 {
+			features = append(features, "recipient:"+word)
+		}
+	}
+	if tx.Account != "" {
+		features = append(features, "account:"+strings.ToLower(tx.Account))
+	}
+	features = append(features, "amount:"+amountBucket(tx.Amount))
+	if !tx.DateTime.IsZero() {
+		features = append(features, "hour:"+timeOfDay(tx.DateTime.Hour()))
+	}
+	return features
+}
+
+func amountBucket(amount float64) string {
+	switch {
+	case amount < 50:
+		return "<50"
+	case amount < 200:
+		return "<200"
+	case amount < 1000:
+		return "<1000"
+	case amount < 5000:
+		return "<5000"
+	default:
+		return "5000+"
+	}
+}
+
+func timeOfDay(hour int) string {
+	switch {
+	case hour < 5:
+		return "night"
+	case hour < 11:
+		return "morning"
+	case hour < 16:
+		return "afternoon"
+	case hour < 21:
+		return "evening"
+	default:
+		return "night"
+	}
+}
@@ -0,0 +1,70 @@
+package classify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NgigiN/wallet/internal/storage"
+)
+
+func tx(id, recipient string, amount float64, hour int, category string) storage.Transaction {
+	return storage.Transaction{
+		TransactionID: id,
+		Recipient:     recipient,
+		Amount:        amount,
+		DateTime:      time.Date(2025, time.September, 21, hour, 0, 0, 0, time.UTC),
+		Category:      category,
+	}
+}
+
+func TestPredict(t *testing.T) {
+	c := New()
+	c.Reset([]storage.Transaction{
+		tx("T1", "Super Metro Sacco", 100, 7, "travel"),
+		tx("T2", "Super Metro Sacco", 120, 18, "travel"),
+		tx("T3", "Embassava Sacco", 80, 8, "travel"),
+		tx("T4", "Caroline Mwania", 25, 19, "food"),
+		tx("T5", "Caroline Mwania", 40, 19, "food"),
+		tx("T6", "Naivas Supermarket", 1500, 12, "food"),
+		tx("T7", "Pending Person", 10, 12, "uncategorized"),
+	})
+
+	tests := []struct {
+		tx   storage.Transaction
+		want string
+	}{
+		{tx("N1", "SUPER METRO SACCO", 110, 7, ""), "travel"},
+		{tx("N2", "Kibera Sacco", 90, 8, ""), "travel"},
+		{tx("N3", "Caroline Mwania", 30, 19, ""), "food"},
+		{tx("N4", "Someone New", 30, 19, ""), ""},
+		{tx("N5", "Pending Person", 10, 12, ""), ""},
+	}
+	for _, tt := range tests {
+		got, confidence := c.Predict(tt.tx)
+		if got != tt.want {
+			t.Errorf("Predict(%s) = %q (%.2f), want %q", tt.tx.Recipient, got, confidence, tt.want)
+		}
+		if got != "" && (confidence <= 0.5 || confidence > 1) {
+			t.Errorf("Predict(%s) confidence %.2f out of range", tt.tx.Recipient, confidence)
+		}
+	}
+}
+
+func TestObserveCorrectionAndForget(t *testing.T) {
+	c := New()
+	c.Observe(tx("T1", "Co-operative Bank", 1000, 12, "food"))
+	if got, _ := c.Predict(tx("N1", "Co-operative Bank", 1000, 12, "")); got != "food" {
+		t.Fatalf("expected food before the correction, got %q", got)
+	}
+
+	// Correcting the category replaces what was learned from the row
+	c.Observe(tx("T1", "Co-operative Bank", 1000, 12, "savings"))
+	if got, confidence := c.Predict(tx("N1", "Co-operative Bank", 1000, 12, "")); got != "savings" || confidence != 1 {
+		t.Fatalf("expected savings only after the correction, got %q (%.2f)", got, confidence)
+	}
+
+	c.Forget("T1")
+	if got, _ := c.Predict(tx("N1", "Co-operative Bank", 1000, 12, "")); got != "" {
+		t.Fatalf("expected nothing learned after forgetting, got %q", got)
+	}
+}
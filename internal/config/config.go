@@ -3,11 +3,35 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
 )
 
+// Channel binds a Discord channel to a ledger. Channels in different guilds
+// may share a ledger.
+type Channel struct {
+	ID string
+	// Ledger names the set of transactions, categories and rules the
+	// channel works on. Empty means the default ledger.
+	Ledger string
+}
+
 type Config struct {
-	DiscordBotToken  string
+	DiscordBotToken string
+	// DiscordChannelId is a channel on the default ledger, for single-channel
+	// setups.
 	DiscordChannelId string
+	// Channels lists further channels with the ledger each one uses.
+	Channels []Channel
+	// PermissionsFile is a JSON file granting capabilities per channel.
+	// Without one, everyone in a configured channel may do everything.
+	PermissionsFile string
+	// DigestSchedule lists when digests are posted to the configured
+	// channels, e.g. "daily 08:00, weekly mon 08:00"; see package schedule.
+	DigestSchedule string
+	// DatabaseDriver is "sqlite" (default) or "postgres".
+	DatabaseDriver string
+	// DatabaseSource is the SQLite file path or the Postgres DSN.
+	DatabaseSource string
 }
 
 func Load() (*Config, error) {
@@ -16,12 +40,60 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("Bot token is not set")
 	}
 	channelID := os.Getenv("DISCORD_CHANNEL_ID")
-	if channelID == "" {
+	channels, err := parseChannels(os.Getenv("DISCORD_CHANNELS"))
+	if err != nil {
+		return nil, err
+	}
+	if channelID == "" && len(channels) == 0 {
 		return nil, fmt.Errorf("Channel ID is not set")
 	}
 
+	driver := os.Getenv("DATABASE_DRIVER")
+	if driver == "" {
+		driver = "sqlite"
+	}
+	var source string
+	switch driver {
+	case "sqlite":
+		source = os.Getenv("DATABASE_PATH")
+		if source == "" {
+			source = "transaction.db"
+		}
+	case "postgres":
+		source = os.Getenv("DATABASE_URL")
+		if source == "" {
+			return nil, fmt.Errorf("DATABASE_URL is not set for the postgres driver")
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported database driver: %s", driver)
+	}
+
 	return &Config{
 		DiscordBotToken:  botToken,
 		DiscordChannelId: channelID,
+		Channels:         channels,
+		PermissionsFile:  os.Getenv("PERMISSIONS_FILE"),
+		DigestSchedule:   os.Getenv("DIGEST_SCHEDULE"),
+		DatabaseDriver:   driver,
+		DatabaseSource:   source,
 	}, nil
 }
+
+// parseChannels reads DISCORD_CHANNELS, a comma-separated list of
+// ledger:channel_id pairs such as "family:123,business:456".
+func parseChannels(value string) ([]Channel, error) {
+	var channels []Channel
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		ledger, id, ok := strings.Cut(pair, ":")
+		ledger, id = strings.ToLower(strings.TrimSpace(ledger)), strings.TrimSpace(id)
+		if !ok || ledger == "" || id == "" {
+			return nil, fmt.Errorf("Invalid DISCORD_CHANNELS entry %q, use ledger:channel_id", pair)
+		}
+		channels = append(channels, Channel{ID: id, Ledger: ledger})
+	}
+	return channels, nil
+}
@@ -1,49 +1,187 @@
 package discord
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 
+	"github.com/NgigiN/wallet/internal/classify"
 	"github.com/NgigiN/wallet/internal/config"
 	"github.com/NgigiN/wallet/internal/mpesa"
+	"github.com/NgigiN/wallet/internal/permissions"
+	"github.com/NgigiN/wallet/internal/schedule"
 	"github.com/NgigiN/wallet/internal/storage"
 	"github.com/bwmarrin/discordgo"
 )
 
+// maxUndo is how many saves per user !undo can walk back through.
+const maxUndo = 10
+
 type Bot struct {
 	session   *discordgo.Session
-	db        *storage.Database
+	db        storage.Store
 	channelID string
 	startTime time.Time
+	// now is the clock of the bot in M-PESA time; see mpesaNow.
+	now func() time.Time
+
+	// channels maps every channel to the bot serving its ledger. The bots
+	// share the session and this set; the one returned by NewBot receives the
+	// events and hands them on.
+	channels *channelBots
+
+	// permissions is shared by the channel bots; nil allows everything.
+	permissions *permissions.Permissions
+	// router dispatches prefix commands; it is shared by the channel bots.
+	router *Router
+	// digests are posted to configured channels, never to direct messages.
+	digests []schedule.Entry
+	// stop ends the scheduler started by Start.
+	stop chan struct{}
+
+	// classifier suggests categories for pending transactions. It is trained
+	// from the store on startup and kept in step with every change.
+	classifier *classify.Classifier
+
+	// undo holds, per Discord user, the transaction IDs of their most recent
+	// saves (one entry per message). It lives in memory, so a restart clears it.
+	undoMu sync.Mutex
+	undo   map[string][][]string
 }
 
 func NewBot(cfg *config.Config) (*Bot, error) {
+	db, err := storage.Open(cfg.DatabaseDriver, cfg.DatabaseSource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize the database: %w", err)
+	}
+	return NewBotWithStore(cfg, db)
+}
+
+// NewBotWithStore creates a bot backed by the given store instead of the
+// default SQLite database.
+func NewBotWithStore(cfg *config.Config, store storage.Store) (*Bot, error) {
 	session, err := discordgo.New("Bot " + cfg.DiscordBotToken)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Discord session: %w", err)
 	}
-	db, err := storage.NewDatabase("transaction.db")
+
+	channels := cfg.Channels
+	if cfg.DiscordChannelId != "" {
+		channels = append([]config.Channel{{ID: cfg.DiscordChannelId}}, channels...)
+	}
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("no channel is configured")
+	}
+
+	var perms *permissions.Permissions
+	if cfg.PermissionsFile != "" {
+		if perms, err = permissions.Load(cfg.PermissionsFile); err != nil {
+			return nil, err
+		}
+	}
+
+	digests, err := schedule.Parse(cfg.DigestSchedule)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize the database: %w", err)
+		return nil, fmt.Errorf("invalid DIGEST_SCHEDULE: %w", err)
 	}
 
-	bot := &Bot{
-		session:   session,
-		db:        db,
-		channelID: cfg.DiscordChannelId,
-		startTime: time.Now(),
+	var bot *Bot
+	router := newRouter()
+	shared := &channelBots{bots: make(map[string]*Bot)}
+	for _, channel := range channels {
+		if shared.get(channel.ID) != nil {
+			return nil, fmt.Errorf("channel %s is configured twice", channel.ID)
+		}
+		ledger := channel.Ledger
+		if ledger == "" {
+			ledger = storage.DefaultLedger
+		}
+		lb, err := newLedgerBot(session, store, ledger, channel.ID, shared)
+		if err != nil {
+			return nil, err
+		}
+		lb.permissions = perms
+		lb.router = router
+		lb.digests = digests
+		shared.add(lb)
+		if bot == nil {
+			bot = lb
+		}
 	}
 
 	session.AddHandler(bot.handleMessage)
-	session.Identify.Intents = discordgo.IntentGuildMessages
+	session.AddHandler(bot.handleMessageUpdate)
+	session.AddHandler(bot.handleMessageDelete)
+	session.AddHandler(bot.handleInteraction)
+	session.AddHandler(bot.registerSlashCommands)
+	session.Identify.Intents = discordgo.IntentGuildMessages | discordgo.IntentDirectMessages
+
+	return bot, nil
+}
+
+// newLedgerBot creates the bot serving one channel on the given ledger.
+func newLedgerBot(session *discordgo.Session, store storage.Store, ledger, channelID string, channels *channelBots) (*Bot, error) {
+	view, err := store.ForLedger(ledger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ledger %s: %w", ledger, err)
+	}
+	history, err := view.GetAllTransactions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load transaction history of ledger %s: %w", ledger, err)
+	}
 
+	bot := &Bot{
+		session:    session,
+		db:         view,
+		channelID:  channelID,
+		startTime:  time.Now(),
+		now:        mpesaNow,
+		channels:   channels,
+		classifier: classify.New(),
+		undo:       make(map[string][][]string),
+	}
+	bot.classifier.Reset(history)
 	return bot, nil
 }
 
+// channelBots is the set of ledger bots by channel. Configured channels are
+// added on startup, direct-message channels as users first write in.
+type channelBots struct {
+	mu   sync.RWMutex
+	bots map[string]*Bot
+}
+
+func (c *channelBots) get(channelID string) *Bot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.bots[channelID]
+}
+
+func (c *channelBots) add(bot *Bot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bots[bot.channelID] = bot
+}
+
+// guildBots returns the bots of guild channels, leaving out direct messages.
+func (c *channelBots) guildBots() []*Bot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var bots []*Bot
+	for _, bot := range c.bots {
+		if !bot.isDM() {
+			bots = append(bots, bot)
+		}
+	}
+	return bots
+}
+
 func (b *Bot) Start() error {
 	// Start health check server
 	go b.startHealthServer()
@@ -51,10 +189,16 @@ func (b *Bot) Start() error {
 	if err := b.session.Open(); err != nil {
 		return fmt.Errorf("failed to open Discord connection: %w", err)
 	}
+
+	b.stop = make(chan struct{})
+	go b.runScheduler(b.stop)
 	return nil
 }
 
 func (b *Bot) Stop() {
+	if b.stop != nil {
+		close(b.stop)
+	}
 	b.session.Close()
 }
 
@@ -81,40 +225,59 @@ func (b *Bot) handleMessage(s *discordgo.Session, m *discordgo.MessageCreate) {
 		return //bot's messages
 	}
 
-	if m.ChannelID != b.channelID {
-		return //specific to the channel
+	lb := b.botFor(m.ChannelID, m.GuildID, m.Author.ID)
+	if lb == nil {
+		return //specific to the configured channels and direct messages
+	}
+	if lb != b {
+		lb.handleMessage(s, m)
+		return
 	}
 
 	// Clean the content to remove any invisible Unicode characters
 	content := cleanContent(m.Content)
 
-	// Check for summary command
-	if strings.HasPrefix(content, "!summary") {
-		b.handleSummaryCommand(s, m)
-		return
-	}
+	b.router.Dispatch(&Context{
+		Replier: channelReplier{session: s, channelID: m.ChannelID},
+		Bot:     b,
+		Session: s,
+		Message: m,
+		Content: content,
+	})
+}
 
+// handleTransactionMessage logs an M-PESA message, or several in a batch.
+func (b *Bot) handleTransactionMessage(ctx *Context) {
 	// Check for batch processing (multiple transactions)
-	if b.isBatchMessage(content) {
-		b.handleBatchMessage(s, m, content)
+	if b.isBatchMessage(ctx.Content) {
+		b.handleBatchMessage(ctx)
 		return
 	}
 
-	parts := strings.Split(content, "\n")
+	parts := strings.Split(ctx.Content, "\n")
 	if len(parts) < 1 {
-		s.ChannelMessageSend(m.ChannelID, "No message content provided")
+		ctx.Reply("No message content provided")
 		return
 	}
 	parsed, err := mpesa.ParseMPesaMessage(parts[0])
 	if err != nil {
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Invalid Mpesa Message: %v", err))
+		ctx.Reply(fmt.Sprintf("Invalid Mpesa Message: %v", err))
 		return
 	}
 
-	category, reason := parseMetadata(parts[1:])
-	if !isValidCategory(category) {
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Invalid category: %s. \n Use: food, travel, savings, church, investments", category))
-		return
+	category, reason, tags := parseMetadata(parts[1:])
+	var suggestions []string
+	if category != uncategorized {
+		requested := category
+		category, suggestions = b.resolveCategory(requested)
+		if category == "" && len(suggestions) == 0 {
+			ctx.Reply(b.invalidCategoryText(requested))
+			return
+		}
+		if category == "" {
+			// Ambiguous: save it anyway and let the user pick
+			category = uncategorized
+		}
 	}
 
 	tx := storage.Transaction{
@@ -126,18 +289,64 @@ func (b *Bot) handleMessage(s *discordgo.Session, m *discordgo.MessageCreate) {
 		Cost:          parsed.Cost,
 		Category:      category,
 		Reason:        reason,
+		Type:          parsed.Type,
+		Tags:          storage.JoinTags(tags),
+		Account:       parsed.Account,
+		UserID:        ctx.UserID(),
+		MessageID:     ctx.Message.ID,
+	}
+
+	// No category line: let the rules file it
+	var rule *storage.Rule
+	if category == uncategorized && len(suggestions) == 0 {
+		if rule = b.applyRule(&tx); rule != nil {
+			category = tx.Category
+		}
 	}
 
-	if err := b.db.SaveTransaction(&tx); err != nil {
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Failed to save transaction %s: %v", parsed.TransactionID, err))
+	if err := b.db.SaveTransaction(&tx, ctx.Origin("message")); err != nil {
+		ctx.Reply(fmt.Sprintf("Failed to save transaction %s: %v", parsed.TransactionID, err))
 		return
 	}
+	b.classifier.Observe(tx)
 
-	s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Tracked %s: Ksh%.2f to %s in %s", parsed.TransactionID, parsed.Amount, parsed.Recipient, category))
+	b.pushUndo(ctx.UserID(), []string{parsed.TransactionID})
+	if category == uncategorized {
+		b.sendCategoryPicker(ctx, &tx, suggestions)
+		return
+	}
+	response := fmt.Sprintf("Tracked %s: Ksh%.2f to %s in %s", parsed.TransactionID, parsed.Amount, parsed.Recipient, category)
+	if rule != nil {
+		response += fmt.Sprintf(" (rule #%d)", rule.ID)
+	}
+	ctx.Reply(response)
+	b.checkBudgets(ctx, []storage.Transaction{tx})
+}
+
+// metadataFields are the lines accepted after an M-PESA message, by full key
+// and abbreviation.
+var metadataFields = []struct {
+	key, short  string
+	description string
+	example     string
+}{
+	{"category", "c", "the category, an alias or a close spelling", "c: food"},
+	{"reason", "r", "what the money was for", "r: lunch with the team"},
+	{"tags", "t", "comma-separated tags", "t: work, lunch"},
+}
+
+// metadataKey returns the full key for a key or its abbreviation, or "".
+func metadataKey(key string) string {
+	for _, field := range metadataFields {
+		if key == field.key || key == field.short {
+			return field.key
+		}
+	}
+	return ""
 }
 
-func parseMetadata(lines []string) (category, reason string) {
-	category = "uncategorized"
+func parseMetadata(lines []string) (category, reason string, tags []string) {
+	category = uncategorized
 
 	for _, line := range lines {
 		trimmed := strings.TrimSpace(line)
@@ -148,6 +357,7 @@ func parseMetadata(lines []string) (category, reason string) {
 		// Case-insensitive parsing with flexible spacing, supports:
 		// "Category: food", "category: food", "c: food", "c:food"
 		// "Reason: lunch", "reason: lunch", "r: lunch", "r:lunch"
+		// "Tags: work, lunch", "t: work"
 		lower := strings.ToLower(trimmed)
 
 		// Find the first colon to split key:value
@@ -156,116 +366,692 @@ func parseMetadata(lines []string) (category, reason string) {
 			continue
 		}
 
-		key := strings.TrimSpace(lower[:colonIdx])
+		key := metadataKey(strings.TrimSpace(lower[:colonIdx]))
 		value := strings.TrimSpace(trimmed[colonIdx+1:]) // preserve original casing for value
 
 		switch key {
-		case "category", "c":
+		case "category":
 			if value != "" {
 				category = value
 			}
-		case "reason", "r":
+		case "reason":
 			// reason is optional
 			reason = value
+		case "tags":
+			tags = append(tags, strings.Split(value, ",")...)
 		}
 	}
 
-	return category, reason
+	return category, reason, tags
 }
 
-func isValidCategory(category string) bool {
-	validCategories := map[string]bool{
-		"food":        true,
-		"travel":      true,
-		"savings":     true,
-		"church":      true,
-		"investments": true,
+// activeCategories returns the assignable (non-archived) categories in
+// creation order.
+func (b *Bot) activeCategories() []storage.Category {
+	categories, err := b.db.ListCategories(false)
+	if err != nil {
+		log.Printf("failed to list categories: %v", err)
+		return nil
 	}
-	return validCategories[strings.ToLower(category)]
+	return categories
 }
 
-func (b *Bot) handleSummaryCommand(s *discordgo.Session, m *discordgo.MessageCreate) {
-	args := strings.Fields(m.Content)
+func (b *Bot) isValidCategory(category string) bool {
+	c, err := b.db.GetCategory(category)
+	return err == nil && !c.Archived
+}
 
-	if len(args) == 1 {
-		// !summary - show all categories
-		b.handleAllCategoriesSummary(s, m)
-	} else if len(args) == 2 {
-		// !summary <category> - show specific category
-		category := strings.ToLower(args[1])
-		if !isValidCategory(category) {
-			s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Invalid category: %s. Use: food, travel, savings, church, investments", category))
+func (b *Bot) invalidCategoryText(category string) string {
+	var names []string
+	for _, c := range b.activeCategories() {
+		names = append(names, c.Name)
+	}
+	return fmt.Sprintf("Invalid category: %s. Use: %s", category, strings.Join(names, ", "))
+}
+
+// unresolvedCategoryText explains why resolveCategory found no category.
+func (b *Bot) unresolvedCategoryText(category string, suggestions []string) string {
+	if len(suggestions) == 0 {
+		return b.invalidCategoryText(category)
+	}
+	return fmt.Sprintf("Unknown category: %s, %s", category, didYouMean(suggestions))
+}
+
+// categoryWithChildren returns the category and all categories nested under it.
+func (b *Bot) categoryWithChildren(category string) []string {
+	all, err := b.db.ListCategories(true)
+	if err != nil {
+		return []string{category}
+	}
+	if names, ok := storage.CategoryTree(all)[category]; ok {
+		return names
+	}
+	return []string{category}
+}
+
+func (b *Bot) handleSummaryCommand(ctx *Context) {
+	query, err := b.parseSummaryArgs(ctx.Args.Words, ctx.UserID(), ctx.Allowed(permissions.Admin))
+	if err != nil {
+		ctx.Reply(fmt.Sprintf("Invalid summary: %v\n%s", err, ctx.Command.UsageText()))
+		return
+	}
+	if query.category != "" {
+		if _, err := b.db.GetCategory(query.category); err != nil {
+			ctx.Reply(b.invalidCategoryText(query.category))
 			return
 		}
-		b.handleCategorySummary(s, m, category)
-	} else {
-		s.ChannelMessageSend(m.ChannelID, "Usage: !summary [category]\nExamples:\n!summary - show all categories\n!summary food - show food transactions")
 	}
+	b.replySummary(ctx, query)
+}
+
+// summaryQuery is what a summary covers.
+type summaryQuery struct {
+	// category is shown in detail when set.
+	category string
+	scope    scope
+	// period limits the summary and adds a comparison with the previous
+	// one; nil covers all time.
+	period *period
+	// requester and args are kept on the pager buttons to rebuild the query.
+	requester string
+	args      []string
 }
 
-func (b *Bot) handleAllCategoriesSummary(s *discordgo.Session, m *discordgo.MessageCreate) {
-	summary, err := b.db.GetCategorySummary()
+// parseSummaryArgs reads "[category] [period] [scope]" in any order; see
+// parseScope and parsePeriod.
+func (b *Bot) parseSummaryArgs(args []string, requester string, admin bool) (summaryQuery, error) {
+	query := summaryQuery{requester: requester, args: args}
+	sc, rest, err := b.parseScope(args, requester, admin)
 	if err != nil {
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Failed to get summary: %v", err))
+		return query, err
+	}
+	query.scope = sc
+
+	for _, arg := range rest {
+		p, ok, err := parsePeriod(arg, b.now())
+		switch {
+		case err != nil:
+			return query, err
+		case ok && query.period != nil:
+			return query, fmt.Errorf("more than one period")
+		case ok:
+			query.period = &p
+		case query.category != "":
+			return query, fmt.Errorf("unexpected %q", arg)
+		default:
+			query.category = strings.ToLower(arg)
+		}
+	}
+	return query, nil
+}
+
+func (q summaryQuery) filter(filter storage.TransactionFilter) storage.TransactionFilter {
+	filter = q.scope.filter(filter)
+	if q.period != nil {
+		filter = q.period.filter(filter)
+	}
+	return filter
+}
+
+// title names the summary after its scope and period.
+func (q summaryQuery) title(title string) string {
+	if q.period != nil {
+		title += " · " + q.period.label
+	}
+	if q.scope.label != "" {
+		title += fmt.Sprintf(" (%s)", q.scope.label)
+	}
+	return title
+}
+
+// showsBudgets reports whether a summary may show the budgets. Budgets total
+// what the whole ledger spent, so only summaries covering the whole ledger,
+// with "all" or in a private one, show them without revealing what other
+// members spent.
+func (b *Bot) showsBudgets(query summaryQuery) bool {
+	return query.scope.userIDs == nil || b.isDM()
+}
+
+// replySummary answers with the all-categories summary, or the first page of
+// one category when the query has one.
+func (b *Bot) replySummary(r Replier, query summaryQuery) {
+	if query.category == "" {
+		embed, text := b.summaryEmbed(query)
+		if embed == nil {
+			r.Reply(text)
+			return
+		}
+		if b.showsBudgets(query) {
+			addListField(embed, "💰 Budgets", b.budgetLines("", b.now()))
+		}
+		replyEmbed(r, embed, nil)
 		return
 	}
 
-	if len(summary) == 0 {
-		s.ChannelMessageSend(m.ChannelID, "No transactions found.")
+	embed, components, text := b.categorySummaryPage(query, 0)
+	if embed == nil {
+		r.Reply(text)
 		return
 	}
+	replyEmbed(r, embed, components)
+}
+
+// summaryEmbed renders the totals per category, or returns the text to reply
+// instead. Parents show the rolled-up total of their children, which are
+// listed in the same field. With a period, every amount is compared with the
+// previous period, and categories that dropped to nothing are kept.
+func (b *Bot) summaryEmbed(query summaryQuery) (*discordgo.MessageEmbed, string) {
+	summary, err := b.db.SummarizeByCategory(query.filter(storage.TransactionFilter{}))
+	if err != nil {
+		return nil, fmt.Sprintf("Failed to get summary: %v", err)
+	}
+	var previous map[string]float64
+	if query.period != nil {
+		before := summaryQuery{scope: query.scope, period: ptr(query.period.previous())}
+		if previous, err = b.db.SummarizeByCategory(before.filter(storage.TransactionFilter{})); err != nil {
+			return nil, fmt.Sprintf("Failed to get summary: %v", err)
+		}
+	}
+
+	if len(summary) == 0 && len(previous) == 0 {
+		if query.period != nil {
+			return nil, fmt.Sprintf("No transactions found for %s.", query.period.label)
+		}
+		return nil, "No transactions found."
+	}
+
+	// Archived categories are included so their history still adds up
+	categories, err := b.db.ListCategories(true)
+	if err != nil {
+		return nil, fmt.Sprintf("Failed to get categories: %v", err)
+	}
+	tree := storage.CategoryTree(categories)
+	children := make(map[uint][]storage.Category)
+	for _, c := range categories {
+		if c.ParentID != nil {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		}
+	}
+
+	embed := &discordgo.MessageEmbed{Title: query.title("📊 Transaction Summary"), Color: embedColor}
+
+	total := func(totals map[string]float64, c storage.Category) (float64, bool) {
+		var amount float64
+		found := false
+		for _, name := range tree[c.Name] {
+			if a, exists := totals[name]; exists {
+				amount += a
+				found = true
+			}
+		}
+		return amount, found
+	}
+	// amountText renders a category's amount, compared when there is a period
+	amountText := func(c storage.Category) (string, bool) {
+		amount, found := total(summary, c)
+		before, foundBefore := total(previous, c)
+		if !found && !foundBefore {
+			return "", false
+		}
+		if query.period == nil {
+			return fmt.Sprintf("Ksh%.2f", amount), true
+		}
+		return fmt.Sprintf("Ksh%.2f (%s)", amount, changeText(amount, before)), true
+	}
+	var render func(c storage.Category, depth int, lines *[]string)
+	render = func(c storage.Category, depth int, lines *[]string) {
+		for _, child := range children[c.ID] {
+			if text, found := amountText(child); found {
+				*lines = append(*lines, fmt.Sprintf("%s↳ %s: %s", strings.Repeat("  ", depth), strings.Title(child.Name), text))
+				render(child, depth+1, lines)
+			}
+		}
+	}
+
+	var sum, sumBefore float64
+	for _, c := range categories {
+		sum += summary[c.Name]
+		sumBefore += previous[c.Name]
+		if c.ParentID != nil {
+			continue
+		}
+		text, found := amountText(c)
+		if !found {
+			continue
+		}
+		lines := []string{text}
+		render(c, 0, &lines)
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   strings.Title(c.Name),
+			Value:  strings.Join(lines, "\n"),
+			Inline: true,
+		})
+	}
+
+	var notes []string
+	totalText := fmt.Sprintf("Ksh%.2f", sum)
+	if query.period != nil {
+		totalText += fmt.Sprintf(" (%s)", changeText(sum, sumBefore))
+		notes = append(notes, "Compared with "+query.period.previous().label)
+	}
+	embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Total", Value: totalText})
+	if amount, exists := summary[uncategorized]; exists {
+		notes = append(notes, fmt.Sprintf("🏷️ Ksh%.2f is still awaiting a category", amount))
+	}
+	embed.Description = strings.Join(notes, "\n")
+	return embed, ""
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// categorySummaryPage renders one page of the transactions of a category and
+// its children, with the buttons to reach the other pages, or returns the
+// text to reply instead.
+func (b *Bot) categorySummaryPage(query summaryQuery, page int) (*discordgo.MessageEmbed, []discordgo.MessageComponent, string) {
+	category := query.category
+	filter := query.filter(storage.TransactionFilter{Categories: b.categoryWithChildren(category)})
+	count, err := b.db.CountTransactions(filter)
+	if err != nil {
+		return nil, nil, fmt.Sprintf("Failed to get transactions: %v", err)
+	}
+	if count == 0 {
+		if query.period != nil {
+			return nil, nil, fmt.Sprintf("No transactions found for category: %s in %s", category, query.period.label)
+		}
+		return nil, nil, fmt.Sprintf("No transactions found for category: %s", category)
+	}
+	total, err := b.totalOf(filter)
+	if err != nil {
+		return nil, nil, fmt.Sprintf("Failed to get transactions: %v", err)
+	}
+
+	pages := int((count + summaryPageSize - 1) / summaryPageSize)
+	page = max(0, min(page, pages-1))
+	filter.Limit = summaryPageSize
+	filter.Offset = page * summaryPageSize
+	transactions, err := b.db.FindTransactions(filter)
+	if err != nil {
+		return nil, nil, fmt.Sprintf("Failed to get transactions: %v", err)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:  query.title(fmt.Sprintf("📊 %s Transactions", strings.Title(category))),
+		Color:  embedColor,
+		Footer: &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("Page %d of %d", page+1, pages)},
+	}
+	for _, tx := range transactions {
+		value := fmt.Sprintf("%s · %s", tx.DateTime.Format("Jan 2, 2006 3:04 PM"), tx.TransactionID)
+		if tx.Reason != "" {
+			value += "\n" + tx.Reason
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  fmt.Sprintf("Ksh%.2f to %s", tx.Amount, tx.Recipient),
+			Value: value,
+		})
+	}
 
+	totalText := fmt.Sprintf("Ksh%.2f (%d transactions)", total, count)
+	if query.period != nil {
+		before := query.period.previous()
+		previous, err := b.totalOf(before.filter(filter))
+		if err != nil {
+			return nil, nil, fmt.Sprintf("Failed to get transactions: %v", err)
+		}
+		totalText += fmt.Sprintf("\n%s in %s", changeText(total, previous), before.label)
+	}
+	embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+		Name:  "Total " + strings.Title(category),
+		Value: totalText,
+	})
+	if b.showsBudgets(query) {
+		addListField(embed, "💰 Budget", b.budgetLines(category, b.now()))
+	}
+	return embed, pagerComponents(pageState(query.requester, query.args), page, pages), ""
+}
+
+// totalOf sums the amounts of the transactions matching filter.
+func (b *Bot) totalOf(filter storage.TransactionFilter) (float64, error) {
+	filter.Limit, filter.Offset = 0, 0
+	totals, err := b.db.SummarizeByCategory(filter)
+	if err != nil {
+		return 0, err
+	}
 	var total float64
-	response := "📊 **Transaction Summary**\n\n"
+	for _, amount := range totals {
+		total += amount
+	}
+	return total, nil
+}
 
-	categories := []string{"food", "travel", "savings", "church", "investments"}
-	for _, category := range categories {
-		if amount, exists := summary[category]; exists {
-			response += fmt.Sprintf("**%s**: Ksh%.2f\n", strings.Title(category), amount)
-			total += amount
+// editKeyPattern finds metadata keys written inline, e.g. "c: food r: lunch".
+var editKeyPattern = regexp.MustCompile(`(?i)(?:^|\s)(category|c|reason|r|tags|t)\s*:`)
+
+// parseEditArgs turns "c: food r: lunch with team" into an update. Values run
+// until the next key, so reasons may contain spaces.
+func parseEditArgs(args string) storage.TransactionUpdate {
+	var update storage.TransactionUpdate
+	locs := editKeyPattern.FindAllStringSubmatchIndex(args, -1)
+	for i, loc := range locs {
+		end := len(args)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		value := strings.TrimSpace(args[loc[1]:end])
+		switch strings.ToLower(args[loc[2]:loc[3]]) {
+		case "category", "c":
+			category := strings.ToLower(value)
+			update.Category = &category
+		case "reason", "r":
+			reason := value
+			update.Reason = &reason
+		case "tags", "t":
+			tags := storage.JoinTags(strings.Split(value, ","))
+			update.Tags = &tags
 		}
 	}
+	return update
+}
 
-	response += fmt.Sprintf("\n**Total**: Ksh%.2f", total)
-	s.ChannelMessageSend(m.ChannelID, response)
+func (b *Bot) handleEditCommand(ctx *Context) {
+	update := parseEditArgs(ctx.Args.String("changes"))
+	if update.Category == nil && update.Reason == nil && update.Tags == nil {
+		ctx.ReplyUsage()
+		return
+	}
+	transactionID := ctx.Args.String("transaction")
+	if text := b.ownerRefusal(transactionID, false, ctx.UserID(), ctx.Message.Member, permissions.Edit); text != "" {
+		ctx.Reply(text)
+		return
+	}
+	ctx.Reply(b.editText(transactionID, update, ctx.Origin("!edit")))
 }
 
-func (b *Bot) handleCategorySummary(s *discordgo.Session, m *discordgo.MessageCreate, category string) {
-	transactions, err := b.db.GetTransactionsByCategory(category)
+func (b *Bot) editText(transactionID string, update storage.TransactionUpdate, origin storage.Origin) string {
+	if update.Category != nil {
+		category, suggestions := b.resolveCategory(*update.Category)
+		if category == "" {
+			return b.unresolvedCategoryText(*update.Category, suggestions)
+		}
+		update.Category = &category
+	}
+
+	tx, err := b.db.UpdateTransaction(transactionID, update, origin)
 	if err != nil {
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("Failed to get transactions: %v", err))
+		if errors.Is(err, storage.ErrTransactionNotFound) {
+			return fmt.Sprintf("Transaction %s not found", transactionID)
+		}
+		return fmt.Sprintf("Failed to edit transaction %s: %v", transactionID, err)
+	}
+	b.classifier.Observe(*tx)
+
+	response := fmt.Sprintf("✏️ Updated %s: Ksh%.2f to %s in %s", tx.TransactionID, tx.Amount, tx.Recipient, tx.Category)
+	if tx.Reason != "" {
+		response += fmt.Sprintf(" (%s)", tx.Reason)
+	}
+	if update.Category != nil {
+		previous := map[string]string{tx.TransactionID: b.previousCategory(tx.TransactionID)}
+		for _, alert := range b.budgetAlerts([]storage.Transaction{*tx}, previous) {
+			response += "\n" + alert
+		}
+	}
+	return response
+}
+
+func (b *Bot) handleRecategorizeCommand(ctx *Context) {
+	requested := ctx.Args.String("category")
+	category, suggestions := b.resolveCategory(requested)
+	if category == "" {
+		ctx.Reply(b.unresolvedCategoryText(requested, suggestions))
+		return
+	}
+
+	transactionIDs := ctx.Args.List("transactions")
+	var updated, missing, refused, failed []string
+	var moved []storage.Transaction
+	previous := make(map[string]string)
+	for _, transactionID := range transactionIDs {
+		if text := b.ownerRefusal(transactionID, false, ctx.UserID(), ctx.Message.Member, permissions.Edit); text != "" {
+			refused = append(refused, text)
+			continue
+		}
+		tx, err := b.db.UpdateTransaction(transactionID, storage.TransactionUpdate{Category: &category}, ctx.Origin("!recategorize"))
+		switch {
+		case err == nil:
+			b.classifier.Observe(*tx)
+			updated = append(updated, transactionID)
+			moved = append(moved, *tx)
+			previous[tx.TransactionID] = b.previousCategory(tx.TransactionID)
+		case errors.Is(err, storage.ErrTransactionNotFound):
+			missing = append(missing, transactionID)
+		default:
+			failed = append(failed, fmt.Sprintf("%s: %v", transactionID, err))
+		}
+	}
+
+	response := fmt.Sprintf("🏷️ **Recategorized to %s**: %d/%d\n", category, len(updated), len(transactionIDs))
+	if len(updated) > 0 {
+		response += fmt.Sprintf("✅ %s\n", strings.Join(updated, ", "))
+	}
+	if len(missing) > 0 {
+		response += fmt.Sprintf("➖ **Not found**: %s\n", strings.Join(missing, ", "))
+	}
+	for _, r := range refused {
+		response += r + "\n"
+	}
+	for _, f := range failed {
+		response += fmt.Sprintf("❌ %s\n", f)
+	}
+	ctx.Reply(response)
+	for _, alert := range b.budgetAlerts(moved, previous) {
+		ctx.Reply(alert)
+	}
+}
+
+// pushUndo remembers the transactions saved by one message so !undo can revert them.
+func (b *Bot) pushUndo(userID string, transactionIDs []string) {
+	if len(transactionIDs) == 0 {
+		return
+	}
+	b.undoMu.Lock()
+	defer b.undoMu.Unlock()
+
+	stack := append(b.undo[userID], transactionIDs)
+	if len(stack) > maxUndo {
+		stack = stack[len(stack)-maxUndo:]
+	}
+	b.undo[userID] = stack
+}
+
+func (b *Bot) popUndo(userID string) []string {
+	b.undoMu.Lock()
+	defer b.undoMu.Unlock()
+
+	stack := b.undo[userID]
+	if len(stack) == 0 {
+		return nil
+	}
+	last := stack[len(stack)-1]
+	b.undo[userID] = stack[:len(stack)-1]
+	return last
+}
+
+func (b *Bot) handleDeleteCommand(ctx *Context) {
+	transactionID := ctx.Args.String("transaction")
+	if text := b.ownerRefusal(transactionID, false, ctx.UserID(), ctx.Message.Member, permissions.Delete); text != "" {
+		ctx.Reply(text)
+		return
+	}
+	ctx.Reply(b.deleteText(transactionID, ctx.Origin("!delete")))
+}
+
+func (b *Bot) deleteText(transactionID string, origin storage.Origin) string {
+	tx, err := b.db.DeleteTransaction(transactionID, origin)
+	if err != nil {
+		if errors.Is(err, storage.ErrTransactionNotFound) {
+			return fmt.Sprintf("Transaction %s not found", transactionID)
+		}
+		return fmt.Sprintf("Failed to delete transaction %s: %v", transactionID, err)
+	}
+	b.classifier.Forget(transactionID)
+	return fmt.Sprintf("🗑️ Deleted %s: Ksh%.2f to %s. Use !restore %s to bring it back.", tx.TransactionID, tx.Amount, tx.Recipient, tx.TransactionID)
+}
+
+func (b *Bot) handleUndoCommand(ctx *Context) {
+	transactionIDs := b.popUndo(ctx.UserID())
+	if len(transactionIDs) == 0 {
+		ctx.Reply("Nothing to undo.")
+		return
+	}
+
+	var undone, failed []string
+	for _, transactionID := range transactionIDs {
+		if _, err := b.db.DeleteTransaction(transactionID, ctx.Origin("!undo")); err != nil {
+			// Already deleted by hand counts as undone
+			if !errors.Is(err, storage.ErrTransactionNotFound) {
+				failed = append(failed, fmt.Sprintf("%s: %v", transactionID, err))
+			}
+			continue
+		}
+		b.classifier.Forget(transactionID)
+		undone = append(undone, transactionID)
+	}
+
+	response := fmt.Sprintf("↩️ **Undone**: %d transaction(s)", len(undone))
+	if len(undone) > 0 {
+		response += fmt.Sprintf("\n%s", strings.Join(undone, ", "))
+	}
+	for _, f := range failed {
+		response += fmt.Sprintf("\n❌ %s", f)
+	}
+	ctx.Reply(response)
+}
+
+func (b *Bot) handleTrashCommand(ctx *Context) {
+	sc, rest, err := b.parseScope(ctx.Args.Words, ctx.UserID(), ctx.Allowed(permissions.Admin))
+	if err != nil {
+		ctx.Reply(err.Error())
+		return
+	}
+	if len(rest) > 0 {
+		ctx.ReplyUsage()
+		return
+	}
+	transactions, err := b.db.GetDeletedTransactions(sc.filter(storage.TransactionFilter{Limit: 10}))
+	if err != nil {
+		ctx.Reply(fmt.Sprintf("Failed to get deleted transactions: %v", err))
 		return
 	}
 
 	if len(transactions) == 0 {
-		s.ChannelMessageSend(m.ChannelID, fmt.Sprintf("No transactions found for category: %s", category))
+		ctx.Reply("Trash is empty.")
 		return
 	}
 
-	var total float64
-	response := fmt.Sprintf("📊 **%s Transactions**\n\n", strings.Title(category))
+	response := "🗑️ **Deleted Transactions**"
+	if sc.label != "" {
+		response += fmt.Sprintf(" (%s)", sc.label)
+	}
+	response += "\n\n"
+	for _, tx := range transactions {
+		response += fmt.Sprintf("• **%s** Ksh%.2f to %s (%s)\n  deleted %s\n",
+			tx.TransactionID, tx.Amount, tx.Recipient, tx.Category,
+			tx.DeletedAt.Time.Format("Jan 2, 2006 3:04 PM"))
+	}
+	response += "\nUse !restore <TransactionID> to restore one."
+	ctx.Reply(response)
+}
 
-	// Show last 10 transactions
-	limit := 10
-	if len(transactions) < limit {
-		limit = len(transactions)
+func (b *Bot) handleRestoreCommand(ctx *Context) {
+	transactionID := ctx.Args.String("transaction")
+	if text := b.ownerRefusal(transactionID, true, ctx.UserID(), ctx.Message.Member, permissions.Delete); text != "" {
+		ctx.Reply(text)
+		return
+	}
+	tx, err := b.db.RestoreTransaction(transactionID, ctx.Origin("!restore"))
+	if err != nil {
+		if errors.Is(err, storage.ErrTransactionNotFound) {
+			ctx.Reply(fmt.Sprintf("Transaction %s is not in the trash", transactionID))
+			return
+		}
+		ctx.Reply(fmt.Sprintf("Failed to restore transaction %s: %v", transactionID, err))
+		return
 	}
+	b.classifier.Observe(*tx)
 
-	for i := 0; i < limit; i++ {
-		tx := transactions[i]
-		total += tx.Amount
-		response += fmt.Sprintf("• **Ksh%.2f** to %s\n  %s - %s\n\n",
-			tx.Amount, tx.Recipient,
-			tx.DateTime.Format("Jan 2, 2006 3:04 PM"),
-			tx.Reason)
+	ctx.Reply(fmt.Sprintf("♻️ Restored %s: Ksh%.2f to %s in %s", tx.TransactionID, tx.Amount, tx.Recipient, tx.Category))
+	b.checkBudgets(ctx, []storage.Transaction{*tx})
+}
+
+func (b *Bot) handleHistoryCommand(ctx *Context) {
+	transactionID := ctx.Args.String("transaction")
+	visible, err := b.mayViewTransaction(transactionID, ctx.UserID(), ctx.Allowed(permissions.Admin))
+	if err != nil {
+		ctx.Reply(fmt.Sprintf("Failed to get history for %s: %v", transactionID, err))
+		return
+	}
+	if !visible {
+		ctx.Reply(fmt.Sprintf("No history found for %s", transactionID))
+		return
 	}
+	entries, err := b.db.GetAuditEntries(transactionID)
+	if err != nil {
+		ctx.Reply(fmt.Sprintf("Failed to get history for %s: %v", transactionID, err))
+		return
+	}
+
+	if len(entries) == 0 {
+		ctx.Reply(fmt.Sprintf("No history found for %s", transactionID))
+		return
+	}
+
+	response := fmt.Sprintf("📜 **History of %s**\n\n", transactionID)
+	for _, entry := range entries {
+		actor := "<@" + entry.Actor + ">"
+		if entry.Actor == "" {
+			actor = "someone unknown"
+		}
+		response += fmt.Sprintf("• %s **%s** by %s via `%s`\n",
+			entry.CreatedAt.Format("Jan 2, 2006 3:04 PM"), entry.Action, actor, entry.Source)
+		for _, change := range describeChanges(entry) {
+			response += fmt.Sprintf("  %s\n", change)
+		}
+	}
+	ctx.Reply(response)
+}
 
-	if len(transactions) > limit {
-		response += fmt.Sprintf("... and %d more transactions\n\n", len(transactions)-limit)
+// describeChanges lists the user-visible fields that differ between an audit
+// entry's before and after snapshots.
+func describeChanges(entry storage.AuditEntry) []string {
+	before, errBefore := storage.Snapshot(entry.Before)
+	after, errAfter := storage.Snapshot(entry.After)
+	if errBefore != nil || errAfter != nil {
+		return []string{"(snapshot unreadable)"}
 	}
 
-	response += fmt.Sprintf("**Total %s**: Ksh%.2f (%d transactions)", strings.Title(category), total, len(transactions))
-	s.ChannelMessageSend(m.ChannelID, response)
+	if before == nil && after != nil {
+		return []string{fmt.Sprintf("Ksh%.2f to %s in %s", after.Amount, after.Recipient, after.Category)}
+	}
+	if before == nil || after == nil {
+		return nil
+	}
+
+	var changes []string
+	fields := []struct {
+		name     string
+		old, new string
+	}{
+		{"category", before.Category, after.Category},
+		{"reason", before.Reason, after.Reason},
+		{"tags", before.Tags, after.Tags},
+	}
+	for _, f := range fields {
+		if f.old != f.new {
+			changes = append(changes, fmt.Sprintf("%s: %q → %q", f.name, f.old, f.new))
+		}
+	}
+	return changes
 }
 
 func (b *Bot) isBatchMessage(content string) bool {
@@ -275,37 +1061,49 @@ func (b *Bot) isBatchMessage(content string) bool {
 	return len(matches) > 1
 }
 
-func (b *Bot) handleBatchMessage(s *discordgo.Session, m *discordgo.MessageCreate, content string) {
+func (b *Bot) handleBatchMessage(ctx *Context) {
 	// Split into individual transactions scanning entire content, not just lines
-	transactions := b.splitIntoTransactions(content)
+	transactions := b.splitIntoTransactions(ctx.Content)
 
 	if len(transactions) == 0 {
-		s.ChannelMessageSend(m.ChannelID, "No valid M-PESA transactions found in batch message")
+		ctx.Reply("No valid M-PESA transactions found in batch message")
 		return
 	}
 
 	successCount := 0
 	errorCount := 0
 	duplicateCount := 0
-	var errors []string
+	var failures []string
 	var successes []string
 	var duplicates []string
+	var saved []string
+	var filed []storage.Transaction
+	var pending []storage.Transaction
+	var pendingSuggestions [][]string
 
 	for i, txData := range transactions {
 		// Parse the M-PESA message
 		parsed, err := mpesa.ParseMPesaMessage(txData.Message)
 		if err != nil {
 			errorCount++
-			errors = append(errors, fmt.Sprintf("%d [%s]: %v", i+1, extractTxnID(txData.Message), err))
+			failures = append(failures, fmt.Sprintf("%d [%s]: %v", i+1, extractTxnID(txData.Message), err))
 			continue
 		}
 
 		// Parse metadata
-		category, reason := parseMetadata(txData.Metadata)
-		if !isValidCategory(category) {
-			errorCount++
-			errors = append(errors, fmt.Sprintf("%d [%s]: Invalid category '%s'", i+1, parsed.TransactionID, category))
-			continue
+		category, reason, tags := parseMetadata(txData.Metadata)
+		var suggestions []string
+		if category != uncategorized {
+			requested := category
+			category, suggestions = b.resolveCategory(requested)
+			if category == "" && len(suggestions) == 0 {
+				errorCount++
+				failures = append(failures, fmt.Sprintf("%d [%s]: Invalid category '%s'", i+1, parsed.TransactionID, requested))
+				continue
+			}
+			if category == "" {
+				category = uncategorized
+			}
 		}
 
 		// Create transaction record
@@ -318,13 +1116,26 @@ func (b *Bot) handleBatchMessage(s *discordgo.Session, m *discordgo.MessageCreat
 			Cost:          parsed.Cost,
 			Category:      category,
 			Reason:        reason,
+			Type:          parsed.Type,
+			Tags:          storage.JoinTags(tags),
+			Account:       parsed.Account,
+			UserID:        ctx.UserID(),
+			MessageID:     ctx.Message.ID,
+		}
+
+		// No category line: let the rules file it
+		var rule *storage.Rule
+		if category == uncategorized && len(suggestions) == 0 {
+			if rule = b.applyRule(&tx); rule != nil {
+				category = tx.Category
+			}
 		}
 
 		// Save to database with simple retry and duplicate detection
 		var saveErr error
 		for attempt := 1; attempt <= 3; attempt++ {
-			saveErr = b.db.SaveTransaction(&tx)
-			if saveErr == nil {
+			saveErr = b.db.SaveTransaction(&tx, ctx.Origin("batch"))
+			if saveErr == nil || errors.Is(saveErr, storage.ErrDuplicateTransaction) {
 				break
 			}
 			// Backoff a bit
@@ -332,50 +1143,67 @@ func (b *Bot) handleBatchMessage(s *discordgo.Session, m *discordgo.MessageCreat
 		}
 		if saveErr != nil {
 			// If duplicate, count separately and don't treat as hard failure
-			if strings.Contains(strings.ToLower(saveErr.Error()), "unique constraint failed") {
+			if errors.Is(saveErr, storage.ErrDuplicateTransaction) {
 				duplicateCount++
 				duplicates = append(duplicates, fmt.Sprintf("%d [%s] (duplicate)", i+1, parsed.TransactionID))
 				continue
 			}
 			errorCount++
-			errors = append(errors, fmt.Sprintf("%d [%s]: %v", i+1, parsed.TransactionID, saveErr))
+			failures = append(failures, fmt.Sprintf("%d [%s]: %v", i+1, parsed.TransactionID, saveErr))
 			continue
 		}
 
 		successCount++
-		successes = append(successes, fmt.Sprintf("%d [%s]", i+1, parsed.TransactionID))
+		b.classifier.Observe(tx)
+		if rule != nil {
+			successes = append(successes, fmt.Sprintf("%d [%s] → %s (rule #%d)", i+1, parsed.TransactionID, category, rule.ID))
+		} else {
+			successes = append(successes, fmt.Sprintf("%d [%s]", i+1, parsed.TransactionID))
+		}
+		saved = append(saved, parsed.TransactionID)
+		if category == uncategorized {
+			pending = append(pending, tx)
+			pendingSuggestions = append(pendingSuggestions, suggestions)
+		} else {
+			filed = append(filed, tx)
+		}
 	}
+	b.pushUndo(ctx.UserID(), saved)
 
 	// Send summary response
-	response := fmt.Sprintf("📊 **Batch Processing Complete**\n")
-	response += fmt.Sprintf("✅ **Inserted**: %d/%d\n", successCount, len(transactions))
+	embed := &discordgo.MessageEmbed{
+		Title: "📊 Batch Processing Complete",
+		Color: embedColor,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Inserted", Value: fmt.Sprintf("%d/%d", successCount, len(transactions)), Inline: true},
+		},
+	}
 	if duplicateCount > 0 {
-		response += fmt.Sprintf("➖ **Duplicates (skipped)**: %d\n", duplicateCount)
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Duplicates (skipped)", Value: fmt.Sprint(duplicateCount), Inline: true})
 	}
-
-	if len(successes) > 0 {
-		response += "\n**Succeeded:**\n"
-		for _, ok := range successes {
-			response += fmt.Sprintf("• %s\n", ok)
-		}
+	if errorCount > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Failed", Value: fmt.Sprint(errorCount), Inline: true})
 	}
-
-	if len(duplicates) > 0 {
-		response += "\n**Duplicates:**\n"
-		for _, d := range duplicates {
-			response += fmt.Sprintf("• %s\n", d)
-		}
+	if len(pending) > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "Awaiting category", Value: fmt.Sprintf("%d (pick below)", len(pending)), Inline: true})
 	}
 
-	if errorCount > 0 {
-		response += fmt.Sprintf("❌ **Failed**: %d transactions\n", errorCount)
-		response += fmt.Sprintf("**Errors:**\n")
-		for _, err := range errors {
-			response += fmt.Sprintf("• %s\n", err)
+	bullets := func(items []string) []string {
+		lines := make([]string, len(items))
+		for i, item := range items {
+			lines[i] = "• " + item
 		}
+		return lines
+	}
+	addListField(embed, "✅ Succeeded", bullets(successes))
+	addListField(embed, "➖ Duplicates", bullets(duplicates))
+	addListField(embed, "❌ Errors", bullets(failures))
+
+	replyEmbed(ctx, embed, nil)
+	b.checkBudgets(ctx, filed)
+	for i := range pending {
+		b.sendCategoryPicker(ctx, &pending[i], pendingSuggestions[i])
 	}
-
-	s.ChannelMessageSend(m.ChannelID, response)
 }
 
 type TransactionData struct {
@@ -428,7 +1256,8 @@ func (b *Bot) splitIntoTransactions(content string) []TransactionData {
 			}
 			low := strings.ToLower(lt)
 			if strings.HasPrefix(low, "c:") || strings.HasPrefix(low, "category:") ||
-				strings.HasPrefix(low, "r:") || strings.HasPrefix(low, "reason:") {
+				strings.HasPrefix(low, "r:") || strings.HasPrefix(low, "reason:") ||
+				strings.HasPrefix(low, "t:") || strings.HasPrefix(low, "tags:") {
 				meta = append(meta, lt)
 			}
 		}
@@ -0,0 +1,1544 @@
+package discord
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/NgigiN/wallet/internal/config"
+	"github.com/NgigiN/wallet/internal/permissions"
+	"github.com/NgigiN/wallet/internal/schedule"
+	"github.com/NgigiN/wallet/internal/storage"
+	"github.com/bwmarrin/discordgo"
+)
+
+const testChannel = "chan-1"
+
+// recordingTransport captures messages the bot posts instead of calling Discord.
+type recordingTransport struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if strings.HasSuffix(req.URL.Path, "/users/@me/channels") {
+		return rt.respond(req, `{"id": "dm-channel", "type": 1}`), nil
+	}
+	// Direct message channels in tests are named after their user, e.g. dm-111
+	if id, ok := strings.CutPrefix(req.URL.Path, "/api/v9/channels/dm-"); ok && req.Method == http.MethodGet && !strings.Contains(id, "/") {
+		return rt.respond(req, fmt.Sprintf(`{"id": "dm-%s", "type": 1, "recipients": [{"id": %q}]}`, id, id)), nil
+	}
+	// Only replies are recorded, not lookups or command registration
+	if !strings.HasSuffix(req.URL.Path, "/messages") && !strings.HasSuffix(req.URL.Path, "/callback") {
+		return rt.respond(req, `[]`), nil
+	}
+
+	// Channel messages carry content at the top level, interaction responses under data
+	var payload struct {
+		Content string                    `json:"content"`
+		Embeds  []*discordgo.MessageEmbed `json:"embeds"`
+		Data    struct {
+			Content string                    `json:"content"`
+			Embeds  []*discordgo.MessageEmbed `json:"embeds"`
+		} `json:"data"`
+	}
+	// Messages with attachments are multipart, with the JSON in payload_json
+	var body []byte
+	var files []string
+	if mediaType, params, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err == nil && strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(req.Body, params["boundary"])
+		for part, err := reader.NextPart(); err == nil; part, err = reader.NextPart() {
+			if part.FormName() == "payload_json" {
+				body, _ = io.ReadAll(part)
+			} else {
+				files = append(files, part.FileName())
+			}
+		}
+	} else if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+	}
+	json.Unmarshal(body, &payload)
+	content := payload.Content + payload.Data.Content
+	for _, embed := range append(payload.Embeds, payload.Data.Embeds...) {
+		content += embedText(embed)
+	}
+	for _, name := range files {
+		content += "📎 " + name + "\n"
+	}
+	rt.mu.Lock()
+	rt.messages = append(rt.messages, content)
+	rt.mu.Unlock()
+	return rt.respond(req, `{}`), nil
+}
+
+// embedText renders an embed as markdown so tests can match on it like on
+// plain replies: fields become "**Name**: value" lines and the image a
+// markdown image.
+func embedText(embed *discordgo.MessageEmbed) string {
+	text := embed.Title + "\n" + embed.Description + "\n"
+	for _, field := range embed.Fields {
+		text += fmt.Sprintf("**%s**: %s\n", field.Name, field.Value)
+	}
+	if embed.Image != nil {
+		text += fmt.Sprintf("![](%s)\n", embed.Image.URL)
+	}
+	if embed.Footer != nil {
+		text += embed.Footer.Text
+	}
+	return text
+}
+
+func (rt *recordingTransport) respond(req *http.Request, body string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}
+}
+
+func (rt *recordingTransport) last(t *testing.T) string {
+	t.Helper()
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if len(rt.messages) == 0 {
+		t.Fatal("expected the bot to reply")
+	}
+	return rt.messages[len(rt.messages)-1]
+}
+
+func newTestBot(t *testing.T) (*Bot, *storage.MemoryStore, *recordingTransport) {
+	t.Helper()
+	store := storage.NewMemoryStore()
+	bot, err := NewBotWithStore(&config.Config{DiscordBotToken: "test", DiscordChannelId: testChannel}, store)
+	if err != nil {
+		t.Fatalf("failed to create bot: %v", err)
+	}
+	rt := &recordingTransport{}
+	bot.session.Client = &http.Client{Transport: rt}
+	bot.session.State.User = &discordgo.User{ID: "bot"}
+	return bot, store, rt
+}
+
+func send(bot *Bot, content string) {
+	sendAs(bot, "user-1", content)
+}
+
+func sendAs(bot *Bot, userID, content string) {
+	bot.handleMessage(bot.session, &discordgo.MessageCreate{Message: &discordgo.Message{
+		ChannelID: testChannel,
+		Content:   content,
+		Author:    &discordgo.User{ID: userID},
+	}})
+}
+
+const (
+	msgFood   = "TIL4XR5BBM Confirmed. Ksh25.00 sent to Caroline Mwania on 21/9/25 at 7:00 PM. New M-PESA balance is Ksh164.18. Transaction cost, Ksh0.00."
+	msgTravel = "TIL3XTT9WB Confirmed. Ksh40.00 sent to Divinah Nyabuto on 21/9/25 at 7:10 PM. New M-PESA balance is Ksh124.18. Transaction cost, Ksh0.00."
+)
+
+func TestHandleMessageSavesTransaction(t *testing.T) {
+	bot, store, rt := newTestBot(t)
+
+	send(bot, msgFood+"\nc: food\nr: water")
+
+	txs, _ := store.GetAllTransactions()
+	if len(txs) != 1 {
+		t.Fatalf("expected 1 stored transaction, got %d", len(txs))
+	}
+	if txs[0].TransactionID != "TIL4XR5BBM" || txs[0].Category != "food" || txs[0].Reason != "water" {
+		t.Fatalf("unexpected transaction stored: %+v", txs[0])
+	}
+	if reply := rt.last(t); !strings.HasPrefix(reply, "Tracked TIL4XR5BBM") {
+		t.Fatalf("unexpected reply: %q", reply)
+	}
+}
+
+func TestHandleMessageRejectsUnknownCategory(t *testing.T) {
+	bot, store, rt := newTestBot(t)
+
+	send(bot, msgFood+"\nc: gadgets")
+
+	if txs, _ := store.GetAllTransactions(); len(txs) != 0 {
+		t.Fatalf("expected nothing stored, got %d", len(txs))
+	}
+	if reply := rt.last(t); !strings.Contains(reply, "Invalid category") {
+		t.Fatalf("unexpected reply: %q", reply)
+	}
+}
+
+func TestHandleBatchMessageReportsDuplicates(t *testing.T) {
+	bot, store, rt := newTestBot(t)
+
+	send(bot, msgFood+"\nc: food")
+	send(bot, msgFood+"\nc: food\n\n"+msgTravel+"\nc: travel")
+
+	if txs, _ := store.GetAllTransactions(); len(txs) != 2 {
+		t.Fatalf("expected 2 stored transactions, got %d", len(txs))
+	}
+	reply := rt.last(t)
+	if !strings.Contains(reply, "**Inserted**: 1/2") || !strings.Contains(reply, "TIL4XR5BBM] (duplicate)") {
+		t.Fatalf("unexpected batch reply: %q", reply)
+	}
+}
+
+func TestSummaryCommand(t *testing.T) {
+	bot, _, rt := newTestBot(t)
+
+	send(bot, msgFood+"\nc: food\n\n"+msgTravel+"\nc: travel")
+	send(bot, "!summary")
+
+	reply := rt.last(t)
+	for _, want := range []string{"**Food**: Ksh25.00", "**Travel**: Ksh40.00", "**Total**: Ksh65.00"} {
+		if !strings.Contains(reply, want) {
+			t.Fatalf("summary missing %q: %q", want, reply)
+		}
+	}
+}
+
+func TestEditCommand(t *testing.T) {
+	bot, store, rt := newTestBot(t)
+
+	send(bot, msgFood+"\nc: food")
+	send(bot, "!edit TIL4XR5BBM c: travel r: matatu to town")
+
+	txs, _ := store.FindTransactions(storage.TransactionFilter{Category: "travel"})
+	if len(txs) != 1 || txs[0].Reason != "matatu to town" {
+		t.Fatalf("expected edited transaction, got %+v", txs)
+	}
+	if reply := rt.last(t); !strings.HasPrefix(reply, "✏️ Updated TIL4XR5BBM") {
+		t.Fatalf("unexpected reply: %q", reply)
+	}
+
+	send(bot, "!edit NOPE123 c: food")
+	if reply := rt.last(t); reply != "Transaction NOPE123 not found" {
+		t.Fatalf("unexpected reply: %q", reply)
+	}
+}
+
+func TestRecategorizeCommand(t *testing.T) {
+	bot, store, rt := newTestBot(t)
+
+	send(bot, msgFood+"\nc: food\n\n"+msgTravel+"\nc: travel")
+	send(bot, "!recategorize savings TIL4XR5BBM til3xtt9wb MISSING1")
+
+	if count, _ := store.CountTransactions(storage.TransactionFilter{Category: "savings"}); count != 2 {
+		t.Fatalf("expected 2 savings transactions, got %d", count)
+	}
+	reply := rt.last(t)
+	if !strings.Contains(reply, "2/3") || !strings.Contains(reply, "**Not found**: MISSING1") {
+		t.Fatalf("unexpected reply: %q", reply)
+	}
+}
+
+func TestUndoRevertsLastBatch(t *testing.T) {
+	bot, store, rt := newTestBot(t)
+
+	send(bot, msgFood+"\nc: food")
+	send(bot, msgTravel+"\nc: travel\n\nTIL7XUOPX7 Confirmed. Ksh80.00 sent to Meshack Mbindyo on 21/9/25 at 7:13 PM. New M-PESA balance is Ksh44.18. Transaction cost, Ksh0.00.\nc: food")
+	send(bot, "!undo")
+
+	live, _ := store.GetAllTransactions()
+	if len(live) != 1 || live[0].TransactionID != "TIL4XR5BBM" {
+		t.Fatalf("expected only the first save to remain, got %+v", live)
+	}
+	if reply := rt.last(t); !strings.HasPrefix(reply, "↩️ **Undone**: 2 transaction(s)") {
+		t.Fatalf("unexpected reply: %q", reply)
+	}
+
+	send(bot, "!restore TIL3XTT9WB")
+	if count, _ := store.CountTransactions(storage.TransactionFilter{}); count != 2 {
+		t.Fatalf("expected restore to bring back a row, got %d live", count)
+	}
+
+	send(bot, "!undo")
+	send(bot, "!undo")
+	if reply := rt.last(t); reply != "Nothing to undo." {
+		t.Fatalf("unexpected reply: %q", reply)
+	}
+}
+
+func TestHistoryCommand(t *testing.T) {
+	bot, _, rt := newTestBot(t)
+
+	send(bot, msgFood+"\nc: food")
+	send(bot, "!edit TIL4XR5BBM c: travel")
+	send(bot, "!history TIL4XR5BBM")
+
+	reply := rt.last(t)
+	for _, want := range []string{"**create** by <@user-1> via `message`", "**update** by <@user-1> via `!edit`", `category: "food" → "travel"`} {
+		if !strings.Contains(reply, want) {
+			t.Fatalf("history missing %q: %q", want, reply)
+		}
+	}
+}
+
+func TestSearchCommand(t *testing.T) {
+	bot, _, rt := newTestBot(t)
+
+	send(bot, msgFood+"\nc: food\n\n"+msgTravel+"\nc: travel")
+	send(bot, "!search nyabuto from:2025-09-21 to:2025-09-21")
+
+	reply := rt.last(t)
+	if !strings.Contains(reply, "**TIL3XTT9WB** Ksh40.00 to Divinah Nyabuto (travel)") || strings.Contains(reply, "TIL4XR5BBM") {
+		t.Fatalf("unexpected search reply: %q", reply)
+	}
+
+	send(bot, "!search c:gadgets")
+	if reply := rt.last(t); reply != "Invalid search: invalid category: gadgets" {
+		t.Fatalf("unexpected reply: %q", reply)
+	}
+}
+
+func TestSlashSummary(t *testing.T) {
+	bot, _, rt := newTestBot(t)
+
+	send(bot, msgFood+"\nc: food")
+	bot.handleInteraction(bot.session, &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type:      discordgo.InteractionApplicationCommand,
+		ChannelID: testChannel,
+		Member:    &discordgo.Member{User: &discordgo.User{ID: "user-1"}},
+		Data: discordgo.ApplicationCommandInteractionData{
+			Name: "summary",
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{
+				{Name: "category", Type: discordgo.ApplicationCommandOptionString, Value: "food"},
+			},
+		},
+	}})
+
+	if reply := rt.last(t); !strings.Contains(reply, "**Total Food**: Ksh25.00 (1 transactions)") {
+		t.Fatalf("unexpected slash reply: %q", reply)
+	}
+}
+
+func TestSlashCommandsGoThroughTheRouter(t *testing.T) {
+	bot, store, rt := newTestBot(t)
+	slash := func(name string, options ...*discordgo.ApplicationCommandInteractionDataOption) {
+		bot.handleInteraction(bot.session, &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+			Type:      discordgo.InteractionApplicationCommand,
+			ChannelID: testChannel,
+			Member:    &discordgo.Member{User: &discordgo.User{ID: "user-1"}},
+			Data:      discordgo.ApplicationCommandInteractionData{Name: name, Options: options},
+		}})
+	}
+	option := func(name, value string) *discordgo.ApplicationCommandInteractionDataOption {
+		return &discordgo.ApplicationCommandInteractionDataOption{Name: name, Type: discordgo.ApplicationCommandOptionString, Value: value}
+	}
+
+	send(bot, msgFood+"\nc: food")
+	slash("edit", option("transaction_id", "til4xr5bbm"), option("category", "travel"), option("reason", "bus: town and back"))
+	if reply := rt.last(t); !strings.Contains(reply, "Updated TIL4XR5BBM") {
+		t.Fatalf("unexpected slash reply: %q", reply)
+	}
+	txs, _ := store.GetAllTransactions()
+	if len(txs) != 1 || txs[0].Category != "travel" || txs[0].Reason != "bus: town and back" {
+		t.Fatalf("expected the edit to apply, got %+v", txs)
+	}
+	entries, _ := store.GetAuditEntries("TIL4XR5BBM")
+	if last := entries[len(entries)-1]; last.Source != "/edit" {
+		t.Fatalf("expected the slash command in the audit log, got %+v", last)
+	}
+
+	// The rate limit of !export also covers /export
+	for i := 0; i < 3; i++ {
+		slash("export")
+	}
+	slash("export")
+	if reply := rt.last(t); !strings.Contains(reply, "Slow down") {
+		t.Fatalf("expected /export to be rate limited, got %q", reply)
+	}
+}
+
+func TestCategoryPicker(t *testing.T) {
+	bot, store, rt := newTestBot(t)
+
+	send(bot, msgFood+"\nr: water")
+
+	txs, _ := store.FindTransactions(storage.TransactionFilter{Category: uncategorized})
+	if len(txs) != 1 {
+		t.Fatalf("expected transaction saved as pending, got %+v", txs)
+	}
+	if reply := rt.last(t); !strings.Contains(reply, "without a category. Pick one") {
+		t.Fatalf("unexpected reply: %q", reply)
+	}
+
+	bot.handleInteraction(bot.session, &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type:      discordgo.InteractionMessageComponent,
+		ChannelID: testChannel,
+		Member:    &discordgo.Member{User: &discordgo.User{ID: "user-1"}},
+		Data: discordgo.MessageComponentInteractionData{
+			CustomID: pickCategoryID + "TIL4XR5BBM",
+			Values:   []string{"food"},
+		},
+	}})
+
+	txs, _ = store.FindTransactions(storage.TransactionFilter{Category: "food"})
+	if len(txs) != 1 || txs[0].Reason != "water" {
+		t.Fatalf("expected picked category to be saved, got %+v", txs)
+	}
+	if reply := rt.last(t); reply != "Tracked TIL4XR5BBM: Ksh25.00 to Caroline Mwania in food (water)" {
+		t.Fatalf("unexpected reply: %q", reply)
+	}
+}
+
+func TestCategoryPickerIsForItsOwner(t *testing.T) {
+	bot, store, rt := newTestBot(t)
+	perms, err := permissions.Parse([]byte(`{"default": {"everyone": ["log", "view"], "users": {"editor": ["edit"]}}}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	bot.permissions = perms
+	pick := func(userID string) {
+		bot.handleInteraction(bot.session, &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+			Type:      discordgo.InteractionMessageComponent,
+			ChannelID: testChannel,
+			Member:    &discordgo.Member{User: &discordgo.User{ID: userID}},
+			Data: discordgo.MessageComponentInteractionData{
+				CustomID: pickCategoryID + "TIL4XR5BBM:0",
+				Values:   []string{"food"},
+			},
+		}})
+	}
+
+	sendAs(bot, "owner", msgFood)
+	pick("stranger")
+	if reply := rt.last(t); !strings.Contains(reply, "needs the edit permission") {
+		t.Fatalf("expected others to be refused, got %q", reply)
+	}
+	if txs, _ := store.FindTransactions(storage.TransactionFilter{Category: uncategorized}); len(txs) != 1 {
+		t.Fatalf("expected the transaction to stay pending, got %+v", txs)
+	}
+
+	pick("editor")
+	if reply := rt.last(t); !strings.HasPrefix(reply, "Tracked TIL4XR5BBM") {
+		t.Fatalf("expected editors to pick for others, got %q", reply)
+	}
+}
+
+func TestOthersTransactionsNeedPermission(t *testing.T) {
+	bot, store, rt := newTestBot(t)
+	sendAs(bot, "owner", msgFood+"\nc: food")
+
+	// Open channels let everyone log, but not change what others logged
+	for command, want := range map[string]string{
+		"!edit TIL4XR5BBM c: travel":      "needs the edit permission",
+		"!recategorize travel TIL4XR5BBM": "TIL4XR5BBM was logged by <@owner>",
+		"!delete TIL4XR5BBM":              "needs the delete permission",
+		"!edit TIL4XR5BBM r: not mine":    "needs the edit permission",
+	} {
+		sendAs(bot, "stranger", command)
+		if reply := rt.last(t); !strings.Contains(reply, want) {
+			t.Fatalf("%s: expected %q, got %q", command, want, reply)
+		}
+	}
+	if txs, _ := store.FindTransactions(storage.TransactionFilter{Category: "food"}); len(txs) != 1 || txs[0].Reason != "" {
+		t.Fatalf("expected the transaction untouched, got %+v", txs)
+	}
+
+	sendAs(bot, "owner", "!delete TIL4XR5BBM")
+	sendAs(bot, "stranger", "!restore TIL4XR5BBM")
+	if reply := rt.last(t); !strings.Contains(reply, "needs the delete permission") {
+		t.Fatalf("expected restore to be refused, got %q", reply)
+	}
+	sendAs(bot, "owner", "!restore TIL4XR5BBM")
+	if reply := rt.last(t); !strings.HasPrefix(reply, "♻️ Restored TIL4XR5BBM") {
+		t.Fatalf("expected owners to restore, got %q", reply)
+	}
+
+	perms, err := permissions.Parse([]byte(`{"default": {"everyone": ["log", "view"], "users": {"editor": ["edit", "delete"]}}}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	bot.permissions = perms
+	sendAs(bot, "editor", "!edit TIL4XR5BBM c: travel")
+	if reply := rt.last(t); !strings.HasPrefix(reply, "✏️ Updated TIL4XR5BBM") {
+		t.Fatalf("expected editors to edit others, got %q", reply)
+	}
+	sendAs(bot, "editor", "!delete TIL4XR5BBM")
+	if reply := rt.last(t); !strings.HasPrefix(reply, "🗑️ Deleted TIL4XR5BBM") {
+		t.Fatalf("expected editors to delete others, got %q", reply)
+	}
+}
+
+func TestTrashAndHistoryAreScoped(t *testing.T) {
+	bot, _, rt := newTestBot(t)
+	perms, err := permissions.Parse([]byte(`{"default": {"everyone": ["log", "view", "delete"], "users": {"boss": ["admin"]}}}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	bot.permissions = perms
+
+	sendAs(bot, "owner", msgFood+"\nc: food")
+	sendAs(bot, "owner", "!delete TIL4XR5BBM")
+	sendAs(bot, "stranger", msgTravel+"\nc: travel")
+	sendAs(bot, "stranger", "!delete TIL3XTT9WB")
+
+	sendAs(bot, "stranger", "!trash")
+	if reply := rt.last(t); !strings.Contains(reply, "TIL3XTT9WB") || strings.Contains(reply, "TIL4XR5BBM") {
+		t.Fatalf("expected only the caller's trash, got %q", reply)
+	}
+	sendAs(bot, "stranger", "!trash all")
+	if reply := rt.last(t); !strings.Contains(reply, "all needs the admin permission") {
+		t.Fatalf("expected all to need admin, got %q", reply)
+	}
+	sendAs(bot, "boss", "!trash all")
+	if reply := rt.last(t); !strings.Contains(reply, "TIL3XTT9WB") || !strings.Contains(reply, "TIL4XR5BBM") {
+		t.Fatalf("expected admins to see all the trash, got %q", reply)
+	}
+
+	sendAs(bot, "stranger", "!history TIL4XR5BBM")
+	if reply := rt.last(t); reply != "No history found for TIL4XR5BBM" {
+		t.Fatalf("expected others' history hidden, got %q", reply)
+	}
+	for _, userID := range []string{"owner", "boss"} {
+		sendAs(bot, userID, "!history TIL4XR5BBM")
+		if reply := rt.last(t); !strings.Contains(reply, "History of TIL4XR5BBM") {
+			t.Fatalf("%s: expected the history, got %q", userID, reply)
+		}
+	}
+}
+
+func TestCategoryPickerSpreadsCategoriesOverMenus(t *testing.T) {
+	bot, store, rt := newTestBot(t)
+	for i := 0; i < 30; i++ {
+		if _, err := store.CreateCategory(fmt.Sprintf("extra-%02d", i), ""); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+
+	rows := bot.pickerComponents("TIL4XR5BBM", true)
+	if len(rows) != 3 {
+		t.Fatalf("expected two menus and the buttons, got %d rows", len(rows))
+	}
+	listed := make(map[string]bool)
+	for _, row := range rows[:2] {
+		menu := row.(discordgo.ActionsRow).Components[0].(discordgo.SelectMenu)
+		if len(menu.Options) > maxChoices || !strings.HasPrefix(menu.CustomID, pickCategoryID+"TIL4XR5BBM:") {
+			t.Fatalf("unexpected menu %+v", menu)
+		}
+		for _, option := range menu.Options {
+			listed[option.Value] = true
+		}
+	}
+	if len(listed) != len(bot.activeCategories()) {
+		t.Fatalf("expected every category listed once, got %d of %d", len(listed), len(bot.activeCategories()))
+	}
+
+	// Past what the menus hold, the picker points at !edit
+	for i := 30; i < maxPickerMenus*maxChoices; i++ {
+		store.CreateCategory(fmt.Sprintf("extra-%02d", i), "")
+	}
+	send(bot, msgFood)
+	if reply := rt.last(t); !strings.Contains(reply, "Not listed? Use !edit TIL4XR5BBM c: <category>") {
+		t.Fatalf("expected the !edit hint, got %q", reply)
+	}
+}
+
+func TestCategoryCommands(t *testing.T) {
+	bot, store, rt := newTestBot(t)
+
+	send(bot, "!category add groceries food")
+	if reply := rt.last(t); !strings.Contains(reply, "Added category groceries under food") {
+		t.Fatalf("unexpected add reply: %q", reply)
+	}
+	send(bot, "!category add Uncategorized")
+	if reply := rt.last(t); !strings.Contains(reply, "reserved") {
+		t.Fatalf("expected reserved name to be rejected, got %q", reply)
+	}
+
+	send(bot, msgFood+"\nc: food\n\n"+msgTravel+"\nc: groceries")
+	send(bot, "!summary")
+	reply := rt.last(t)
+	for _, want := range []string{"**Food**: Ksh65.00", "↳ Groceries: Ksh40.00", "**Total**: Ksh65.00"} {
+		if !strings.Contains(reply, want) {
+			t.Fatalf("summary missing %q: %q", want, reply)
+		}
+	}
+
+	send(bot, "!category archive groceries")
+	send(bot, "!recategorize groceries TIL4XR5BBM")
+	if reply := rt.last(t); !strings.Contains(reply, "Invalid category: groceries") {
+		t.Fatalf("expected archived category to be rejected, got %q", reply)
+	}
+
+	send(bot, "!category rename food meals")
+	tx, _ := store.FindTransactions(storage.TransactionFilter{Category: "meals"})
+	if len(tx) != 1 || tx[0].TransactionID != "TIL4XR5BBM" {
+		t.Fatalf("expected rename to move transactions, got %+v", tx)
+	}
+}
+
+func TestValidCategoryName(t *testing.T) {
+	for _, name := range []string{"food", "week-end", "monthly", "x2025-09"} {
+		if err := validCategoryName(name); err != nil {
+			t.Errorf("%s: unexpected error %v", name, err)
+		}
+	}
+	// Malformed names, and names commands would read as something else
+	for _, name := range []string{"uncategorized", "all", "today", "week", "month", "year", "2025-09", "Food", "a b"} {
+		if err := validCategoryName(name); err == nil {
+			t.Errorf("%s: expected to be rejected", name)
+		}
+	}
+}
+
+func TestResolveCategory(t *testing.T) {
+	bot, store, _ := newTestBot(t)
+	store.CreateCategory("tea", "")
+	store.CreateCategory("tax", "")
+
+	tests := []struct {
+		input       string
+		category    string
+		suggestions []string
+	}{
+		{"Food", "food", nil},
+		{"Transport", "travel", nil},
+		{"fod", "food", nil},
+		{"trvel", "travel", nil},
+		{"invest", "investments", nil},
+		{"tex", "", []string{"tax", "tea"}},
+		{"xyzzy", "", nil},
+	}
+	for _, tt := range tests {
+		category, suggestions := bot.resolveCategory(tt.input)
+		if category != tt.category || strings.Join(suggestions, ",") != strings.Join(tt.suggestions, ",") {
+			t.Errorf("resolveCategory(%q) = %q, %v; want %q, %v", tt.input, category, suggestions, tt.category, tt.suggestions)
+		}
+	}
+}
+
+func TestAmbiguousCategorySavesPending(t *testing.T) {
+	bot, store, rt := newTestBot(t)
+	store.CreateCategory("tea", "")
+	store.CreateCategory("tax", "")
+
+	send(bot, msgFood+"\nc: tex")
+	if reply := rt.last(t); !strings.Contains(reply, "did you mean tax or tea?") {
+		t.Fatalf("expected suggestions, got %q", reply)
+	}
+	txs, _ := store.FindTransactions(storage.TransactionFilter{Category: "uncategorized"})
+	if len(txs) != 1 {
+		t.Fatalf("expected the transaction to be saved as pending, got %d", len(txs))
+	}
+
+	send(bot, msgTravel+"\nc: fuel")
+	if reply := rt.last(t); !strings.Contains(reply, "in travel") {
+		t.Fatalf("expected alias to resolve to travel, got %q", reply)
+	}
+}
+
+func TestRulesFileUncategorizedMessages(t *testing.T) {
+	bot, store, rt := newTestBot(t)
+
+	send(bot, "!rule add mwania max:100 c:fod r:water")
+	if reply := rt.last(t); !strings.Contains(reply, "Added rule #1") || !strings.Contains(reply, "→ food (water)") {
+		t.Fatalf("unexpected add reply: %q", reply)
+	}
+
+	send(bot, "!rule test "+msgFood)
+	if reply := rt.last(t); !strings.Contains(reply, "Rule #1 would file Ksh25.00 to Caroline Mwania in food (water)") {
+		t.Fatalf("unexpected test reply: %q", reply)
+	}
+
+	send(bot, msgFood)
+	if reply := rt.last(t); !strings.Contains(reply, "in food (rule #1)") {
+		t.Fatalf("expected the rule to file the message, got %q", reply)
+	}
+	txs, _ := store.FindTransactions(storage.TransactionFilter{Category: "food"})
+	if len(txs) != 1 || txs[0].Reason != "water" {
+		t.Fatalf("expected categorized transaction with rule reason, got %+v", txs)
+	}
+
+	// An explicit category line always beats the rules
+	send(bot, msgTravel+"\nc: travel")
+	if reply := rt.last(t); strings.Contains(reply, "rule #") {
+		t.Fatalf("rule applied despite category line: %q", reply)
+	}
+
+	send(bot, "!rule delete 1")
+	send(bot, "!rule list")
+	if reply := rt.last(t); !strings.Contains(reply, "No rules yet") {
+		t.Fatalf("expected no rules, got %q", reply)
+	}
+}
+
+func TestPickerSuggestsLearnedCategory(t *testing.T) {
+	bot, _, rt := newTestBot(t)
+
+	send(bot, msgFood+"\nc: food")
+	send(bot, "TIM1QWE2RT Confirmed. Ksh30.00 sent to Caroline Mwania on 22/9/25 at 7:05 PM. New M-PESA balance is Ksh94.18. Transaction cost, Ksh0.00.")
+	if reply := rt.last(t); !strings.Contains(reply, "Looks like **food**") {
+		t.Fatalf("expected a learned suggestion, got %q", reply)
+	}
+
+	// The suggestion follows corrections
+	send(bot, "!recategorize travel TIL4XR5BBM")
+	next := storage.Transaction{Recipient: "Caroline Mwania", Amount: 30}
+	if category, _ := bot.classifier.Predict(next); category != "travel" {
+		t.Fatalf("expected the correction to be learned, got %q", category)
+	}
+}
+
+func TestLedgersAreScopedToUsers(t *testing.T) {
+	bot, _, rt := newTestBot(t)
+
+	sendAs(bot, "111", msgFood+"\nc: food")
+	sendAs(bot, "222", msgTravel+"\nc: travel")
+
+	sendAs(bot, "111", "!summary")
+	if reply := rt.last(t); !strings.Contains(reply, "**Total**: Ksh25.00") || strings.Contains(reply, "Travel") {
+		t.Fatalf("expected only the caller's ledger, got %q", reply)
+	}
+
+	sendAs(bot, "333", "!summary h:family")
+	if reply := rt.last(t); !strings.Contains(reply, "household family not found") {
+		t.Fatalf("unexpected reply: %q", reply)
+	}
+
+	sendAs(bot, "111", "!household create family")
+	sendAs(bot, "111", "!household add family <@222>")
+	sendAs(bot, "333", "!summary h:family")
+	if reply := rt.last(t); !strings.Contains(reply, "not a member of household family") {
+		t.Fatalf("expected non-members to be refused, got %q", reply)
+	}
+
+	// An invitation shares nothing until it is accepted
+	sendAs(bot, "222", "!summary h:family")
+	if reply := rt.last(t); !strings.Contains(reply, "not a member of household family") {
+		t.Fatalf("expected invited users to be refused, got %q", reply)
+	}
+	sendAs(bot, "333", "!household join family")
+	if reply := rt.last(t); !strings.Contains(reply, "not invited to family") {
+		t.Fatalf("expected uninvited users to be refused, got %q", reply)
+	}
+	sendAs(bot, "222", "!household join family")
+
+	sendAs(bot, "222", "!summary h:family")
+	reply := rt.last(t)
+	for _, want := range []string{"(household family)", "**Food**: Ksh25.00", "**Travel**: Ksh40.00", "**Total**: Ksh65.00"} {
+		if !strings.Contains(reply, want) {
+			t.Fatalf("household summary missing %q: %q", want, reply)
+		}
+	}
+
+	// Only members of the caller's households can be mentioned
+	sendAs(bot, "333", "!search <@222>")
+	if reply := rt.last(t); !strings.Contains(reply, "only view the ledgers of members of your households") {
+		t.Fatalf("expected strangers to be refused, got %q", reply)
+	}
+	sendAs(bot, "111", "!search <@222>")
+	if reply := rt.last(t); !strings.Contains(reply, "TIL3XTT9WB") || strings.Contains(reply, "TIL4XR5BBM") {
+		t.Fatalf("expected search scoped to the mentioned member, got %q", reply)
+	}
+}
+
+func TestUnownedRowsShowInTheDefaultScope(t *testing.T) {
+	bot, store, rt := newTestBot(t)
+
+	// A row from before the audit log, which backfillOwners could not assign
+	legacy := storage.Transaction{TransactionID: "TIL0LEGACY", Amount: 70, Recipient: "KPLC", DateTime: time.Now(), Category: "bills"}
+	if err := store.SaveTransaction(&legacy, storage.Origin{}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	sendAs(bot, "111", msgFood+"\nc: food")
+
+	sendAs(bot, "222", "!search from:2000-01-01")
+	if reply := rt.last(t); !strings.Contains(reply, "TIL0LEGACY") || strings.Contains(reply, "TIL4XR5BBM") {
+		t.Fatalf("expected the unowned row without others' rows, got %q", reply)
+	}
+}
+
+func TestChannelsUseTheirOwnLedgers(t *testing.T) {
+	store := storage.NewMemoryStore()
+	cfg := &config.Config{
+		DiscordBotToken:  "test",
+		DiscordChannelId: testChannel,
+		Channels:         []config.Channel{{ID: "chan-2", Ledger: "business"}},
+	}
+	bot, err := NewBotWithStore(cfg, store)
+	if err != nil {
+		t.Fatalf("failed to create bot: %v", err)
+	}
+	rt := &recordingTransport{}
+	bot.session.Client = &http.Client{Transport: rt}
+	bot.session.State.User = &discordgo.User{ID: "bot"}
+	sendIn := func(channelID, content string) {
+		bot.handleMessage(bot.session, &discordgo.MessageCreate{Message: &discordgo.Message{
+			ChannelID: channelID,
+			GuildID:   "guild-1",
+			Content:   content,
+			Author:    &discordgo.User{ID: "user-1"},
+		}})
+	}
+
+	sendIn("chan-2", "!category add payroll")
+	sendIn("chan-2", msgFood+"\nc: payroll")
+	if reply := rt.last(t); !strings.Contains(reply, "Tracked TIL4XR5BBM") {
+		t.Fatalf("expected the business channel to save, got %q", reply)
+	}
+
+	// The default ledger knows nothing of payroll, and the same message is a
+	// new transaction there
+	sendIn(testChannel, "!category list")
+	if reply := rt.last(t); strings.Contains(reply, "payroll") {
+		t.Fatalf("expected payroll only in the business ledger, got %q", reply)
+	}
+	sendIn(testChannel, msgFood+"\nc: food")
+	if reply := rt.last(t); !strings.Contains(reply, "Tracked TIL4XR5BBM") {
+		t.Fatalf("expected the default channel to save, got %q", reply)
+	}
+
+	sendIn("chan-2", "!summary")
+	if reply := rt.last(t); !strings.Contains(reply, "**Payroll**: Ksh25.00") || strings.Contains(reply, "Food") {
+		t.Fatalf("expected only the business ledger, got %q", reply)
+	}
+
+	count := len(rt.messages)
+	sendIn("chan-3", "!summary")
+	if len(rt.messages) != count {
+		t.Fatal("expected unconfigured channels to be ignored")
+	}
+}
+
+func TestDirectMessagesUsePrivateLedgers(t *testing.T) {
+	bot, store, rt := newTestBot(t)
+	dm := func(channelID, userID, content string) {
+		bot.handleMessage(bot.session, &discordgo.MessageCreate{Message: &discordgo.Message{
+			ChannelID: channelID,
+			Content:   content,
+			Author:    &discordgo.User{ID: userID},
+		}})
+	}
+
+	dm("dm-111", "111", msgFood+"\nc: food")
+	if reply := rt.last(t); !strings.Contains(reply, "Tracked TIL4XR5BBM") {
+		t.Fatalf("expected the DM to be saved, got %q", reply)
+	}
+	if all, _ := store.GetAllTransactions(); len(all) != 0 {
+		t.Fatalf("expected nothing in the shared ledger, got %+v", all)
+	}
+	private, err := store.ForLedger("dm:111")
+	if err != nil {
+		t.Fatalf("for ledger: %v", err)
+	}
+	if all, _ := private.GetAllTransactions(); len(all) != 1 || all[0].UserID != "111" {
+		t.Fatalf("expected the transaction in the private ledger, got %+v", all)
+	}
+
+	sendAs(bot, "111", "!summary all")
+	if reply := rt.last(t); strings.Contains(reply, "Food") {
+		t.Fatalf("private spending leaked into the channel: %q", reply)
+	}
+
+	dm("dm-222", "222", "!summary all")
+	if reply := rt.last(t); strings.Contains(reply, "Food") {
+		t.Fatalf("private spending leaked to another user: %q", reply)
+	}
+	dm("dm-111", "111", "!summary")
+	if reply := rt.last(t); !strings.Contains(reply, "**Food**: Ksh25.00") {
+		t.Fatalf("expected the private summary, got %q", reply)
+	}
+
+	// Deleting a DM after a restart finds the private ledger through the channel
+	bot.handleMessage(bot.session, &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID: "dm-msg", ChannelID: "dm-111", Content: msgTravel + "\nc: travel", Author: &discordgo.User{ID: "111"},
+	}})
+	bot.channels.mu.Lock()
+	delete(bot.channels.bots, "dm-111")
+	bot.channels.mu.Unlock()
+	bot.handleMessageDelete(bot.session, &discordgo.MessageDelete{Message: &discordgo.Message{ID: "dm-msg", ChannelID: "dm-111"}})
+	if reply := rt.last(t); !strings.Contains(reply, "TIL3XTT9WB moved to the trash") {
+		t.Fatalf("expected the deletion to be followed, got %q", reply)
+	}
+	if all, _ := private.GetAllTransactions(); len(all) != 1 || all[0].TransactionID != "TIL4XR5BBM" {
+		t.Fatalf("expected only TIL4XR5BBM to remain, got %+v", all)
+	}
+}
+
+func TestPermissionsAreEnforcedBeforeHandlers(t *testing.T) {
+	bot, store, rt := newTestBot(t)
+	perms, err := permissions.Parse([]byte(`{"channels": {"` + testChannel + `": {
+		"everyone": ["view"],
+		"roles": {"treasurer": ["log", "edit"]},
+		"users": {"owner": ["admin"]}
+	}}}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	bot.permissions = perms
+	sendWithRoles := func(userID string, roles []string, content string) {
+		bot.handleMessage(bot.session, &discordgo.MessageCreate{Message: &discordgo.Message{
+			ChannelID: testChannel,
+			Content:   content,
+			Author:    &discordgo.User{ID: userID},
+			Member:    &discordgo.Member{Roles: roles},
+		}})
+	}
+
+	sendWithRoles("guest", nil, msgFood+"\nc: food")
+	if reply := rt.last(t); !strings.Contains(reply, "need the log permission") {
+		t.Fatalf("expected logging to be refused, got %q", reply)
+	}
+	if all, _ := store.GetAllTransactions(); len(all) != 0 {
+		t.Fatalf("expected nothing saved, got %+v", all)
+	}
+
+	sendWithRoles("guest", nil, "!category list")
+	if reply := rt.last(t); !strings.Contains(reply, "Categories") {
+		t.Fatalf("expected listing to need only view, got %q", reply)
+	}
+	sendWithRoles("guest", nil, "!category add payroll")
+	if reply := rt.last(t); !strings.Contains(reply, "need the admin permission") {
+		t.Fatalf("expected category changes to need admin, got %q", reply)
+	}
+
+	sendWithRoles("member", []string{"treasurer"}, msgFood+"\nc: food")
+	if reply := rt.last(t); !strings.Contains(reply, "Tracked TIL4XR5BBM") {
+		t.Fatalf("expected the treasurer to log, got %q", reply)
+	}
+	sendWithRoles("member", []string{"treasurer"}, "!delete TIL4XR5BBM")
+	if reply := rt.last(t); !strings.Contains(reply, "need the delete permission") {
+		t.Fatalf("expected delete to be refused, got %q", reply)
+	}
+	sendWithRoles("owner", nil, "!delete TIL4XR5BBM")
+	if reply := rt.last(t); strings.Contains(reply, "permission") {
+		t.Fatalf("expected admin to delete, got %q", reply)
+	}
+
+	sendWithRoles("guest", nil, "!summary all")
+	if reply := rt.last(t); !strings.Contains(reply, "all needs the admin permission") {
+		t.Fatalf("expected all to need admin, got %q", reply)
+	}
+	sendWithRoles("owner", nil, "!summary all")
+	if reply := rt.last(t); strings.Contains(reply, "permission") {
+		t.Fatalf("expected admin to view everyone, got %q", reply)
+	}
+
+	bot.handleInteraction(bot.session, &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type:      discordgo.InteractionApplicationCommand,
+		ChannelID: testChannel,
+		Member:    &discordgo.Member{User: &discordgo.User{ID: "guest"}},
+		Data:      discordgo.ApplicationCommandInteractionData{Name: "export"},
+	}})
+	if reply := rt.last(t); !strings.Contains(reply, "need the export permission") {
+		t.Fatalf("expected /export to be refused, got %q", reply)
+	}
+}
+
+func TestEditedAndDeletedMessagesFollowThrough(t *testing.T) {
+	bot, store, rt := newTestBot(t)
+	message := func(id, content string) *discordgo.Message {
+		return &discordgo.Message{ID: id, ChannelID: testChannel, Content: content, Author: &discordgo.User{ID: "user-1"}}
+	}
+
+	// A typo in the category saves nothing; fixing it saves the transaction
+	bot.handleMessage(bot.session, &discordgo.MessageCreate{Message: message("msg-1", msgFood+"\nc: zzzz")})
+	bot.handleMessageUpdate(bot.session, &discordgo.MessageUpdate{Message: message("msg-1", msgFood+"\nc: food")})
+	if reply := rt.last(t); !strings.Contains(reply, "Tracked TIL4XR5BBM") {
+		t.Fatalf("expected the fixed message to save, got %q", reply)
+	}
+
+	bot.handleMessageUpdate(bot.session, &discordgo.MessageUpdate{Message: message("msg-1", msgFood+"\nc: travel\nr: bus")})
+	if reply := rt.last(t); !strings.Contains(reply, "Updated TIL4XR5BBM") || !strings.Contains(reply, "in travel (bus)") {
+		t.Fatalf("expected the edit to update the transaction, got %q", reply)
+	}
+	entries, _ := store.GetAuditEntries("TIL4XR5BBM")
+	if last := entries[len(entries)-1]; last.Source != "message edit" {
+		t.Fatalf("expected the edit in the audit log, got %+v", last)
+	}
+
+	// A close spelling of the stored category is not a change
+	bot.handleMessageUpdate(bot.session, &discordgo.MessageUpdate{Message: message("msg-1", msgFood+"\nc: travl\nr: bus")})
+	if again, _ := store.GetAuditEntries("TIL4XR5BBM"); len(again) != len(entries) {
+		t.Fatalf("expected no change for a close spelling, got %+v", again[len(again)-1])
+	}
+
+	// Cutting a transaction from a batch moves it to the trash
+	bot.handleMessage(bot.session, &discordgo.MessageCreate{Message: message("msg-2", msgTravel+"\nc: travel\n"+strings.Replace(msgFood, "TIL4XR5BBM", "TIL5XXXXXX", 1)+"\nc: food")})
+	bot.handleMessageUpdate(bot.session, &discordgo.MessageUpdate{Message: message("msg-2", msgTravel+"\nc: travel")})
+	if reply := rt.last(t); !strings.Contains(reply, "Deleted TIL5XXXXXX") || strings.Contains(reply, "TIL3XTT9WB") {
+		t.Fatalf("expected only the removed transaction to be deleted, got %q", reply)
+	}
+
+	bot.handleMessageDelete(bot.session, &discordgo.MessageDelete{Message: &discordgo.Message{ID: "msg-2", ChannelID: testChannel}})
+	if reply := rt.last(t); !strings.Contains(reply, "TIL3XTT9WB moved to the trash") {
+		t.Fatalf("expected the deletion to be confirmed, got %q", reply)
+	}
+	if all, _ := store.GetAllTransactions(); len(all) != 1 || all[0].TransactionID != "TIL4XR5BBM" {
+		t.Fatalf("expected only TIL4XR5BBM to remain, got %+v", all)
+	}
+	// Editing a message whose transactions are in the trash points at !restore
+	bot.handleMessageUpdate(bot.session, &discordgo.MessageUpdate{Message: message("msg-2", msgTravel+"\nc: food")})
+	if reply := rt.last(t); !strings.Contains(reply, "TIL3XTT9WB, TIL5XXXXXX from this message are in the trash") {
+		t.Fatalf("expected the trash to be pointed out, got %q", reply)
+	}
+	// A moderator may have deleted it, so the owner is not blamed
+	send(bot, "!history TIL3XTT9WB")
+	if reply := rt.last(t); !strings.Contains(reply, "**delete** by someone unknown via `message delete`") {
+		t.Fatalf("expected an unknown actor, got %q", reply)
+	}
+
+	// Transactions added to a message are saved with it
+	first := strings.Replace(msgTravel, "TIL3XTT9WB", "TIL6XTT9WB", 1) + "\nc: travel"
+	bot.handleMessage(bot.session, &discordgo.MessageCreate{Message: message("msg-3", first)})
+	bot.handleMessageUpdate(bot.session, &discordgo.MessageUpdate{Message: message("msg-3", first+"\n\n"+strings.Replace(msgFood, "TIL4XR5BBM", "TIL7XR5BBM", 1)+"\nc: food")})
+	if reply := rt.last(t); !strings.Contains(reply, "1/1") || !strings.Contains(reply, "TIL7XR5BBM") {
+		t.Fatalf("expected the added transaction to be saved, got %q", reply)
+	}
+	if added, _ := store.FindTransactions(storage.TransactionFilter{MessageID: "msg-3"}); len(added) != 2 {
+		t.Fatalf("expected both transactions linked to the message, got %+v", added)
+	}
+}
+
+func TestRouterParsesTypedArguments(t *testing.T) {
+	command := &Command{
+		Name:  "move",
+		Usage: []string{"<category> <TransactionID> [TransactionID...]"},
+		Params: []Param{
+			{Name: "category", Type: WordParam},
+			{Name: "transactions", Type: TransactionIDParam, Variadic: true},
+		},
+	}
+	args, err := command.parseArgs("  travel til4xr5bbm\nTIL3XTT9WB ")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if args.String("category") != "travel" || !equalStrings(args.List("transactions"), []string{"TIL4XR5BBM", "TIL3XTT9WB"}) {
+		t.Fatalf("unexpected args: %+v", args)
+	}
+	if _, err := command.parseArgs("travel"); err == nil {
+		t.Fatal("expected a missing transaction ID to be rejected")
+	}
+	if _, err := command.parseArgs("travel not-an-id"); err == nil {
+		t.Fatal("expected an invalid transaction ID to be rejected")
+	}
+
+	edit := &Command{Name: "edit", Params: []Param{
+		{Name: "transaction", Type: TransactionIDParam},
+		{Name: "changes", Type: TextParam},
+	}}
+	args, err = edit.parseArgs("TIL4XR5BBM c: travel\nr: matatu to town")
+	if err != nil || args.String("changes") != "c: travel\nr: matatu to town" {
+		t.Fatalf("expected the rest of the message, got %q (%v)", args.String("changes"), err)
+	}
+
+	single := &Command{Name: "delete", Params: []Param{{Name: "transaction", Type: TransactionIDParam}}}
+	if _, err := single.parseArgs("TIL4XR5BBM TIL3XTT9WB"); err == nil {
+		t.Fatal("expected extra arguments to be rejected")
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRouterRunsMiddlewareAndReportsUsage(t *testing.T) {
+	bot, _, rt := newTestBot(t)
+
+	send(bot, "!delete")
+	if reply := rt.last(t); !strings.Contains(reply, "Invalid !delete: missing transaction") || !strings.Contains(reply, "Usage: !delete <TransactionID>") {
+		t.Fatalf("expected the generated usage, got %q", reply)
+	}
+
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx *Context) {
+				order = append(order, name)
+				next(ctx)
+			}
+		}
+	}
+	router := NewRouter()
+	router.Use(trace("router"))
+	router.Register(&Command{
+		Name:       "ping",
+		Middleware: []Middleware{trace("command"), rateLimit(2, time.Hour)},
+		Run:        func(b *Bot, ctx *Context) { ctx.Reply("pong") },
+	})
+	bot.router = router
+
+	for i := 0; i < 3; i++ {
+		send(bot, "!PING")
+	}
+	if reply := rt.last(t); !strings.Contains(reply, "Slow down") {
+		t.Fatalf("expected the third ping to be rate limited, got %q", reply)
+	}
+	if !equalStrings(order[:2], []string{"router", "command"}) || len(order) != 6 {
+		t.Fatalf("unexpected middleware order %v", order)
+	}
+	sendAs(bot, "user-2", "!ping")
+	if reply := rt.last(t); reply != "pong" {
+		t.Fatalf("expected limits per user, got %q", reply)
+	}
+}
+
+func TestHelpIsGeneratedFromCommands(t *testing.T) {
+	bot, _, rt := newTestBot(t)
+
+	send(bot, "!help")
+	reply := rt.last(t)
+	for _, command := range bot.router.Commands() {
+		if !strings.Contains(reply, "`!"+command.Name+"`") {
+			t.Fatalf("help missing !%s: %q", command.Name, reply)
+		}
+	}
+	for _, want := range []string{"`category:` or `c:`", "`tags:` or `t:`", "food"} {
+		if !strings.Contains(reply, want) {
+			t.Fatalf("help missing %q: %q", want, reply)
+		}
+	}
+	if len(reply) > 2000 {
+		t.Fatalf("help is %d characters, over Discord's limit", len(reply))
+	}
+
+	send(bot, "!help !restore")
+	if reply := rt.last(t); !strings.Contains(reply, "Usage: !restore <TransactionID>") || !strings.Contains(reply, "Needs the delete permission") {
+		t.Fatalf("unexpected command help: %q", reply)
+	}
+
+	send(bot, "!budgett")
+	if reply := rt.last(t); !strings.Contains(reply, "Unknown command !budgett") {
+		t.Fatalf("expected unknown commands to point at !help, got %q", reply)
+	}
+}
+
+func TestCategorySummaryPages(t *testing.T) {
+	bot, store, rt := newTestBot(t)
+
+	start := time.Date(2025, 9, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 23; i++ {
+		tx := storage.Transaction{
+			TransactionID: fmt.Sprintf("TIL%07d", i),
+			Amount:        10,
+			Recipient:     "Mama Mboga",
+			DateTime:      start.Add(time.Duration(i) * time.Hour),
+			Category:      "food",
+			UserID:        "user-1",
+		}
+		if err := store.SaveTransaction(&tx, storage.Origin{UserID: "user-1"}); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+
+	send(bot, "!summary food")
+	reply := rt.last(t)
+	if !strings.Contains(reply, "Page 1 of 3") || !strings.Contains(reply, "**Total Food**: Ksh230.00 (23 transactions)") {
+		t.Fatalf("unexpected first page: %q", reply)
+	}
+	if strings.Count(reply, "to Mama Mboga") != summaryPageSize {
+		t.Fatalf("expected %d transactions on the first page: %q", summaryPageSize, reply)
+	}
+
+	turn := func(page int) string {
+		bot.handleInteraction(bot.session, &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+			Type:      discordgo.InteractionMessageComponent,
+			ChannelID: testChannel,
+			Member:    &discordgo.Member{User: &discordgo.User{ID: "user-2"}},
+			Data:      discordgo.MessageComponentInteractionData{CustomID: fmt.Sprintf("%s%d:%s", summaryPageID, page, pageState("user-1", []string{"food"}))},
+		}})
+		return rt.last(t)
+	}
+	if reply := turn(2); !strings.Contains(reply, "Page 3 of 3") || strings.Count(reply, "to Mama Mboga") != 3 {
+		t.Fatalf("unexpected last page: %q", reply)
+	}
+	if reply := turn(7); !strings.Contains(reply, "Page 3 of 3") {
+		t.Fatalf("expected pages past the end to show the last one: %q", reply)
+	}
+
+	if components := pagerComponents(pageState("user-1", []string{"food"}), 0, 3); len(components) != 1 {
+		t.Fatalf("expected a row of pager buttons, got %v", components)
+	}
+	if components := pagerComponents(pageState("user-1", []string{"food"}), 0, 1); components != nil {
+		t.Fatalf("expected no buttons for a single page, got %v", components)
+	}
+}
+
+func TestLongRepliesAreSplit(t *testing.T) {
+	line := strings.Repeat("x", 90)
+	content := strings.TrimSuffix(strings.Repeat(line+"\n", 50), "\n")
+	chunks := splitText(content, maxMessageLength)
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if len(chunk) > maxMessageLength || strings.HasPrefix(chunk, "\n") || len(chunk)%91 != 90 {
+			t.Fatalf("chunk broke a line: %d bytes", len(chunk))
+		}
+	}
+	if chunks := splitText(strings.Repeat("é", 1500), maxMessageLength); !utf8.ValidString(chunks[0]) || len(chunks) != 2 {
+		t.Fatalf("expected a rune-safe split of one long line, got %d chunks", len(chunks))
+	}
+
+	embed := &discordgo.MessageEmbed{Title: "Batch"}
+	var lines []string
+	for i := 0; i < 200; i++ {
+		lines = append(lines, fmt.Sprintf("• %d [TIL%07d] → food (rule #1)", i+1, i))
+	}
+	addListField(embed, "Succeeded", lines)
+	embeds := splitEmbed(embed)
+	for _, part := range embeds {
+		if len(part.Fields) > maxEmbedFields || embedLength(part) > maxEmbedLength {
+			t.Fatalf("embed over Discord's limits: %d fields, %d characters", len(part.Fields), embedLength(part))
+		}
+		for _, field := range part.Fields {
+			if len(field.Value) > maxFieldValueLength {
+				t.Fatalf("field %s is %d characters", field.Name, len(field.Value))
+			}
+		}
+	}
+	if len(embeds) < 2 {
+		t.Fatalf("expected the batch report to span several embeds, got %d", len(embeds))
+	}
+}
+
+func TestSummaryPeriodsCompareWithThePreviousOne(t *testing.T) {
+	bot, store, rt := newTestBot(t)
+	bot.now = func() time.Time { return time.Date(2025, time.October, 10, 9, 0, 0, 0, time.UTC) }
+
+	send(bot, msgFood+"\nc: food\n\n"+msgTravel+"\nc: travel")
+	october := storage.Transaction{
+		TransactionID: "TJA1XR5BBM", Amount: 50, Recipient: "Mama Mboga",
+		DateTime: time.Date(2025, time.October, 2, 8, 0, 0, 0, time.UTC), Category: "food", UserID: "user-1",
+	}
+	if err := store.SaveTransaction(&october, storage.Origin{UserID: "user-1"}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	send(bot, "!summary month")
+	reply := rt.last(t)
+	for _, want := range []string{
+		"Transaction Summary · October 2025",
+		"**Food**: Ksh50.00 (▲ 100% vs Ksh25.00)",
+		"**Travel**: Ksh0.00 (▼ 100% vs Ksh40.00)",
+		"**Total**: Ksh50.00 (▼ 23% vs Ksh65.00)",
+		"Compared with September 2025",
+	} {
+		if !strings.Contains(reply, want) {
+			t.Fatalf("month summary missing %q: %q", want, reply)
+		}
+	}
+
+	send(bot, "!summary 2025-09 food")
+	if reply := rt.last(t); !strings.Contains(reply, "**Total Food**: Ksh25.00 (1 transactions)\nnew in August 2025") {
+		t.Fatalf("unexpected category period: %q", reply)
+	}
+
+	send(bot, "!summary week")
+	if reply := rt.last(t); !strings.Contains(reply, "**Food**: Ksh0.00 (▼ 100% vs Ksh50.00)") || !strings.Contains(reply, "Compared with week of Sep 29, 2025") {
+		t.Fatalf("expected last week's food to be kept, got %q", reply)
+	}
+	send(bot, "!summary today")
+	if reply := rt.last(t); reply != "No transactions found for Fri Oct 10, 2025." {
+		t.Fatalf("expected an empty day, got %q", reply)
+	}
+
+	send(bot, "!summary 2025-09-30..2025-09-01")
+	if reply := rt.last(t); !strings.Contains(reply, "Invalid summary: 2025-09-01 is before 2025-09-30") {
+		t.Fatalf("expected a backwards range to be refused, got %q", reply)
+	}
+
+	p, ok, err := parsePeriod("2025-09-21..2025-09-27", bot.now())
+	if !ok || err != nil {
+		t.Fatalf("parse range: %v %v", ok, err)
+	}
+	if before := p.previous(); before.label != "Sep 14 – Sep 20, 2025" || !before.to.Equal(p.from) {
+		t.Fatalf("expected the week before the range, got %+v", before)
+	}
+}
+
+func TestBudgetsAlertWhenCrossed(t *testing.T) {
+	bot, _, rt := newTestBot(t)
+	bot.now = func() time.Time { return time.Date(2025, time.September, 25, 9, 0, 0, 0, time.UTC) }
+
+	send(bot, "!budget set food 30")
+	if reply := rt.last(t); !strings.Contains(reply, "Food (monthly): Ksh30.00 left of Ksh30.00") {
+		t.Fatalf("unexpected set reply: %q", reply)
+	}
+	send(bot, "!budget set gadgets 30")
+	if reply := rt.last(t); !strings.Contains(reply, "Invalid category") {
+		t.Fatalf("expected an unknown category to be refused, got %q", reply)
+	}
+
+	send(bot, msgFood+"\nc: food")
+	if reply := rt.last(t); reply != "⚠️ Food has used 83% of its monthly budget for September 2025: Ksh5.00 left of Ksh30.00." {
+		t.Fatalf("expected the 80%% alert, got %q", reply)
+	}
+
+	send(bot, msgTravel+"\nc: food")
+	if reply := rt.last(t); !strings.HasPrefix(reply, "🚨 Food is over its monthly budget for September 2025: Ksh65.00 of Ksh30.00") {
+		t.Fatalf("expected the 100%% alert, got %q", reply)
+	}
+
+	send(bot, "!summary all")
+	if reply := rt.last(t); !strings.Contains(reply, "**💰 Budgets**: Food (monthly): Ksh35.00 over Ksh30.00") {
+		t.Fatalf("expected the remaining budget in the summary, got %q", reply)
+	}
+	// Budgets total everyone's spending, which personal summaries keep out
+	sendAs(bot, "user-2", strings.ReplaceAll(msgFood, "TIL4XR5BBM", "TIL9XR5BBM")+"\nc: food")
+	for _, command := range []string{"!summary", "!summary food"} {
+		sendAs(bot, "user-2", command)
+		if reply := rt.last(t); !strings.Contains(reply, "Ksh25.00") || strings.Contains(reply, "Budget") {
+			t.Fatalf("%s: expected no budgets in a personal summary, got %q", command, reply)
+		}
+	}
+
+	// A batch crossing both thresholds at once raises one alert
+	send(bot, "!budget set travel 100 week")
+	rt.mu.Lock()
+	before := len(rt.messages)
+	rt.mu.Unlock()
+	batch := strings.ReplaceAll(msgFood, "TIL4XR5BBM", "TIL5XR5BBM") + "\nc: travel\n\n" + strings.ReplaceAll(msgTravel, "TIL3XTT9WB", "TIL6XTT9WB") + "\nc: travel\n\n" + strings.ReplaceAll(msgTravel, "TIL3XTT9WB", "TIL7XTT9WB") + "\nc: travel"
+	send(bot, batch)
+	rt.mu.Lock()
+	replies := rt.messages[before:]
+	rt.mu.Unlock()
+	if len(replies) != 2 || !strings.HasPrefix(replies[1], "🚨 Travel is over its weekly budget for week of Sep 15, 2025: Ksh105.00") {
+		t.Fatalf("expected the batch report and one alert, got %q", replies)
+	}
+
+	send(bot, "!budget delete travel")
+	if reply := rt.last(t); reply != "Travel has no monthly budget" {
+		t.Fatalf("unexpected delete reply: %q", reply)
+	}
+	send(bot, "!budget delete travel week")
+	send(bot, "!budget list")
+	if reply := rt.last(t); !strings.Contains(reply, "Food (monthly)") || strings.Contains(reply, "Travel") {
+		t.Fatalf("unexpected budget list: %q", reply)
+	}
+}
+
+func TestBudgetsAlertWhenTransactionsMoveIn(t *testing.T) {
+	bot, _, rt := newTestBot(t)
+	bot.now = func() time.Time { return time.Date(2025, time.September, 25, 9, 0, 0, 0, time.UTC) }
+	send(bot, "!budget set food 30")
+	send(bot, msgFood+"\nc: travel")
+
+	send(bot, "!edit TIL4XR5BBM c: food")
+	if reply := rt.last(t); !strings.Contains(reply, "Updated TIL4XR5BBM") || !strings.Contains(reply, "⚠️ Food has used 83%") {
+		t.Fatalf("expected the edit to raise the 80%% alert, got %q", reply)
+	}
+	// Staying in the category adds nothing to it
+	send(bot, "!edit TIL4XR5BBM c: food r: water")
+	if reply := rt.last(t); strings.Contains(reply, "budget") {
+		t.Fatalf("expected no alert for an unchanged category, got %q", reply)
+	}
+
+	send(bot, "!delete TIL4XR5BBM")
+	send(bot, "!restore TIL4XR5BBM")
+	if reply := rt.last(t); !strings.Contains(reply, "⚠️ Food has used 83%") {
+		t.Fatalf("expected the restore to raise the 80%% alert, got %q", reply)
+	}
+
+	send(bot, msgTravel+"\nc: travel")
+	send(bot, "!recategorize food TIL3XTT9WB")
+	if reply := rt.last(t); !strings.HasPrefix(reply, "🚨 Food is over its monthly budget") {
+		t.Fatalf("expected the recategorization to raise the 100%% alert, got %q", reply)
+	}
+}
+
+func TestDigestsArePostedOncePerPeriod(t *testing.T) {
+	bot, store, rt := newTestBot(t)
+	digests, err := schedule.Parse("daily 08:00, weekly mon 08:00")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	bot.digests = digests
+	// Monday after the Sunday both test messages were sent on
+	now := time.Date(2025, time.September, 22, 9, 0, 0, 0, time.UTC)
+	bot.now = func() time.Time { return now }
+
+	send(bot, "!budget set food 100 week")
+	send(bot, msgFood+"\nc: food\n\n"+msgTravel+"\nc: travel")
+	rt.mu.Lock()
+	before := len(rt.messages)
+	rt.mu.Unlock()
+
+	bot.postDueDigests()
+	rt.mu.Lock()
+	posted := append([]string(nil), rt.messages[before:]...)
+	rt.mu.Unlock()
+	if len(posted) != 2 {
+		t.Fatalf("expected a daily and a weekly digest, got %q", posted)
+	}
+	for _, want := range []string{
+		"🗓️ Daily Digest · Sun Sep 21, 2025",
+		"**Food**: Ksh25.00 (new)",
+		"**🔝 Biggest transactions**: Ksh40.00 to Divinah Nyabuto (travel) · Sep 21\nKsh25.00 to Caroline Mwania (food) · Sep 21",
+		"**💸 Transaction costs**: Ksh0.00 over 2 transactions",
+	} {
+		if !strings.Contains(posted[0], want) {
+			t.Fatalf("daily digest missing %q: %q", want, posted[0])
+		}
+	}
+	if !strings.Contains(posted[1], "🗓️ Weekly Digest · week of Sep 15, 2025") {
+		t.Fatalf("unexpected weekly digest: %q", posted[1])
+	}
+	// Budgets stand as they were when the digest's week ended, not in the new one
+	if !strings.Contains(posted[1], "**💰 Budgets**: Food (weekly): Ksh75.00 left of Ksh100.00") {
+		t.Fatalf("expected the budget of the digest's week, got %q", posted[1])
+	}
+
+	// A restart within the same day posts nothing again
+	bot.postDueDigests()
+	rt.mu.Lock()
+	if len(rt.messages) != before+2 {
+		t.Fatalf("expected digests to be posted once, got %q", rt.messages[before:])
+	}
+	rt.mu.Unlock()
+	if last, _ := store.LastScheduledPost("digest:week"); !last.Equal(time.Date(2025, time.September, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected the weekly digest to be recorded, got %v", last)
+	}
+
+	now = now.AddDate(0, 0, 2)
+	bot.postDueDigests()
+	if reply := rt.last(t); reply != "🗓️ Daily Digest · Tue Sep 23, 2025\nNothing was logged." {
+		t.Fatalf("expected the missed day to be caught up, got %q", reply)
+	}
+}
+
+func TestRemindersNudgeQuietUsersAndBalanceGaps(t *testing.T) {
+	bot, _, rt := newTestBot(t)
+	now := time.Date(2025, time.September, 21, 10, 0, 0, 0, time.UTC)
+	bot.now = func() time.Time { return now }
+	posted := func() []string {
+		rt.mu.Lock()
+		defer rt.mu.Unlock()
+		return append([]string(nil), rt.messages...)
+	}
+
+	send(bot, "!remind on 2")
+	if reply := rt.last(t); !strings.Contains(reply, "nudged in this channel when you log nothing for 2 days") {
+		t.Fatalf("unexpected reply: %q", reply)
+	}
+	send(bot, msgFood+"\nc: food\n\n"+msgTravel+"\nc: travel")
+
+	now = time.Date(2025, time.September, 22, 19, 0, 0, 0, time.UTC)
+	before := len(posted())
+	bot.sendDueReminders()
+	if got := posted()[before:]; len(got) != 0 {
+		t.Fatalf("expected no nudge after one quiet day, got %q", got)
+	}
+
+	// Ksh50.00 went out between the travel payment and this one
+	send(bot, "TIM1GAP0AA Confirmed. Ksh10.00 sent to Jane Doe on 22/9/25 at 8:00 AM. New M-PESA balance is Ksh64.18. Transaction cost, Ksh0.00.\nc: food")
+	before = len(posted())
+	bot.sendDueReminders()
+	bot.sendDueReminders()
+	got := posted()[before:]
+	if len(got) != 1 {
+		t.Fatalf("expected one gap nudge, got %q", got)
+	}
+	for _, want := range []string{
+		"👋 <@user-1> Your balance fell by more than your logged spending:",
+		"• Ksh124.18 after TIL3XTT9WB on Sep 21, down to Ksh64.18 at TIM1GAP0AA on Sep 22: Ksh50.00 unaccounted for",
+		"Last known balance: Ksh64.18 on Sep 22 at 8:00 AM.",
+	} {
+		if !strings.Contains(got[0], want) {
+			t.Fatalf("gap nudge missing %q: %q", want, got[0])
+		}
+	}
+
+	// Nudges wait for the evening
+	now = time.Date(2025, time.September, 24, 9, 0, 0, 0, time.UTC)
+	before = len(posted())
+	bot.sendDueReminders()
+	if got := posted()[before:]; len(got) != 0 {
+		t.Fatalf("expected no nudge in the morning, got %q", got)
+	}
+	now = time.Date(2025, time.September, 24, 19, 0, 0, 0, time.UTC)
+	bot.sendDueReminders()
+	bot.sendDueReminders()
+	got = posted()[before:]
+	if len(got) != 1 || !strings.HasPrefix(got[0], "👋 <@user-1> Nothing has been logged for 2 days.") {
+		t.Fatalf("expected one quiet nudge, got %q", got)
+	}
+
+	send(bot, "!remind on dm")
+	now = time.Date(2025, time.September, 26, 19, 0, 0, 0, time.UTC)
+	bot.sendDueReminders()
+	if reply := rt.last(t); !strings.HasPrefix(reply, "👋 Nothing has been logged for 4 days.") {
+		t.Fatalf("expected a repeated nudge by direct message, got %q", reply)
+	}
+
+	send(bot, "!remind off")
+	if reply := rt.last(t); reply != "🔕 Reminders are off." {
+		t.Fatalf("unexpected reply: %q", reply)
+	}
+	now = now.AddDate(0, 0, 4)
+	before = len(posted())
+	bot.sendDueReminders()
+	if got := posted()[before:]; len(got) != 0 {
+		t.Fatalf("expected no nudges once off, got %q", got)
+	}
+}
+
+func TestChartsAreUploadedAsImages(t *testing.T) {
+	bot, _, rt := newTestBot(t)
+	bot.now = func() time.Time { return time.Date(2025, time.September, 22, 9, 0, 0, 0, time.UTC) }
+	send(bot, "!category add lunch food")
+	send(bot, msgFood+"\nc: lunch\n\n"+msgTravel+"\nc: travel")
+
+	send(bot, "!chart pie")
+	reply := rt.last(t)
+	for _, want := range []string{"📈 Spending by category · September 2025", "![](attachment://chart.png)", "📎 chart.png"} {
+		if !strings.Contains(reply, want) {
+			t.Fatalf("chart missing %q: %q", want, reply)
+		}
+	}
+
+	query, err := bot.parseSummaryArgs(nil, "user-1", false)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	query.period = ptr(calendarPeriod(bot.now(), storage.Month))
+	_, split, err := bot.categorySplit(query)
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	amounts := make(map[string]float64)
+	for _, v := range split {
+		amounts[v.Label] = v.Amount
+	}
+	if amounts["Food"] != 25 || amounts["Travel"] != 40 {
+		t.Fatalf("expected lunch rolled into food, got %+v", split)
+	}
+
+	title, days, err := bot.spendingOverTime(query)
+	if err != nil {
+		t.Fatalf("over time: %v", err)
+	}
+	if title != "Daily spending · September 2025" || len(days) != 30 || days[20].Label != "Sep 21" || days[20].Amount != 65 || days[19].Amount != 0 {
+		t.Fatalf("unexpected daily spending %q: %+v", title, days)
+	}
+
+	send(bot, "!chart line year")
+	if reply := rt.last(t); !strings.Contains(reply, "📈 Monthly spending · 2025") {
+		t.Fatalf("expected a monthly chart for the year, got %q", reply)
+	}
+	send(bot, "!chart bar food 2025-08")
+	if reply := rt.last(t); reply != "No spending to chart for August 2025." {
+		t.Fatalf("unexpected reply: %q", reply)
+	}
+	send(bot, "!chart radar")
+	if reply := rt.last(t); !strings.Contains(reply, "!chart <pie|bar|line>") {
+		t.Fatalf("expected the usage, got %q", reply)
+	}
+}
@@ -0,0 +1,250 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NgigiN/wallet/internal/storage"
+)
+
+// budgetThresholds are the shares of a budget that trigger an alert when a
+// saved transaction crosses them, highest first.
+var budgetThresholds = []float64{1, 0.8}
+
+// budgetPeriods maps the period words of !budget to their granularity.
+var budgetPeriods = map[string]storage.Granularity{
+	"week":  storage.Week,
+	"month": storage.Month,
+	"year":  storage.Year,
+}
+
+func (b *Bot) handleBudgetCommand(ctx *Context) {
+	if len(ctx.Args.Words) == 0 {
+		ctx.ReplyUsage()
+		return
+	}
+	args := ctx.Args.Words[1:]
+
+	switch strings.ToLower(ctx.Args.Words[0]) {
+	case "list":
+		ctx.Reply(b.budgetListText())
+	case "set":
+		if len(args) < 2 || len(args) > 3 {
+			ctx.ReplyUsage()
+			return
+		}
+		ctx.Reply(b.setBudgetText(args, ctx.UserID()))
+	case "delete":
+		if len(args) < 1 || len(args) > 2 {
+			ctx.ReplyUsage()
+			return
+		}
+		ctx.Reply(b.deleteBudgetText(args))
+	default:
+		ctx.ReplyUsage()
+	}
+}
+
+// parseBudgetPeriod reads the optional period of a budget, month by default.
+func parseBudgetPeriod(args []string) (storage.Granularity, error) {
+	if len(args) == 0 {
+		return storage.Month, nil
+	}
+	period, ok := budgetPeriods[strings.ToLower(args[0])]
+	if !ok {
+		return "", fmt.Errorf("invalid period %q, use week, month or year", args[0])
+	}
+	return period, nil
+}
+
+// setBudgetText handles "<category> <amount> [period]".
+func (b *Bot) setBudgetText(args []string, userID string) string {
+	category, suggestions := b.resolveCategory(args[0])
+	if category == "" {
+		return b.unresolvedCategoryText(args[0], suggestions)
+	}
+	amount, err := strconv.ParseFloat(strings.TrimPrefix(strings.ToLower(args[1]), "ksh"), 64)
+	if err != nil || amount <= 0 {
+		return fmt.Sprintf("Invalid amount %q", args[1])
+	}
+	period, err := parseBudgetPeriod(args[2:])
+	if err != nil {
+		return err.Error()
+	}
+
+	budget := storage.Budget{Category: category, Period: period, Amount: amount, CreatedBy: userID}
+	if err := b.db.SetBudget(&budget); err != nil {
+		return fmt.Sprintf("Failed to set budget: %v", err)
+	}
+	return fmt.Sprintf("💰 Budget set: %s\n%s", b.budgetLine(budget, b.now()), "Alerts are posted at 80% and 100%.")
+}
+
+// deleteBudgetText handles "<category> [period]".
+func (b *Bot) deleteBudgetText(args []string) string {
+	category := strings.ToLower(args[0])
+	period, err := parseBudgetPeriod(args[1:])
+	if err != nil {
+		return err.Error()
+	}
+
+	if err := b.db.DeleteBudget(category, period); err != nil {
+		if errors.Is(err, storage.ErrBudgetNotFound) {
+			return fmt.Sprintf("%s has no %s budget", strings.Title(category), periodAdjective(period))
+		}
+		return fmt.Sprintf("Failed to delete budget: %v", err)
+	}
+	return fmt.Sprintf("🗑️ Deleted the %s budget of %s", periodAdjective(period), strings.Title(category))
+}
+
+func (b *Bot) budgetListText() string {
+	budgets, err := b.db.ListBudgets()
+	if err != nil {
+		return fmt.Sprintf("Failed to list budgets: %v", err)
+	}
+	if len(budgets) == 0 {
+		return "No budgets yet. Set one with !budget set."
+	}
+
+	response := "💰 **Budgets**\n\n"
+	for _, budget := range budgets {
+		response += "• " + b.budgetLine(budget, b.now()) + "\n"
+	}
+	return response
+}
+
+// budgetLines describes the budgets of a category, or of every category when
+// category is empty, as they stood at until.
+func (b *Bot) budgetLines(category string, until time.Time) []string {
+	budgets, err := b.db.ListBudgets()
+	if err != nil {
+		log.Printf("failed to load budgets: %v", err)
+		return nil
+	}
+	var lines []string
+	for _, budget := range budgets {
+		if category == "" || budget.Category == category {
+			lines = append(lines, b.budgetLine(budget, until))
+		}
+	}
+	return lines
+}
+
+// budgetLine describes how much of a budget was left at until, counting the
+// spending of its period up to then, e.g. "Food (monthly): Ksh150.00 left of
+// Ksh500.00". A digest passes the end of the period it covers, so spending
+// after that does not count.
+func (b *Bot) budgetLine(budget storage.Budget, until time.Time) string {
+	current := calendarPeriod(until.Add(-time.Nanosecond), budget.Period)
+	current.to = until
+	name := fmt.Sprintf("%s (%s)", strings.Title(budget.Category), periodAdjective(budget.Period))
+	spent, err := b.budgetSpent(budget, current)
+	if err != nil {
+		return fmt.Sprintf("%s: Ksh%.2f, spending unavailable: %v", name, budget.Amount, err)
+	}
+	if spent > budget.Amount {
+		return fmt.Sprintf("%s: Ksh%.2f over Ksh%.2f", name, spent-budget.Amount, budget.Amount)
+	}
+	return fmt.Sprintf("%s: Ksh%.2f left of Ksh%.2f", name, budget.Amount-spent, budget.Amount)
+}
+
+// budgetSpent totals what the whole ledger spent on the budget's category
+// and its subcategories in period.
+func (b *Bot) budgetSpent(budget storage.Budget, p period) (float64, error) {
+	return b.totalOf(p.filter(storage.TransactionFilter{Categories: b.categoryWithChildren(budget.Category)}))
+}
+
+// checkBudgets posts an alert for every budget that the saved transactions
+// pushed past 80% or 100% of its amount.
+func (b *Bot) checkBudgets(r Replier, saved []storage.Transaction) {
+	for _, alert := range b.budgetAlerts(saved, nil) {
+		r.Reply(alert)
+	}
+}
+
+// budgetAlerts returns the alerts for budgets that the changed transactions
+// pushed past a threshold. previous maps recategorized transactions to their
+// former category; they only count toward budgets they newly fall under.
+// Transactions are grouped per budget period, so a batch raises at most one
+// alert per budget and period.
+func (b *Bot) budgetAlerts(changed []storage.Transaction, previous map[string]string) []string {
+	if len(changed) == 0 {
+		return nil
+	}
+	budgets, err := b.db.ListBudgets()
+	if err != nil {
+		log.Printf("failed to load budgets: %v", err)
+		return nil
+	}
+
+	var alerts []string
+
+	for _, budget := range budgets {
+		categories := make(map[string]bool)
+		for _, name := range b.categoryWithChildren(budget.Category) {
+			categories[name] = true
+		}
+
+		added := make(map[period]float64)
+		var order []period
+		for _, tx := range changed {
+			if !categories[tx.Category] || categories[previous[tx.TransactionID]] {
+				continue
+			}
+			p := calendarPeriod(tx.DateTime, budget.Period)
+			if _, ok := added[p]; !ok {
+				order = append(order, p)
+			}
+			added[p] += tx.Amount
+		}
+
+		for _, p := range order {
+			spent, err := b.budgetSpent(budget, p)
+			if err != nil {
+				log.Printf("failed to total the %s budget of %s: %v", budget.Period, budget.Category, err)
+				continue
+			}
+			if alert := budgetAlertText(budget, p, spent-added[p], spent); alert != "" {
+				alerts = append(alerts, alert)
+			}
+		}
+	}
+	return alerts
+}
+
+// previousCategory returns the category a transaction had before its latest
+// change, as recorded in the audit log, or "" if it cannot be told.
+func (b *Bot) previousCategory(transactionID string) string {
+	entries, err := b.db.GetAuditEntries(transactionID)
+	if err != nil || len(entries) == 0 {
+		return ""
+	}
+	before, err := storage.Snapshot(entries[len(entries)-1].Before)
+	if err != nil || before == nil {
+		return ""
+	}
+	return before.Category
+}
+
+// budgetAlertText returns the alert for the highest threshold crossed by
+// going from before to after, or "" if none was.
+func budgetAlertText(budget storage.Budget, p period, before, after float64) string {
+	for _, threshold := range budgetThresholds {
+		limit := threshold * budget.Amount
+		if before >= limit || after < limit {
+			continue
+		}
+		name := strings.Title(budget.Category)
+		used := after / budget.Amount * 100
+		if threshold >= 1 {
+			return fmt.Sprintf("🚨 %s is over its %s budget for %s: Ksh%.2f of Ksh%.2f (%.0f%%).",
+				name, periodAdjective(budget.Period), p.label, after, budget.Amount, used)
+		}
+		return fmt.Sprintf("⚠️ %s has used %.0f%% of its %s budget for %s: Ksh%.2f left of Ksh%.2f.",
+			name, used, periodAdjective(budget.Period), p.label, budget.Amount-after, budget.Amount)
+	}
+	return ""
+}
@@ -0,0 +1,195 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NgigiN/wallet/internal/storage"
+)
+
+// categoryNamePattern keeps names usable as single words in commands.
+var categoryNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+func validCategoryName(name string) error {
+	if !categoryNamePattern.MatchString(name) {
+		return fmt.Errorf("category names start with a letter and use only a-z, 0-9, - and _ (max 32)")
+	}
+	if name == uncategorized {
+		return fmt.Errorf("%s is reserved for pending transactions", uncategorized)
+	}
+	if name == scopeAll {
+		return fmt.Errorf("%s is reserved for views across every ledger", scopeAll)
+	}
+	// Summaries would read the name as a period instead
+	if _, isPeriod, _ := parsePeriod(name, time.Now()); isPeriod {
+		return fmt.Errorf("%s is reserved for summary periods", name)
+	}
+	return nil
+}
+
+func (b *Bot) handleCategoryCommand(ctx *Context) {
+	fields := strings.Fields(strings.ToLower(ctx.Args.Text))
+	if len(fields) == 0 {
+		ctx.ReplyUsage()
+		return
+	}
+
+	var response string
+	changed := false
+	switch args := fields[1:]; {
+	case fields[0] == "list" && len(args) == 0:
+		response = b.categoryListText()
+
+	case fields[0] == "add" && (len(args) == 1 || len(args) == 2):
+		parent := ""
+		if len(args) == 2 {
+			parent = args[1]
+		}
+		response, changed = b.addCategoryText(args[0], parent)
+
+	case fields[0] == "rename" && len(args) == 2:
+		response, changed = b.renameCategoryText(args[0], args[1], ctx.Origin("!category"))
+
+	case (fields[0] == "archive" || fields[0] == "unarchive") && len(args) == 1:
+		response, changed = b.archiveCategoryText(args[0], fields[0] == "archive")
+
+	case fields[0] == "alias" && len(args) == 2:
+		response = b.addAliasText(args[0], args[1])
+
+	case fields[0] == "unalias" && len(args) == 1:
+		response = b.removeAliasText(args[0])
+
+	default:
+		response = ctx.Command.UsageText()
+	}
+
+	ctx.Reply(response)
+	if s := ctx.Session; changed && s.State != nil && s.State.User != nil {
+		// Slash command choices are baked into the registration
+		b.syncSlashCommands(s, s.State.User.ID)
+	}
+}
+
+func (b *Bot) categoryListText() string {
+	categories, err := b.db.ListCategories(true)
+	if err != nil {
+		return fmt.Sprintf("Failed to list categories: %v", err)
+	}
+
+	aliases, err := b.db.ListCategoryAliases()
+	if err != nil {
+		return fmt.Sprintf("Failed to list aliases: %v", err)
+	}
+	aliasesOf := make(map[string][]string)
+	for alias, category := range aliases {
+		aliasesOf[category] = append(aliasesOf[category], alias)
+	}
+
+	names := make(map[uint]string)
+	for _, c := range categories {
+		names[c.ID] = c.Name
+	}
+
+	response := "🗂️ **Categories**\n\n"
+	for _, c := range categories {
+		response += "• " + c.Name
+		if c.ParentID != nil {
+			response += fmt.Sprintf(" (under %s)", names[*c.ParentID])
+		}
+		if c.Archived {
+			response += " [archived]"
+		}
+		if list := aliasesOf[c.Name]; len(list) > 0 {
+			sort.Strings(list)
+			response += " — also: " + strings.Join(list, ", ")
+		}
+		response += "\n"
+	}
+	return response
+}
+
+func (b *Bot) addCategoryText(name, parent string) (string, bool) {
+	if err := validCategoryName(name); err != nil {
+		return fmt.Sprintf("Invalid category name %s: %v", name, err), false
+	}
+
+	if _, err := b.db.CreateCategory(name, parent); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrDuplicateCategory):
+			return fmt.Sprintf("Category %s already exists", name), false
+		case errors.Is(err, storage.ErrCategoryNotFound):
+			return fmt.Sprintf("Parent category %s not found", parent), false
+		}
+		return fmt.Sprintf("Failed to add category %s: %v", name, err), false
+	}
+
+	if parent != "" {
+		return fmt.Sprintf("✅ Added category %s under %s", name, parent), true
+	}
+	return fmt.Sprintf("✅ Added category %s", name), true
+}
+
+func (b *Bot) renameCategoryText(oldName, newName string, origin storage.Origin) (string, bool) {
+	if err := validCategoryName(newName); err != nil {
+		return fmt.Sprintf("Invalid category name %s: %v", newName, err), false
+	}
+
+	if err := b.db.RenameCategory(oldName, newName, origin); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrDuplicateCategory):
+			return fmt.Sprintf("Category %s already exists", newName), false
+		case errors.Is(err, storage.ErrCategoryNotFound):
+			return fmt.Sprintf("Category %s not found", oldName), false
+		}
+		return fmt.Sprintf("Failed to rename category %s: %v", oldName, err), false
+	}
+	if history, err := b.db.GetAllTransactions(); err == nil {
+		b.classifier.Reset(history)
+	}
+	return fmt.Sprintf("✏️ Renamed category %s to %s", oldName, newName), true
+}
+
+func (b *Bot) archiveCategoryText(name string, archived bool) (string, bool) {
+	if err := b.db.SetCategoryArchived(name, archived); err != nil {
+		if errors.Is(err, storage.ErrCategoryNotFound) {
+			return fmt.Sprintf("Category %s not found", name), false
+		}
+		return fmt.Sprintf("Failed to update category %s: %v", name, err), false
+	}
+
+	if archived {
+		return fmt.Sprintf("📦 Archived %s. Existing transactions keep it, but it can no longer be picked.", name), true
+	}
+	return fmt.Sprintf("📂 Unarchived %s", name), true
+}
+
+func (b *Bot) addAliasText(alias, category string) string {
+	if err := validCategoryName(alias); err != nil {
+		return fmt.Sprintf("Invalid alias %s: %v", alias, err)
+	}
+
+	if err := b.db.AddCategoryAlias(alias, category); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrDuplicateCategory):
+			return fmt.Sprintf("%s is already a category or alias", alias)
+		case errors.Is(err, storage.ErrCategoryNotFound):
+			return fmt.Sprintf("Category %s not found", category)
+		}
+		return fmt.Sprintf("Failed to add alias %s: %v", alias, err)
+	}
+	return fmt.Sprintf("🔗 %s now means %s", alias, category)
+}
+
+func (b *Bot) removeAliasText(alias string) string {
+	if err := b.db.RemoveCategoryAlias(alias); err != nil {
+		if errors.Is(err, storage.ErrAliasNotFound) {
+			return fmt.Sprintf("Alias %s not found", alias)
+		}
+		return fmt.Sprintf("Failed to remove alias %s: %v", alias, err)
+	}
+	return fmt.Sprintf("Removed alias %s", alias)
+}
@@ -0,0 +1,178 @@
+package discord
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NgigiN/wallet/internal/chart"
+	"github.com/NgigiN/wallet/internal/permissions"
+	"github.com/NgigiN/wallet/internal/storage"
+	"github.com/bwmarrin/discordgo"
+)
+
+// chartKinds draws each kind of !chart.
+var chartKinds = map[string]func(title string, values []chart.Value) ([]byte, error){
+	"pie":  chart.Pie,
+	"bar":  chart.Bar,
+	"line": chart.Line,
+}
+
+// chartDailyLimit is the longest period, in days, that bar and line charts
+// show day by day. Years and longer ranges are shown month by month.
+const chartDailyLimit = 62
+
+// chartFile is the name of the uploaded image, which the embed refers to.
+const chartFile = "chart.png"
+
+// handleChartCommand handles "<pie|bar|line> [summary arguments]". The
+// arguments pick the category, period and scope as for !summary, with this
+// month as the default period.
+func (b *Bot) handleChartCommand(ctx *Context) {
+	if len(ctx.Args.Words) == 0 {
+		ctx.ReplyUsage()
+		return
+	}
+	kind := strings.ToLower(ctx.Args.Words[0])
+	draw, ok := chartKinds[kind]
+	if !ok {
+		ctx.ReplyUsage()
+		return
+	}
+	query, err := b.parseSummaryArgs(ctx.Args.Words[1:], ctx.UserID(), ctx.Allowed(permissions.Admin))
+	if err != nil {
+		ctx.Reply(fmt.Sprintf("Invalid chart: %v\n%s", err, ctx.Command.UsageText()))
+		return
+	}
+	if query.period == nil {
+		query.period = ptr(calendarPeriod(b.now(), storage.Month))
+	}
+
+	var title string
+	var values []chart.Value
+	if kind == "pie" {
+		title, values, err = b.categorySplit(query)
+	} else {
+		title, values, err = b.spendingOverTime(query)
+	}
+	if err != nil {
+		ctx.Reply(fmt.Sprintf("Failed to draw the chart: %v", err))
+		return
+	}
+	image, err := draw(title, values)
+	if errors.Is(err, chart.ErrNoData) {
+		ctx.Reply(fmt.Sprintf("No spending to chart for %s.", query.period.label))
+		return
+	}
+	if err != nil {
+		ctx.Reply(fmt.Sprintf("Failed to draw the chart: %v", err))
+		return
+	}
+
+	ctx.ReplyComplex(&discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{{
+			Title: "📈 " + title,
+			Color: embedColor,
+			Image: &discordgo.MessageEmbedImage{URL: "attachment://" + chartFile},
+		}},
+		Files: []*discordgo.File{{Name: chartFile, ContentType: "image/png", Reader: bytes.NewReader(image)}},
+	})
+}
+
+// categorySplit totals the query's spending per top-level category, with
+// children rolled into their parents. When the query names a category, it
+// totals its direct children instead, plus what was filed under the
+// category itself.
+func (b *Bot) categorySplit(query summaryQuery) (string, []chart.Value, error) {
+	filter := storage.TransactionFilter{}
+	title := "Spending by category"
+	if query.category != "" {
+		filter.Categories = b.categoryWithChildren(query.category)
+		title = strings.Title(query.category) + " by subcategory"
+	}
+	summary, err := b.db.SummarizeByCategory(query.filter(filter))
+	if err != nil {
+		return "", nil, err
+	}
+	// Archived categories are included so their history still adds up
+	categories, err := b.db.ListCategories(true)
+	if err != nil {
+		return "", nil, err
+	}
+	tree := storage.CategoryTree(categories)
+
+	var parent *storage.Category
+	for i := range categories {
+		if categories[i].Name == query.category {
+			parent = &categories[i]
+		}
+	}
+	slice := func(c storage.Category) bool {
+		if query.category == "" {
+			return c.ParentID == nil
+		}
+		return parent != nil && c.ParentID != nil && *c.ParentID == parent.ID
+	}
+
+	counted := make(map[string]bool)
+	var values []chart.Value
+	for _, c := range categories {
+		if !slice(c) {
+			continue
+		}
+		var amount float64
+		for _, name := range tree[c.Name] {
+			amount += summary[name]
+			counted[name] = true
+		}
+		values = append(values, chart.Value{Label: strings.Title(c.Name), Amount: amount})
+	}
+
+	// What is left was filed under the category itself, awaits a category,
+	// or uses a name no longer in the categories table
+	var rest []string
+	for name := range summary {
+		if !counted[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	for _, name := range rest {
+		values = append(values, chart.Value{Label: strings.Title(name), Amount: summary[name]})
+	}
+	return query.title(title), values, nil
+}
+
+// spendingOverTime totals the query's spending per day of its period, or per
+// month when the period is longer than chartDailyLimit days. Days or months
+// without spending are included as zero.
+func (b *Bot) spendingOverTime(query summaryQuery) (string, []chart.Value, error) {
+	p := *query.period
+	granularity, layout, title := storage.Day, "Jan 2", "Daily spending"
+	if p.granularity == storage.Year || p.to.Sub(p.from) > chartDailyLimit*24*time.Hour {
+		granularity, layout, title = storage.Month, "Jan 2006", "Monthly spending"
+	}
+	filter := storage.TransactionFilter{}
+	if query.category != "" {
+		filter.Categories = b.categoryWithChildren(query.category)
+		title += " on " + strings.Title(query.category)
+	}
+
+	totals, err := b.db.AggregateByPeriod(query.filter(filter), granularity)
+	if err != nil {
+		return "", nil, err
+	}
+	byPeriod := make(map[string]float64)
+	for _, total := range totals {
+		byPeriod[total.Period.Format(dateLayout)] = total.Total
+	}
+
+	var values []chart.Value
+	for t := storage.PeriodStart(p.from, granularity); t.Before(p.to); t = storage.PeriodEnd(t, granularity) {
+		values = append(values, chart.Value{Label: t.Format(layout), Amount: byPeriod[t.Format(dateLayout)]})
+	}
+	return query.title(title), values, nil
+}
@@ -0,0 +1,217 @@
+package discord
+
+import (
+	"time"
+
+	"github.com/NgigiN/wallet/internal/permissions"
+)
+
+// filterUsage is the argument syntax shared by !search and !export.
+const filterUsage = "[recipient] [c:category] [from:YYYY-MM-DD] [to:YYYY-MM-DD] [min:amount] [max:amount] [type:sent|paid] [tag:name] [@member...] [h:household] [all]"
+
+// newRouter registers every prefix command. Handlers live next to the
+// feature they belong to; this is the one place listing them.
+func newRouter() *Router {
+	r := NewRouter()
+	r.Use(logCommands, requirePermission)
+
+	r.Register(
+		&Command{
+			Name:        "help",
+			Usage:       []string{"[command]"},
+			Description: "List the commands, message format and categories, or explain one command",
+			Examples:    []string{"!help", "!help search"},
+			Params:      []Param{{Name: "command", Type: WordParam, Optional: true}},
+			Run:         (*Bot).handleHelpCommand,
+		},
+		&Command{
+			Name:        "summary",
+			Usage:       []string{"[category] [today|week|month|year|YYYY-MM|YYYY-MM-DD..YYYY-MM-DD] [@member...] [h:household] [all]"},
+			Description: "Show your totals per category, or the transactions of one category",
+			Examples: []string{
+				"!summary - show your totals per category",
+				"!summary food - show your food transactions",
+				"!summary month - compare this month with the last, per category",
+				"!summary travel 2025-09-01..2025-09-15 - travel in the first half of September",
+				"!summary h:family - combine the ledgers of household family",
+			},
+			Capability: permissions.View,
+			Run:        (*Bot).handleSummaryCommand,
+		},
+		&Command{
+			Name:        "chart",
+			Usage:       []string{"<pie|bar|line> [category] [today|week|month|year|YYYY-MM|YYYY-MM-DD..YYYY-MM-DD] [@member...] [h:household] [all]"},
+			Description: "Draw your spending as a chart: pie by category, or bar and line over time",
+			Examples: []string{
+				"!chart pie - your spending per category this month",
+				"!chart bar - your spending per day this month",
+				"!chart line year - your spending per month this year",
+				"!chart pie food 2025-09 - food by subcategory in September 2025",
+			},
+			Capability: permissions.View,
+			Run:        (*Bot).handleChartCommand,
+		},
+		&Command{
+			Name:        "search",
+			Usage:       []string{filterUsage},
+			Description: "Find transactions by recipient, category, date, amount or tag",
+			Examples:    []string{"!search mwania c:food from:2025-09-01"},
+			Capability:  permissions.View,
+			Middleware:  []Middleware{rateLimit(10, time.Minute)},
+			Run:         (*Bot).handleSearchCommand,
+		},
+		&Command{
+			Name:        "export",
+			Usage:       []string{filterUsage},
+			Description: "Download matching transactions as CSV",
+			Examples:    []string{"!export from:2025-09-01 to:2025-09-30"},
+			Capability:  permissions.Export,
+			Middleware:  []Middleware{rateLimit(3, time.Minute)},
+			Run:         (*Bot).handleExportCommand,
+		},
+		&Command{
+			Name:        "edit",
+			Usage:       []string{"<TransactionID> [c: <category>] [r: <reason>] [t: <tags>]"},
+			Description: "Change the category, reason or tags of a transaction",
+			Examples:    []string{"!edit TIL4XR5BBM c: travel r: matatu to town"},
+			Params: []Param{
+				{Name: "transaction", Type: TransactionIDParam},
+				{Name: "changes", Type: TextParam},
+			},
+			Capability: permissions.Edit,
+			Run:        (*Bot).handleEditCommand,
+		},
+		&Command{
+			Name:        "recategorize",
+			Usage:       []string{"<category> <TransactionID> [TransactionID...]"},
+			Description: "Move several transactions to a category at once",
+			Examples:    []string{"!recategorize travel TIL4XR5BBM TIL3XTT9WB"},
+			Params: []Param{
+				{Name: "category", Type: WordParam},
+				{Name: "transactions", Type: TransactionIDParam, Variadic: true},
+			},
+			Capability: permissions.Edit,
+			Run:        (*Bot).handleRecategorizeCommand,
+		},
+		&Command{
+			Name:        "delete",
+			Usage:       []string{"<TransactionID>"},
+			Description: "Move a transaction to the trash",
+			Examples:    []string{"!delete TIL4XR5BBM"},
+			Params:      []Param{{Name: "transaction", Type: TransactionIDParam}},
+			Capability:  permissions.Delete,
+			Run:         (*Bot).handleDeleteCommand,
+		},
+		&Command{
+			Name:        "undo",
+			Description: "Revert your last save or batch",
+			Capability:  permissions.Log,
+			Run:         (*Bot).handleUndoCommand,
+		},
+		&Command{
+			Name:        "trash",
+			Usage:       []string{"[@member...] [h:household] [all]"},
+			Description: "List recently deleted transactions",
+			Capability:  permissions.Delete,
+			Run:         (*Bot).handleTrashCommand,
+		},
+		&Command{
+			Name:        "restore",
+			Usage:       []string{"<TransactionID>"},
+			Description: "Bring a transaction back from the trash",
+			Examples:    []string{"!restore TIL4XR5BBM"},
+			Params:      []Param{{Name: "transaction", Type: TransactionIDParam}},
+			Capability:  permissions.Delete,
+			Run:         (*Bot).handleRestoreCommand,
+		},
+		&Command{
+			Name:        "history",
+			Usage:       []string{"<TransactionID>"},
+			Description: "Show who changed a transaction and how",
+			Examples:    []string{"!history TIL4XR5BBM"},
+			Params:      []Param{{Name: "transaction", Type: TransactionIDParam}},
+			Capability:  permissions.View,
+			Run:         (*Bot).handleHistoryCommand,
+		},
+		&Command{
+			Name: "category",
+			Usage: []string{
+				"list",
+				"add <name> [parent]",
+				"rename <old> <new>",
+				"archive <name>",
+				"unarchive <name>",
+				"alias <alias> <name>",
+				"unalias <alias>",
+			},
+			Description: "List and manage categories",
+			Examples:    []string{"!category add matatu travel"},
+			Capability:  permissions.Admin,
+			Actions:     map[string]permissions.Capability{"list": permissions.View},
+			Run:         (*Bot).handleCategoryCommand,
+		},
+		&Command{
+			Name: "rule",
+			Usage: []string{
+				"list",
+				"add [recipient] [account:number] [min:amount] [max:amount] [time:HH:MM-HH:MM] c:<category> [r:<reason>]",
+				"delete <id>",
+				"test <M-PESA message>",
+			},
+			Description: "Manage rules that file transactions sent without a category",
+			Examples:    []string{"!rule add mama mboga time:06:00-10:00 c:food r:vegetables"},
+			Capability:  permissions.Admin,
+			Actions:     map[string]permissions.Capability{"list": permissions.View, "test": permissions.View},
+			Run:         (*Bot).handleRuleCommand,
+		},
+		&Command{
+			Name: "budget",
+			Usage: []string{
+				"list",
+				"set <category> <amount> [week|month|year]",
+				"delete <category> [week|month|year]",
+			},
+			Description: "Cap what the ledger spends on a category, with alerts at 80% and 100%",
+			Examples:    []string{"!budget set food 5000 - at most Ksh5000 on food each month"},
+			Capability:  permissions.Admin,
+			Actions:     map[string]permissions.Capability{"list": permissions.View},
+			Run:         (*Bot).handleBudgetCommand,
+		},
+		&Command{
+			Name: "remind",
+			Usage: []string{
+				"status",
+				"on [days] [dm|channel]",
+				"off",
+			},
+			Description: "Get nudged when you log nothing for a few days or your balances show missed messages",
+			Examples:    []string{"!remind on 3 dm - a direct message after 3 days without a logged transaction"},
+			Capability:  permissions.Log,
+			Run:         (*Bot).handleRemindCommand,
+		},
+		&Command{
+			Name: "household",
+			Usage: []string{
+				"list",
+				"create <name>",
+				"add <name> @member [@member...]",
+				"remove <name> @member",
+				"join <name>",
+				"leave <name>",
+			},
+			Description: "Group members whose ledgers you view together; invited members join with !household join",
+			Examples:    []string{"!household add family @spouse", "!household join family"},
+			Capability:  permissions.Admin,
+			Actions:     map[string]permissions.Capability{"list": permissions.View, "join": permissions.View, "leave": permissions.View},
+			Run:         (*Bot).handleHouseholdCommand,
+		},
+	)
+
+	r.Fallback(&Command{
+		Name:        "message",
+		Description: "Log an M-PESA message, optionally followed by c:, r: and t: lines",
+		Capability:  permissions.Log,
+		Run:         (*Bot).handleTransactionMessage,
+	})
+	return r
+}
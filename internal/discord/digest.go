@@ -0,0 +1,114 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/NgigiN/wallet/internal/storage"
+	"github.com/bwmarrin/discordgo"
+)
+
+// schedulerInterval is how often the scheduler checks for due posts.
+const schedulerInterval = time.Minute
+
+// digestTopTransactions is how many of the biggest transactions a digest
+// lists.
+const digestTopTransactions = 3
+
+// runScheduler posts the digests due on every configured channel and the
+// reminders due to every user, once on start to catch up on runs missed
+// while the bot was down, then every minute until stop is closed.
+func (b *Bot) runScheduler(stop <-chan struct{}) {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+	for {
+		for _, lb := range b.channels.guildBots() {
+			lb.postDueDigests()
+		}
+		b.sendDueReminders()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// postDueDigests posts each digest whose latest period has not gone out yet.
+// Only the most recent period is posted, so a long outage does not flood
+// the channel with old digests.
+func (b *Bot) postDueDigests() {
+	now := b.now()
+	for _, entry := range b.digests {
+		key := "digest:" + string(entry.Period)
+		covered := entry.Covered(entry.Last(now))
+		last, err := b.db.LastScheduledPost(key)
+		if err != nil {
+			log.Printf("failed to check the %s digest of ledger %s: %v", entry.Period, b.db.Ledger(), err)
+			continue
+		}
+		if !last.Before(covered) {
+			continue
+		}
+
+		reply := channelReplier{session: b.session, channelID: b.channelID}
+		embed, text := b.digestEmbed(calendarPeriod(covered, entry.Period))
+		if embed == nil {
+			reply.Reply(text)
+		} else {
+			replyEmbed(reply, embed, nil)
+		}
+		if err := b.db.RecordScheduledPost(key, covered); err != nil {
+			log.Printf("failed to record the %s digest of ledger %s: %v", entry.Period, b.db.Ledger(), err)
+		}
+	}
+}
+
+// digestEmbed reports on a finished period for the whole ledger: spending
+// per category compared with the period before, the biggest transactions,
+// the transaction costs paid and how the budgets stood when the period
+// ended. It returns the text to post instead when nothing was logged.
+func (b *Bot) digestEmbed(p period) (*discordgo.MessageEmbed, string) {
+	title := fmt.Sprintf("🗓️ %s Digest · %s", strings.Title(periodAdjective(p.granularity)), p.label)
+	count, err := b.db.CountTransactions(p.filter(storage.TransactionFilter{}))
+	if err != nil {
+		return nil, fmt.Sprintf("Failed to build the digest: %v", err)
+	}
+	if count == 0 {
+		return nil, fmt.Sprintf("%s\nNothing was logged.", title)
+	}
+	embed, text := b.summaryEmbed(summaryQuery{period: &p})
+	if embed == nil {
+		return nil, text
+	}
+	embed.Title = title
+
+	filter := p.filter(storage.TransactionFilter{SortBy: storage.SortByAmount, Limit: digestTopTransactions})
+	biggest, err := b.db.FindTransactions(filter)
+	if err != nil {
+		return nil, fmt.Sprintf("Failed to build the digest: %v", err)
+	}
+	var lines []string
+	for _, tx := range biggest {
+		lines = append(lines, fmt.Sprintf("Ksh%.2f to %s (%s) · %s", tx.Amount, tx.Recipient, tx.Category, tx.DateTime.Format("Jan 2")))
+	}
+
+	totals, err := b.db.AggregateByPeriod(p.filter(storage.TransactionFilter{}), p.granularity)
+	if err != nil {
+		return nil, fmt.Sprintf("Failed to build the digest: %v", err)
+	}
+	var cost float64
+	for _, total := range totals {
+		cost += total.Cost
+	}
+
+	addListField(embed, "🔝 Biggest transactions", lines)
+	embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+		Name:  "💸 Transaction costs",
+		Value: fmt.Sprintf("Ksh%.2f over %d transactions", cost, count),
+	})
+	addListField(embed, "💰 Budgets", b.budgetLines("", p.to))
+	return embed, ""
+}
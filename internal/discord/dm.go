@@ -0,0 +1,60 @@
+package discord
+
+import (
+	"log"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// dmLedgerPrefix names the private ledger of each user who messages the bot
+// directly, e.g. "dm:1234". Configured ledgers cannot contain a colon, so
+// these never collide with a channel's ledger.
+const dmLedgerPrefix = "dm:"
+
+func (b *Bot) isDM() bool {
+	return strings.HasPrefix(b.db.Ledger(), dmLedgerPrefix)
+}
+
+// botFor returns the bot serving a channel. A direct message opens the
+// sender's private ledger the first time they write; replies go back to the
+// DM channel, so nothing logged there shows up in a shared channel. Events
+// from other guild channels get nil.
+func (b *Bot) botFor(channelID, guildID, userID string) *Bot {
+	if lb := b.channels.get(channelID); lb != nil {
+		return lb
+	}
+	if guildID != "" || userID == "" {
+		return nil
+	}
+
+	b.channels.mu.Lock()
+	defer b.channels.mu.Unlock()
+	if lb := b.channels.bots[channelID]; lb != nil {
+		return lb
+	}
+	lb, err := newLedgerBot(b.session, b.db, dmLedgerPrefix+userID, channelID, b.channels)
+	if err != nil {
+		log.Printf("failed to open the private ledger of %s: %v", userID, err)
+		return nil
+	}
+	lb.router = b.router
+	b.channels.bots[channelID] = lb
+	return lb
+}
+
+// dmUser returns the user at the other end of a direct message channel, or
+// "" if the channel is not a direct message.
+func dmUser(s *discordgo.Session, channelID string) string {
+	channel, err := s.State.Channel(channelID)
+	if err != nil {
+		if channel, err = s.Channel(channelID); err != nil {
+			log.Printf("failed to look up channel %s: %v", channelID, err)
+			return ""
+		}
+	}
+	if channel.Type != discordgo.ChannelTypeDM || len(channel.Recipients) == 0 {
+		return ""
+	}
+	return channel.Recipients[0].ID
+}
@@ -0,0 +1,221 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/NgigiN/wallet/internal/mpesa"
+	"github.com/NgigiN/wallet/internal/permissions"
+	"github.com/NgigiN/wallet/internal/storage"
+	"github.com/bwmarrin/discordgo"
+)
+
+// handleMessageUpdate follows edits to a logged message: metadata changes
+// are applied to its transactions, and transactions cut from the message are
+// moved to the trash. A message that saved nothing, e.g. because of a typo
+// in the category, is handled as if it had just been sent.
+func (b *Bot) handleMessageUpdate(s *discordgo.Session, m *discordgo.MessageUpdate) {
+	// Embed unfurls also arrive as updates, without an author
+	if m.Message == nil || m.Author == nil || m.Author.ID == s.State.User.ID {
+		return
+	}
+
+	lb := b.botFor(m.ChannelID, m.GuildID, m.Author.ID)
+	if lb == nil {
+		return
+	}
+	if lb != b {
+		lb.handleMessageUpdate(s, m)
+		return
+	}
+
+	content := cleanContent(m.Content)
+	if strings.HasPrefix(content, "!") {
+		return // commands are not re-run
+	}
+
+	linked, err := b.db.FindTransactions(storage.TransactionFilter{MessageID: m.ID})
+	if err != nil {
+		log.Printf("failed to look up transactions of message %s: %v", m.ID, err)
+		return
+	}
+	reply := channelReplier{session: s, channelID: m.ChannelID}
+	if len(linked) == 0 {
+		// Trashed transactions keep their IDs, so saving them again would
+		// only report duplicates
+		trashed, err := b.db.GetDeletedTransactions(storage.TransactionFilter{MessageID: m.ID})
+		if err != nil {
+			log.Printf("failed to look up deleted transactions of message %s: %v", m.ID, err)
+			return
+		}
+		if len(trashed) > 0 {
+			reply.Reply(trashedText(trashed))
+			return
+		}
+		b.handleMessage(s, &discordgo.MessageCreate{Message: m.Message})
+		return
+	}
+	if !b.allowed(m.Author.ID, m.Member, permissions.Log) {
+		reply.Reply(deniedText(permissions.Log))
+		return
+	}
+
+	response, added := b.messageEditText(content, linked, m.Author.ID)
+	if response != "" {
+		reply.Reply(response)
+	}
+	if len(added) > 0 {
+		// Transactions added to the message are saved as if they had just
+		// been sent
+		b.handleBatchMessage(&Context{
+			Replier: reply,
+			Bot:     b,
+			Session: s,
+			Message: &discordgo.MessageCreate{Message: m.Message},
+			Content: joinTransactions(added),
+		})
+	}
+}
+
+// messageEditText applies an edited message to the transactions logged from
+// it and describes what changed, or returns "" if nothing did. Only the
+// metadata present in the message is applied, so clearing a line keeps the
+// stored value; use !edit for that. Transactions the message now holds but
+// that were not logged from it are returned for saving.
+func (b *Bot) messageEditText(content string, linked []storage.Transaction, userID string) (string, []TransactionData) {
+	var transactions []TransactionData
+	if b.isBatchMessage(content) {
+		transactions = b.splitIntoTransactions(content)
+	} else {
+		lines := strings.Split(content, "\n")
+		transactions = []TransactionData{{Message: lines[0], Metadata: lines[1:]}}
+	}
+
+	isLinked := make(map[string]bool)
+	for _, tx := range linked {
+		isLinked[tx.TransactionID] = true
+	}
+	entries := make(map[string][]string)
+	var added []TransactionData
+	for _, txData := range transactions {
+		parsed, err := mpesa.ParseMPesaMessage(txData.Message)
+		if err != nil {
+			continue
+		}
+		entries[parsed.TransactionID] = txData.Metadata
+		if !isLinked[parsed.TransactionID] {
+			added = append(added, txData)
+		}
+	}
+
+	origin := storage.Origin{UserID: userID, Source: "message edit"}
+	var responses []string
+	for _, tx := range linked {
+		metadata, ok := entries[tx.TransactionID]
+		if !ok {
+			responses = append(responses, b.deleteText(tx.TransactionID, origin))
+			continue
+		}
+
+		var update storage.TransactionUpdate
+		category, reason, tags := parseMetadata(metadata)
+		if category != uncategorized {
+			// Compare what the category resolves to; unknown names are left
+			// for editText to report
+			if resolved, _ := b.resolveCategory(category); resolved != "" {
+				category = resolved
+			}
+			if category != tx.Category {
+				update.Category = &category
+			}
+		}
+		if reason != "" && reason != tx.Reason {
+			update.Reason = &reason
+		}
+		if joined := storage.JoinTags(tags); joined != "" && joined != tx.Tags {
+			update.Tags = &joined
+		}
+		if update.Category != nil || update.Reason != nil || update.Tags != nil {
+			responses = append(responses, b.editText(tx.TransactionID, update, origin))
+		}
+	}
+	return strings.Join(responses, "\n"), added
+}
+
+// trashedText tells the author of an edited message that its transactions
+// are in the trash and how to bring them back.
+func trashedText(trashed []storage.Transaction) string {
+	ids := make([]string, len(trashed))
+	for i, tx := range trashed {
+		ids[i] = tx.TransactionID
+	}
+	verb := "is"
+	if len(ids) > 1 {
+		verb = "are"
+	}
+	return fmt.Sprintf("🗑️ %s from this message %s in the trash, so the edit was not applied. Use !restore <id> to bring it back, then edit again.", strings.Join(ids, ", "), verb)
+}
+
+// joinTransactions writes transactions back as the content of a message,
+// each with its metadata lines.
+func joinTransactions(transactions []TransactionData) string {
+	blocks := make([]string, len(transactions))
+	for i, txData := range transactions {
+		blocks[i] = strings.Join(append([]string{txData.Message}, txData.Metadata...), "\n")
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// handleMessageDelete moves the transactions of a deleted message to the
+// trash. Discord only lets the author or a moderator delete a message, so no
+// further permission is checked. Deletions carry no author, so a direct
+// message whose ledger is not open yet, e.g. after a restart, is traced back
+// to its user through the channel.
+func (b *Bot) handleMessageDelete(s *discordgo.Session, m *discordgo.MessageDelete) {
+	if m.Message == nil {
+		return
+	}
+	userID := ""
+	if m.GuildID == "" && b.channels.get(m.ChannelID) == nil {
+		userID = dmUser(s, m.ChannelID)
+	}
+	lb := b.botFor(m.ChannelID, m.GuildID, userID)
+	if lb == nil {
+		return
+	}
+	if lb != b {
+		lb.handleMessageDelete(s, m)
+		return
+	}
+
+	linked, err := b.db.FindTransactions(storage.TransactionFilter{MessageID: m.ID})
+	if err != nil {
+		log.Printf("failed to look up transactions of message %s: %v", m.ID, err)
+		return
+	}
+	if len(linked) == 0 {
+		return
+	}
+
+	// Discord does not say who deleted a message: in a channel it may have
+	// been a moderator, so the actor is left unknown. Only the user can delete
+	// their messages in a direct message.
+	origin := storage.Origin{Source: "message delete"}
+	if b.isDM() {
+		origin.UserID = strings.TrimPrefix(b.db.Ledger(), dmLedgerPrefix)
+	}
+	var deleted []string
+	for _, tx := range linked {
+		if _, err := b.db.DeleteTransaction(tx.TransactionID, origin); err != nil {
+			log.Printf("failed to delete transaction %s of message %s: %v", tx.TransactionID, m.ID, err)
+			continue
+		}
+		b.classifier.Forget(tx.TransactionID)
+		deleted = append(deleted, tx.TransactionID)
+	}
+	if len(deleted) > 0 {
+		reply := channelReplier{session: s, channelID: m.ChannelID}
+		reply.Reply(fmt.Sprintf("🗑️ The message was deleted, so %s moved to the trash. Use !restore <id> to bring one back.", strings.Join(deleted, ", ")))
+	}
+}
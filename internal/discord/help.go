@@ -0,0 +1,64 @@
+package discord
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// handleHelpCommand explains the bot from its own definitions: the router's
+// commands, the metadata keys and the ledger's categories.
+func (b *Bot) handleHelpCommand(ctx *Context) {
+	if name := ctx.Args.String("command"); name != "" {
+		ctx.Reply(b.commandHelpText(strings.TrimPrefix(name, "!")))
+		return
+	}
+	ctx.Reply(b.helpText())
+}
+
+func (b *Bot) helpText() string {
+	var sb strings.Builder
+	sb.WriteString("📖 **Logging transactions**\n")
+	sb.WriteString("Paste an M-PESA message, optionally followed by one line per field:\n")
+	for _, field := range metadataFields {
+		fmt.Fprintf(&sb, "• `%s:` or `%s:` %s, e.g. `%s`\n", field.key, field.short, field.description, field.example)
+	}
+	sb.WriteString("Several messages in one post are saved as a batch, each with its own lines. Without a category, your rules or the category picker file it.\n")
+
+	sb.WriteString("\n📋 **Commands**\n")
+	for _, command := range b.router.Commands() {
+		fmt.Fprintf(&sb, "• `!%s` %s\n", command.Name, command.Description)
+	}
+
+	if categories := b.activeCategories(); len(categories) > 0 {
+		names := make([]string, len(categories))
+		for i, category := range categories {
+			names[i] = category.Name
+		}
+		fmt.Fprintf(&sb, "\n🗂️ **Categories**: %s\n", strings.Join(names, ", "))
+	}
+	sb.WriteString("\nUse !help <command> for its arguments and examples.")
+	return sb.String()
+}
+
+func (b *Bot) commandHelpText(name string) string {
+	command := b.router.Find("!" + name)
+	if command == nil {
+		return fmt.Sprintf("Unknown command !%s. Use !help to list the commands.", name)
+	}
+
+	response := fmt.Sprintf("📖 **!%s**: %s\n%s", command.Name, command.Description, command.UsageText())
+	if command.Capability != "" {
+		response += fmt.Sprintf("\nNeeds the %s permission", command.Capability)
+		actions := make([]string, 0, len(command.Actions))
+		for action := range command.Actions {
+			actions = append(actions, action)
+		}
+		sort.Strings(actions)
+		for _, action := range actions {
+			response += fmt.Sprintf(" (%s for %s)", command.Actions[action], action)
+		}
+		response += "."
+	}
+	return response
+}
@@ -0,0 +1,285 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/NgigiN/wallet/internal/permissions"
+	"github.com/NgigiN/wallet/internal/storage"
+)
+
+// scopeAll widens a query to every user in the current ledger.
+const scopeAll = "all"
+
+var mentionPattern = regexp.MustCompile(`^<@!?(\d+)>$`)
+
+// scope is whose ledgers a query covers. A nil userIDs means everyone.
+type scope struct {
+	userIDs []string
+	// label describes a scope other than the caller's own ledger, e.g.
+	// "household family"; it is empty for the default.
+	label string
+}
+
+func (sc scope) filter(filter storage.TransactionFilter) storage.TransactionFilter {
+	filter.UserIDs = sc.userIDs
+	return filter
+}
+
+// parseScope picks the scope arguments out of a command: "@member" mentions,
+// "h:<household>" and "all". Without any of them the scope is the caller's
+// own ledger, plus the unowned rows of the default ledger. Callers may only mention themselves and members of their
+// households, and only admins may use "all". The remaining arguments are
+// returned in order.
+func (b *Bot) parseScope(args []string, callerID string, admin bool) (scope, []string, error) {
+	var sc scope
+	var labels, rest []string
+	everyone := false
+
+	for _, arg := range args {
+		if m := mentionPattern.FindStringSubmatch(arg); m != nil {
+			shared, err := b.sharesHousehold(callerID, m[1])
+			if err != nil {
+				return sc, nil, err
+			}
+			if !shared {
+				return sc, nil, fmt.Errorf("you can only view the ledgers of members of your households")
+			}
+			sc.userIDs = append(sc.userIDs, m[1])
+			labels = append(labels, arg)
+			continue
+		}
+
+		key, value, ok := strings.Cut(arg, ":")
+		switch {
+		case strings.EqualFold(arg, scopeAll):
+			if !admin {
+				return sc, nil, fmt.Errorf("%s needs the %s permission", scopeAll, permissions.Admin)
+			}
+			everyone = true
+		case ok && value != "" && (strings.EqualFold(key, "h") || strings.EqualFold(key, "household")):
+			household, err := b.db.GetHousehold(value)
+			if err != nil {
+				if errors.Is(err, storage.ErrHouseholdNotFound) {
+					return sc, nil, fmt.Errorf("household %s not found", value)
+				}
+				return sc, nil, err
+			}
+			if !isMember(household, callerID) {
+				return sc, nil, fmt.Errorf("you are not a member of household %s", household.Name)
+			}
+			sc.userIDs = append(sc.userIDs, household.MemberIDs()...)
+			labels = append(labels, "household "+household.Name)
+		default:
+			rest = append(rest, arg)
+		}
+	}
+
+	switch {
+	case everyone:
+		return scope{label: "everyone"}, rest, nil
+	case len(sc.userIDs) == 0 && b.db.Ledger() == storage.DefaultLedger:
+		// Rows saved before the audit log have no owner; rather than
+		// disappear, they count as everyone's in the ledger they came from
+		return scope{userIDs: []string{callerID, ""}}, rest, nil
+	case len(sc.userIDs) == 0:
+		return scope{userIDs: []string{callerID}}, rest, nil
+	}
+	sc.label = strings.Join(labels, ", ")
+	return sc, rest, nil
+}
+
+func isMember(household *storage.Household, userID string) bool {
+	return slices.Contains(household.MemberIDs(), userID)
+}
+
+// sharesHousehold reports whether userID is the caller or a member of one of
+// the caller's households.
+func (b *Bot) sharesHousehold(callerID, userID string) (bool, error) {
+	if userID == callerID {
+		return true, nil
+	}
+	households, err := b.db.ListHouseholds(callerID)
+	if err != nil {
+		return false, err
+	}
+	for i := range households {
+		if isMember(&households[i], userID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// mayViewTransaction reports whether a transaction, live or in the trash, is
+// in a scope the caller could ask for: their own, a household member's, an
+// unowned row of the default ledger, or anyone's for admins. Transactions
+// that cannot be found are visible, so their absence is reported as usual.
+func (b *Bot) mayViewTransaction(transactionID, callerID string, admin bool) (bool, error) {
+	filter := storage.TransactionFilter{TransactionID: transactionID, Limit: 1}
+	txs, err := b.db.FindTransactions(filter)
+	if err == nil && len(txs) == 0 {
+		txs, err = b.db.GetDeletedTransactions(filter)
+	}
+	if err != nil {
+		return false, err
+	}
+	if admin || len(txs) == 0 {
+		return true, nil
+	}
+	if txs[0].UserID == "" {
+		return b.db.Ledger() == storage.DefaultLedger, nil
+	}
+	return b.sharesHousehold(callerID, txs[0].UserID)
+}
+
+func (b *Bot) handleHouseholdCommand(ctx *Context) {
+	fields := ctx.Args.Words
+	if len(fields) == 0 {
+		ctx.ReplyUsage()
+		return
+	}
+
+	switch action := strings.ToLower(fields[0]); {
+	case action == "list" && len(fields) == 1:
+		ctx.Reply(b.householdListText(ctx.UserID()))
+	case action == "create" && len(fields) == 2:
+		ctx.Reply(b.createHouseholdText(fields[1], ctx.UserID()))
+	case action == "join" && len(fields) == 2:
+		ctx.Reply(b.joinHouseholdText(fields[1], ctx.UserID()))
+	case action == "leave" && len(fields) == 2:
+		ctx.Reply(b.leaveHouseholdText(fields[1], ctx.UserID()))
+	case (action == "add" || action == "remove") && len(fields) >= 3:
+		var members []string
+		for _, field := range fields[2:] {
+			match := mentionPattern.FindStringSubmatch(field)
+			if match == nil {
+				ctx.ReplyUsage()
+				return
+			}
+			members = append(members, match[1])
+		}
+		ctx.Reply(b.householdMembersText(fields[1], members, action == "add", ctx.UserID()))
+	default:
+		ctx.ReplyUsage()
+	}
+}
+
+func (b *Bot) householdListText(userID string) string {
+	households, err := b.db.ListHouseholds(userID)
+	if err != nil {
+		return fmt.Sprintf("Failed to list households: %v", err)
+	}
+	invites, err := b.db.ListHouseholdInvites(userID)
+	if err != nil {
+		return fmt.Sprintf("Failed to list households: %v", err)
+	}
+	if len(households) == 0 && len(invites) == 0 {
+		return "You are not in any household. Create one with !household create <name>."
+	}
+
+	response := "🏠 **Your Households**\n\n"
+	for _, h := range households {
+		response += fmt.Sprintf("• **%s**: %s", h.Name, mentions(h.MemberIDs()))
+		if invited := h.InvitedIDs(); len(invited) > 0 {
+			response += fmt.Sprintf(" (invited: %s)", mentions(invited))
+		}
+		response += "\n"
+	}
+	if len(invites) > 0 {
+		response += "\n**Invitations**\n"
+		for _, h := range invites {
+			response += fmt.Sprintf("• **%s**: accept with !household join %s\n", h.Name, h.Name)
+		}
+	}
+	return response
+}
+
+func mentions(userIDs []string) string {
+	var mentions []string
+	for _, id := range userIDs {
+		mentions = append(mentions, "<@"+id+">")
+	}
+	return strings.Join(mentions, ", ")
+}
+
+func (b *Bot) createHouseholdText(name, userID string) string {
+	name = strings.ToLower(name)
+	if err := validCategoryName(name); err != nil {
+		return fmt.Sprintf("Invalid household name %s: use a single word of a-z, 0-9, - and _", name)
+	}
+
+	if _, err := b.db.CreateHousehold(name, userID); err != nil {
+		if errors.Is(err, storage.ErrDuplicateHousehold) {
+			return fmt.Sprintf("Household %s already exists", name)
+		}
+		return fmt.Sprintf("Failed to create household %s: %v", name, err)
+	}
+	return fmt.Sprintf("🏠 Created household %s. Invite members with !household add %s @member, then use h:%s with !summary, !search or !export.", name, name, name)
+}
+
+// householdMembersText invites or removes members. Only members may change a
+// household, and an invited user only becomes a member once they accept.
+func (b *Bot) householdMembersText(name string, members []string, add bool, callerID string) string {
+	household, err := b.db.GetHousehold(name)
+	if err != nil {
+		if errors.Is(err, storage.ErrHouseholdNotFound) {
+			return fmt.Sprintf("Household %s not found", name)
+		}
+		return fmt.Sprintf("Failed to get household %s: %v", name, err)
+	}
+	if !isMember(household, callerID) {
+		return fmt.Sprintf("Only members of %s can change it", household.Name)
+	}
+
+	for _, userID := range members {
+		if add {
+			err = b.db.InviteHouseholdMember(household.Name, userID)
+		} else {
+			err = b.db.RemoveHouseholdMember(household.Name, userID)
+		}
+		if err != nil {
+			return fmt.Sprintf("Failed to update household %s: %v", household.Name, err)
+		}
+	}
+
+	if add {
+		return fmt.Sprintf("🏠 Invited %s to %s. They join by running !household join %s.", mentions(members), household.Name, household.Name)
+	}
+	return fmt.Sprintf("🏠 Removed %s from %s", mentions(members), household.Name)
+}
+
+func (b *Bot) joinHouseholdText(name, userID string) string {
+	if err := b.db.AcceptHouseholdInvite(name, userID); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrHouseholdNotFound):
+			return fmt.Sprintf("Household %s not found", name)
+		case errors.Is(err, storage.ErrInviteNotFound):
+			return fmt.Sprintf("You were not invited to %s. Ask a member to run !household add %s @you.", name, name)
+		}
+		return fmt.Sprintf("Failed to join household %s: %v", name, err)
+	}
+	return fmt.Sprintf("🏠 You joined %s. Its members can now view your ledger with h:%s.", strings.ToLower(name), strings.ToLower(name))
+}
+
+// leaveHouseholdText removes the caller from a household, or declines their
+// invitation to it.
+func (b *Bot) leaveHouseholdText(name, userID string) string {
+	household, err := b.db.GetHousehold(name)
+	if err != nil {
+		if errors.Is(err, storage.ErrHouseholdNotFound) {
+			return fmt.Sprintf("Household %s not found", name)
+		}
+		return fmt.Sprintf("Failed to get household %s: %v", name, err)
+	}
+	if !isMember(household, userID) && !slices.Contains(household.InvitedIDs(), userID) {
+		return fmt.Sprintf("You are not in %s", household.Name)
+	}
+	if err := b.db.RemoveHouseholdMember(household.Name, userID); err != nil {
+		return fmt.Sprintf("Failed to leave household %s: %v", household.Name, err)
+	}
+	return fmt.Sprintf("🏠 You left %s", household.Name)
+}
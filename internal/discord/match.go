@@ -0,0 +1,106 @@
+package discord
+
+import (
+	"sort"
+	"strings"
+)
+
+// resolveCategory maps what a user typed to an active category. Exact names
+// win, then aliases, then the single closest name or alias within a small
+// edit distance. When several categories are equally close, or the input
+// only loosely resembles some, category is empty and suggestions lists the
+// candidates. Both are empty when nothing comes close.
+func (b *Bot) resolveCategory(input string) (category string, suggestions []string) {
+	input = strings.ToLower(strings.TrimSpace(input))
+
+	// Candidate spellings and the category each resolves to
+	spellings := make(map[string]string)
+	for _, c := range b.activeCategories() {
+		spellings[c.Name] = c.Name
+	}
+	if target, ok := spellings[input]; ok {
+		return target, nil
+	}
+
+	aliases, _ := b.db.ListCategoryAliases()
+	for alias, target := range aliases {
+		if _, active := spellings[target]; active {
+			spellings[alias] = target
+		}
+	}
+	if target, ok := spellings[input]; ok {
+		return target, nil
+	}
+
+	// Best distance per category, counting any spelling of it
+	best := make(map[string]int)
+	for spelling, target := range spellings {
+		d := levenshtein(input, spelling)
+		if len(input) >= 3 && strings.HasPrefix(spelling, input) {
+			d = 1
+		}
+		if current, ok := best[target]; !ok || d < current {
+			best[target] = d
+		}
+	}
+
+	limit := maxTypos(input)
+	for target, d := range best {
+		if d <= limit+1 {
+			suggestions = append(suggestions, target)
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if best[suggestions[i]] != best[suggestions[j]] {
+			return best[suggestions[i]] < best[suggestions[j]]
+		}
+		return suggestions[i] < suggestions[j]
+	})
+
+	// A unique closest match within the typo limit is taken as meant
+	if len(suggestions) > 0 && best[suggestions[0]] <= limit &&
+		(len(suggestions) == 1 || best[suggestions[1]] > best[suggestions[0]]) {
+		return suggestions[0], nil
+	}
+	return "", suggestions
+}
+
+// maxTypos is how many edits a word of this length may be off by and still
+// match without asking.
+func maxTypos(word string) int {
+	if len(word) <= 4 {
+		return 1
+	}
+	return 2
+}
+
+// didYouMean renders suggestions as "did you mean food or fuel?".
+func didYouMean(suggestions []string) string {
+	if len(suggestions) == 1 {
+		return "did you mean " + suggestions[0] + "?"
+	}
+	last := len(suggestions) - 1
+	return "did you mean " + strings.Join(suggestions[:last], ", ") + " or " + suggestions[last] + "?"
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,156 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NgigiN/wallet/internal/storage"
+)
+
+// eat is East Africa Time. M-PESA messages carry EAT wall-clock times, which
+// are stored as if they were UTC.
+var eat = time.FixedZone("EAT", 3*60*60)
+
+// mpesaNow returns the current time the way transactions store it: the EAT
+// wall clock in UTC, so it compares directly with Transaction.DateTime.
+func mpesaNow() time.Time {
+	wall := time.Now().In(eat)
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, time.UTC)
+}
+
+const monthLayout = "2006-01"
+
+// period is a date range a summary covers, with the range it is compared to.
+type period struct {
+	from, to time.Time // to is exclusive
+	label    string
+	// granularity is set for calendar periods; custom ranges have none and
+	// compare to the equally long range just before them.
+	granularity storage.Granularity
+}
+
+func (p period) filter(filter storage.TransactionFilter) storage.TransactionFilter {
+	filter.From, filter.To = p.from, p.to
+	return filter
+}
+
+// previous returns the period before p: the previous day, week, month or
+// year, or for a custom range the same number of days before it.
+func (p period) previous() period {
+	if p.granularity == "" {
+		days := int(p.to.Sub(p.from).Hours() / 24)
+		from := p.from.AddDate(0, 0, -days)
+		return customPeriod(from, p.from)
+	}
+
+	var from time.Time
+	switch p.granularity {
+	case storage.Week:
+		from = p.from.AddDate(0, 0, -7)
+	case storage.Month:
+		from = p.from.AddDate(0, -1, 0)
+	case storage.Year:
+		from = p.from.AddDate(-1, 0, 0)
+	default:
+		from = p.from.AddDate(0, 0, -1)
+	}
+	return calendarPeriod(from, p.granularity)
+}
+
+func calendarPeriod(t time.Time, g storage.Granularity) period {
+	from := storage.PeriodStart(t, g)
+	p := period{from: from, to: storage.PeriodEnd(from, g), granularity: g}
+	switch g {
+	case storage.Week:
+		p.label = "week of " + from.Format("Jan 2, 2006")
+	case storage.Month:
+		p.label = from.Format("January 2006")
+	case storage.Year:
+		p.label = from.Format("2006")
+	default:
+		p.label = from.Format("Mon Jan 2, 2006")
+	}
+	return p
+}
+
+// customPeriod covers the days from the start of from up to, but excluding,
+// to.
+func customPeriod(from, to time.Time) period {
+	last := to.AddDate(0, 0, -1)
+	label := from.Format("Jan 2") + " – " + last.Format("Jan 2, 2006")
+	if from.Year() != last.Year() {
+		label = from.Format("Jan 2, 2006") + " – " + last.Format("Jan 2, 2006")
+	}
+	return period{from: from, to: to, label: label}
+}
+
+// parsePeriod reads a summary period: today, week, month, year, a month as
+// YYYY-MM or an inclusive range as YYYY-MM-DD..YYYY-MM-DD. Words that are not
+// periods return ok false.
+func parsePeriod(arg string, now time.Time) (p period, ok bool, err error) {
+	switch strings.ToLower(arg) {
+	case "today":
+		return calendarPeriod(now, storage.Day), true, nil
+	case "week":
+		return calendarPeriod(now, storage.Week), true, nil
+	case "month":
+		return calendarPeriod(now, storage.Month), true, nil
+	case "year":
+		return calendarPeriod(now, storage.Year), true, nil
+	}
+
+	if month, err := time.Parse(monthLayout, arg); err == nil {
+		return calendarPeriod(month, storage.Month), true, nil
+	}
+
+	first, last, found := strings.Cut(arg, "..")
+	if !found {
+		return period{}, false, nil
+	}
+	from, err := time.Parse(dateLayout, first)
+	if err != nil {
+		return period{}, true, fmt.Errorf("invalid start date %q, use YYYY-MM-DD", first)
+	}
+	to, err := time.Parse(dateLayout, last)
+	if err != nil {
+		return period{}, true, fmt.Errorf("invalid end date %q, use YYYY-MM-DD", last)
+	}
+	if to.Before(from) {
+		return period{}, true, fmt.Errorf("%s is before %s", last, first)
+	}
+	return customPeriod(from, to.AddDate(0, 0, 1)), true, nil
+}
+
+// periodAdjective describes how often something recurs, e.g. "monthly".
+func periodAdjective(g storage.Granularity) string {
+	switch g {
+	case storage.Day:
+		return "daily"
+	case storage.Week:
+		return "weekly"
+	case storage.Year:
+		return "yearly"
+	default:
+		return "monthly"
+	}
+}
+
+// changeText compares an amount with the previous period's, e.g.
+// "▲ 12% vs Ksh58.00".
+func changeText(current, previous float64) string {
+	switch {
+	case previous == 0 && current == 0:
+		return "no change"
+	case previous == 0:
+		return "new"
+	case current == previous:
+		return fmt.Sprintf("= vs Ksh%.2f", previous)
+	}
+	change := (current - previous) / previous * 100
+	arrow := "▲"
+	if change < 0 {
+		arrow, change = "▼", -change
+	}
+	return fmt.Sprintf("%s %.0f%% vs Ksh%.2f", arrow, change, previous)
+}
@@ -0,0 +1,71 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NgigiN/wallet/internal/permissions"
+	"github.com/NgigiN/wallet/internal/storage"
+	"github.com/bwmarrin/discordgo"
+)
+
+// interactionCapability returns what a component or modal interaction
+// needs; slash commands are checked by the router like the prefix commands
+// they mirror. The category picker and reason modal finish logging a pending
+// transaction, while turning the page of a summary only reads.
+func interactionCapability(i *discordgo.InteractionCreate) permissions.Capability {
+	if i.Type == discordgo.InteractionMessageComponent && strings.HasPrefix(i.MessageComponentData().CustomID, summaryPageID) {
+		return permissions.View
+	}
+	return permissions.Log
+}
+
+// allowed checks the permissions of the channel. Private ledgers belong to
+// their only user, so direct messages are always allowed.
+func (b *Bot) allowed(userID string, member *discordgo.Member, capability permissions.Capability) bool {
+	if b.isDM() {
+		return true
+	}
+	var roles []string
+	if member != nil {
+		roles = member.Roles
+	}
+	return b.permissions.Allowed(b.channelID, userID, roles, capability)
+}
+
+func deniedText(capability permissions.Capability) string {
+	return fmt.Sprintf("🔒 You need the %s permission to do that here.", capability)
+}
+
+// refusal returns why a user may not change a transaction with capability,
+// or "" when they may. Members may change what they logged themselves and
+// rows nobody owns; changing what someone else logged needs capability
+// granted by a policy, because an open channel grants everyone everything.
+func (b *Bot) refusal(tx storage.Transaction, userID string, member *discordgo.Member, capability permissions.Capability) string {
+	if tx.UserID == "" || tx.UserID == userID || b.isDM() {
+		return ""
+	}
+	if !b.permissions.Open(b.channelID) && b.allowed(userID, member, capability) {
+		return ""
+	}
+	return fmt.Sprintf("🔒 %s was logged by <@%s>; changing it needs the %s permission.", tx.TransactionID, tx.UserID, capability)
+}
+
+// ownerRefusal looks up a live transaction, or one in the trash when deleted
+// is set, and returns its refusal. Transactions that cannot be found are
+// left for the command to report.
+func (b *Bot) ownerRefusal(transactionID string, deleted bool, userID string, member *discordgo.Member, capability permissions.Capability) string {
+	filter := storage.TransactionFilter{TransactionID: transactionID, Limit: 1}
+	find := b.db.FindTransactions
+	if deleted {
+		find = b.db.GetDeletedTransactions
+	}
+	txs, err := find(filter)
+	if err != nil {
+		return fmt.Sprintf("Failed to look up %s: %v", transactionID, err)
+	}
+	if len(txs) == 0 {
+		return ""
+	}
+	return b.refusal(txs[0], userID, member, capability)
+}
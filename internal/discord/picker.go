@@ -0,0 +1,244 @@
+package discord
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/NgigiN/wallet/internal/permissions"
+	"github.com/NgigiN/wallet/internal/storage"
+	"github.com/bwmarrin/discordgo"
+)
+
+// uncategorized is the category of a transaction saved without a Category
+// line. It stays pending until someone picks a category from the buttons.
+const uncategorized = "uncategorized"
+
+// Custom ID prefixes of the picker components; the transaction ID follows the colon.
+const (
+	// pickCategoryID is followed by "<transaction ID>:<menu number>".
+	pickCategoryID = "pick_category:"
+	addReasonID    = "add_reason:"
+	reasonModalID  = "reason_modal:"
+	// useCategoryID is followed by "<transaction ID>:<category>".
+	useCategoryID = "use_category:"
+)
+
+// maxPickerMenus is how many category menus the picker shows. A message
+// holds five rows, and the last is kept for the buttons.
+const maxPickerMenus = 4
+
+// minConfidence is how sure the classifier must be before the picker
+// proposes its category.
+const minConfidence = 0.5
+
+// sendCategoryPicker asks the channel to categorise a pending transaction.
+// Suggestions are the close matches of a category that could not be resolved.
+func (b *Bot) sendCategoryPicker(r Replier, tx *storage.Transaction, suggestions []string) {
+	content := fmt.Sprintf("🏷️ Saved %s: Ksh%.2f to %s without a category. Pick one:", tx.TransactionID, tx.Amount, tx.Recipient)
+	if len(suggestions) > 0 {
+		content = fmt.Sprintf("🤔 Saved %s: Ksh%.2f to %s as pending, %s Pick one:", tx.TransactionID, tx.Amount, tx.Recipient, didYouMean(suggestions))
+	}
+	components := b.pickerComponents(tx.TransactionID, true)
+	if len(b.activeCategories()) > maxPickerMenus*maxChoices {
+		content += fmt.Sprintf("\nNot listed? Use !edit %s c: <category>", tx.TransactionID)
+	}
+
+	// Offer the learned guess as a one-click button next to "Add reason"
+	if category, confidence := b.classifier.Predict(*tx); category != "" && confidence >= minConfidence && b.isValidCategory(category) {
+		content += fmt.Sprintf("\n💡 Looks like **%s** (%.0f%% sure)", category, confidence*100)
+		buttons := components[len(components)-1].(discordgo.ActionsRow)
+		buttons.Components = append([]discordgo.MessageComponent{
+			discordgo.Button{Label: "Use " + category, Style: discordgo.PrimaryButton, CustomID: useCategoryID + tx.TransactionID + ":" + category},
+		}, buttons.Components...)
+		components[len(components)-1] = buttons
+	}
+
+	r.ReplyComplex(&discordgo.MessageSend{
+		Content:    content,
+		Components: components,
+	})
+}
+
+// pickerComponents returns the category menus, when withCategory is set,
+// followed by a row of buttons. A menu holds at most maxChoices categories,
+// so longer lists are sorted and spread over up to maxPickerMenus menus, each
+// showing the range it covers.
+func (b *Bot) pickerComponents(transactionID string, withCategory bool) []discordgo.MessageComponent {
+	var rows []discordgo.MessageComponent
+	if withCategory {
+		categories := b.activeCategories()
+		if len(categories) > maxChoices {
+			sort.Slice(categories, func(i, j int) bool { return categories[i].Name < categories[j].Name })
+		}
+		for start := 0; start < len(categories) && len(rows) < maxPickerMenus; start += maxChoices {
+			page := categories[start:min(start+maxChoices, len(categories))]
+			menu := discordgo.SelectMenu{
+				CustomID:    fmt.Sprintf("%s%s:%d", pickCategoryID, transactionID, len(rows)),
+				Placeholder: "Choose a category",
+			}
+			if len(categories) > maxChoices {
+				menu.Placeholder = fmt.Sprintf("Choose a category (%s to %s)", strings.Title(page[0].Name), strings.Title(page[len(page)-1].Name))
+			}
+			for _, category := range page {
+				menu.Options = append(menu.Options, discordgo.SelectMenuOption{
+					Label: strings.Title(category.Name),
+					Value: category.Name,
+				})
+			}
+			rows = append(rows, discordgo.ActionsRow{Components: []discordgo.MessageComponent{menu}})
+		}
+	}
+	rows = append(rows, discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+		discordgo.Button{Label: "Add reason", Style: discordgo.SecondaryButton, CustomID: addReasonID + transactionID},
+	}})
+	return rows
+}
+
+func (b *Bot) handleComponent(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.MessageComponentData()
+	origin := storage.Origin{UserID: interactionUser(i).ID, Source: "picker"}
+
+	switch {
+	case strings.HasPrefix(data.CustomID, summaryPageID):
+		b.handleSummaryPage(s, i, data.CustomID)
+
+	case strings.HasPrefix(data.CustomID, pickCategoryID):
+		// Pickers sent before the menus were numbered have no number
+		transactionID, _, _ := strings.Cut(strings.TrimPrefix(data.CustomID, pickCategoryID), ":")
+		if !b.mayPick(s, i, transactionID) {
+			return
+		}
+		if len(data.Values) != 1 || !b.isValidCategory(data.Values[0]) {
+			respondEphemeral(s, i, "Pick one of the listed categories.")
+			return
+		}
+		b.pickCategory(s, i, transactionID, data.Values[0], origin)
+
+	case strings.HasPrefix(data.CustomID, useCategoryID):
+		transactionID, category, _ := strings.Cut(strings.TrimPrefix(data.CustomID, useCategoryID), ":")
+		if !b.mayPick(s, i, transactionID) {
+			return
+		}
+		if !b.isValidCategory(category) {
+			respondEphemeral(s, i, fmt.Sprintf("Category %s is no longer available, pick another.", category))
+			return
+		}
+		b.pickCategory(s, i, transactionID, category, origin)
+
+	case strings.HasPrefix(data.CustomID, addReasonID):
+		transactionID := strings.TrimPrefix(data.CustomID, addReasonID)
+		if !b.mayPick(s, i, transactionID) {
+			return
+		}
+		s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseModal,
+			Data: &discordgo.InteractionResponseData{
+				CustomID: reasonModalID + transactionID,
+				Title:    "Reason for " + transactionID,
+				Components: []discordgo.MessageComponent{
+					discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:  "reason",
+							Label:     "Reason",
+							Style:     discordgo.TextInputShort,
+							Required:  true,
+							MaxLength: 200,
+						},
+					}},
+				},
+			},
+		})
+	}
+}
+
+// mayPick reports whether the user of an interaction may use the picker of a
+// transaction, and tells them when not. Anyone with the log permission may
+// complete their own transactions; those of others need the edit permission.
+func (b *Bot) mayPick(s *discordgo.Session, i *discordgo.InteractionCreate, transactionID string) bool {
+	if text := b.ownerRefusal(transactionID, false, interactionUser(i).ID, i.Member, permissions.Edit); text != "" {
+		respondEphemeral(s, i, text)
+		return false
+	}
+	return true
+}
+
+// pickCategory files a pending transaction from the picker and updates the
+// picker message.
+func (b *Bot) pickCategory(s *discordgo.Session, i *discordgo.InteractionCreate, transactionID, category string, origin storage.Origin) {
+	tx, err := b.db.UpdateTransaction(transactionID, storage.TransactionUpdate{Category: &category}, origin)
+	if err != nil {
+		respondEphemeral(s, i, fmt.Sprintf("Failed to categorize %s: %v", transactionID, err))
+		return
+	}
+	b.classifier.Observe(*tx)
+
+	// Keep offering a reason until one is set
+	var components []discordgo.MessageComponent
+	if tx.Reason == "" {
+		components = b.pickerComponents(transactionID, false)
+	}
+	updateMessage(s, i, trackedText(tx), components)
+	b.checkBudgets(channelReplier{session: s, channelID: i.ChannelID}, []storage.Transaction{*tx})
+}
+
+func (b *Bot) handleModalSubmit(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.ModalSubmitData()
+	if !strings.HasPrefix(data.CustomID, reasonModalID) {
+		return
+	}
+	transactionID := strings.TrimPrefix(data.CustomID, reasonModalID)
+	if !b.mayPick(s, i, transactionID) {
+		return
+	}
+
+	var reason string
+	for _, row := range data.Components {
+		actions, ok := row.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, component := range actions.Components {
+			if input, ok := component.(*discordgo.TextInput); ok && input.CustomID == "reason" {
+				reason = strings.TrimSpace(input.Value)
+			}
+		}
+	}
+
+	origin := storage.Origin{UserID: interactionUser(i).ID, Source: "picker"}
+	tx, err := b.db.UpdateTransaction(transactionID, storage.TransactionUpdate{Reason: &reason}, origin)
+	if err != nil {
+		respondEphemeral(s, i, fmt.Sprintf("Failed to set reason for %s: %v", transactionID, err))
+		return
+	}
+
+	// Still pending: keep the category menus on the message
+	var components []discordgo.MessageComponent
+	if tx.Category == uncategorized {
+		components = b.pickerComponents(transactionID, true)
+		components = components[:len(components)-1]
+	}
+	updateMessage(s, i, trackedText(tx), components)
+}
+
+// trackedText is the confirmation shown once a transaction is saved.
+func trackedText(tx *storage.Transaction) string {
+	response := fmt.Sprintf("Tracked %s: Ksh%.2f to %s in %s", tx.TransactionID, tx.Amount, tx.Recipient, tx.Category)
+	if tx.Reason != "" {
+		response += fmt.Sprintf(" (%s)", tx.Reason)
+	}
+	return response
+}
+
+// updateMessage edits the message the component belongs to. A nil component
+// list removes the buttons.
+func updateMessage(s *discordgo.Session, i *discordgo.InteractionCreate, content string, components []discordgo.MessageComponent) {
+	if components == nil {
+		// Discord only clears components when sent an empty list, not null
+		components = []discordgo.MessageComponent{}
+	}
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{Content: content, Components: components},
+	})
+}
@@ -0,0 +1,271 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NgigiN/wallet/internal/storage"
+)
+
+// defaultReminderDays is how many quiet days !remind on waits for when no
+// number is given.
+const defaultReminderDays = 3
+
+// reminderHour is the EAT hour from which nudges go out, so a day only
+// counts as quiet once most of it has passed.
+const reminderHour = 18
+
+// balanceTolerance absorbs rounding when checking that balances add up.
+const balanceTolerance = 0.01
+
+func (b *Bot) handleRemindCommand(ctx *Context) {
+	if len(ctx.Args.Words) == 0 {
+		ctx.ReplyUsage()
+		return
+	}
+	args := ctx.Args.Words[1:]
+
+	switch strings.ToLower(ctx.Args.Words[0]) {
+	case "status":
+		ctx.Reply(b.reminderStatusText(ctx.UserID()))
+	case "on":
+		if len(args) > 2 {
+			ctx.ReplyUsage()
+			return
+		}
+		ctx.Reply(b.setReminderText(args, ctx.UserID()))
+	case "off":
+		if err := b.db.DeleteReminder(ctx.UserID()); err != nil {
+			if errors.Is(err, storage.ErrReminderNotFound) {
+				ctx.Reply("You have no reminders here.")
+				return
+			}
+			ctx.Reply(fmt.Sprintf("Failed to turn reminders off: %v", err))
+			return
+		}
+		ctx.Reply("🔕 Reminders are off.")
+	default:
+		ctx.ReplyUsage()
+	}
+}
+
+// setReminderText handles "[days] [dm|channel]", in any order.
+func (b *Bot) setReminderText(args []string, userID string) string {
+	reminder := storage.Reminder{UserID: userID, ChannelID: b.channelID, Days: defaultReminderDays, DM: b.isDM(), Since: b.now()}
+	for _, arg := range args {
+		switch strings.ToLower(arg) {
+		case "dm":
+			reminder.DM = true
+		case "channel":
+			if b.isDM() {
+				return "Reminders of a private ledger always come by direct message."
+			}
+			reminder.DM = false
+		default:
+			days, err := strconv.Atoi(arg)
+			if err != nil || days < 1 {
+				return fmt.Sprintf("Invalid number of days %q", arg)
+			}
+			reminder.Days = days
+		}
+	}
+
+	if err := b.db.SetReminder(&reminder); err != nil {
+		return fmt.Sprintf("Failed to turn reminders on: %v", err)
+	}
+	return "⏰ Reminders are on. " + reminderText(reminder)
+}
+
+func (b *Bot) reminderStatusText(userID string) string {
+	reminder, err := b.db.GetReminder(userID)
+	if errors.Is(err, storage.ErrReminderNotFound) {
+		return "Reminders are off. Turn them on with !remind on."
+	}
+	if err != nil {
+		return fmt.Sprintf("Failed to look up your reminders: %v", err)
+	}
+	return "⏰ " + reminderText(*reminder)
+}
+
+// reminderText describes when and where a user is nudged.
+func reminderText(reminder storage.Reminder) string {
+	where := "in this channel"
+	if reminder.DM {
+		where = "by direct message"
+	}
+	days := "a day"
+	if reminder.Days > 1 {
+		days = fmt.Sprintf("%d days", reminder.Days)
+	}
+	return fmt.Sprintf("You will be nudged %s when you log nothing for %s, or when your balances show missed messages.", where, days)
+}
+
+// sendDueReminders nudges every user whose reminder is due, through the bot
+// of the channel they set it up in. Private ledgers are opened as needed,
+// since nobody may have written to them since a restart.
+func (b *Bot) sendDueReminders() {
+	reminders, err := b.db.ListReminders()
+	if err != nil {
+		log.Printf("failed to load reminders: %v", err)
+		return
+	}
+	for _, reminder := range reminders {
+		var lb *Bot
+		if strings.HasPrefix(reminder.Ledger, dmLedgerPrefix) {
+			lb = b.botFor(reminder.ChannelID, "", strings.TrimPrefix(reminder.Ledger, dmLedgerPrefix))
+		} else {
+			lb = b.channels.get(reminder.ChannelID)
+		}
+		// The channel may have been removed or moved to another ledger
+		if lb == nil || lb.db.Ledger() != reminder.Ledger {
+			continue
+		}
+		lb.remind(reminder)
+	}
+}
+
+// remind sends the nudges that are due for a reminder: one when the user has
+// been quiet for its days, again after every further stretch as long, and
+// one for balance gaps not reported yet. Each is recorded as a scheduled
+// post, so it goes out once.
+func (b *Bot) remind(reminder storage.Reminder) {
+	now := b.now()
+	if now.Hour() < reminderHour {
+		return
+	}
+	latest, err := b.db.FindTransactions(storage.TransactionFilter{UserIDs: []string{reminder.UserID}, Limit: 1})
+	if err != nil {
+		log.Printf("failed to check the reminder of %s in ledger %s: %v", reminder.UserID, b.db.Ledger(), err)
+		return
+	}
+	var last *storage.Transaction
+	if len(latest) > 0 {
+		last = &latest[0]
+	}
+
+	quietKey := "reminder:quiet:" + reminder.UserID
+	if covered, text := quietNudge(reminder, last, now); text != "" {
+		b.sendNudge(reminder, quietKey, covered, text, last)
+	}
+
+	gapKey := "reminder:gap:" + reminder.UserID
+	reported, err := b.db.LastScheduledPost(gapKey)
+	if err != nil {
+		log.Printf("failed to check the reminder of %s in ledger %s: %v", reminder.UserID, b.db.Ledger(), err)
+		return
+	}
+	from := reminder.Since
+	if reported.After(from) {
+		from = reported
+	}
+	txs, err := b.db.FindTransactions(storage.TransactionFilter{UserIDs: []string{reminder.UserID}, From: from})
+	if err != nil {
+		log.Printf("failed to check the balances of %s in ledger %s: %v", reminder.UserID, b.db.Ledger(), err)
+		return
+	}
+	gaps := balanceGaps(txs)
+	if len(gaps) == 0 {
+		return
+	}
+	lines := []string{"Your balance fell by more than your logged spending:"}
+	for _, gap := range gaps {
+		lines = append(lines, gap.String())
+	}
+	lines = append(lines, "Forward the missing messages to fill the gaps.")
+	b.sendNudge(reminder, gapKey, gaps[len(gaps)-1].after.DateTime, strings.Join(lines, "\n"), last)
+}
+
+// quietNudge returns the nudge for a user who logged nothing since the day
+// of their last transaction, or of opting in, for reminder.Days days. The
+// nudge covers the day the latest stretch of that many quiet days ended.
+func quietNudge(reminder storage.Reminder, last *storage.Transaction, now time.Time) (time.Time, string) {
+	active := reminder.Since
+	if last != nil && last.DateTime.After(active) {
+		active = last.DateTime
+	}
+	activeDay := storage.PeriodStart(active, storage.Day)
+	quiet := int(storage.PeriodStart(now, storage.Day).Sub(activeDay).Hours() / 24)
+	if quiet < reminder.Days {
+		return time.Time{}, ""
+	}
+	covered := activeDay.AddDate(0, 0, quiet/reminder.Days*reminder.Days)
+	return covered, fmt.Sprintf("Nothing has been logged for %d days. Forward any M-PESA messages you missed.", quiet)
+}
+
+// sendNudge posts text unless a nudge with the same key already covered the
+// same time, then records it. The user is mentioned in a channel; in a
+// direct message there is nobody else to address.
+func (b *Bot) sendNudge(reminder storage.Reminder, key string, covered time.Time, text string, last *storage.Transaction) {
+	sent, err := b.db.LastScheduledPost(key)
+	if err != nil {
+		log.Printf("failed to check the reminder of %s in ledger %s: %v", reminder.UserID, b.db.Ledger(), err)
+		return
+	}
+	if !sent.Before(covered) {
+		return
+	}
+
+	if last != nil {
+		text += fmt.Sprintf("\nLast known balance: Ksh%.2f on %s.", last.Balance, last.DateTime.Format("Jan 2 at 3:04 PM"))
+	}
+	channelID := b.channelID
+	if reminder.DM && !b.isDM() {
+		channel, err := b.session.UserChannelCreate(reminder.UserID)
+		if err != nil {
+			log.Printf("failed to open a direct message with %s: %v", reminder.UserID, err)
+			return
+		}
+		channelID = channel.ID
+	} else if !b.isDM() {
+		text = "<@" + reminder.UserID + "> " + text
+	}
+	if _, err := b.session.ChannelMessageSend(channelID, "👋 "+text); err != nil {
+		log.Printf("failed to remind %s: %v", reminder.UserID, err)
+		return
+	}
+	if err := b.db.RecordScheduledPost(key, covered); err != nil {
+		log.Printf("failed to record the reminder of %s in ledger %s: %v", reminder.UserID, b.db.Ledger(), err)
+	}
+}
+
+// balanceGap is a place where the balance after a transaction is lower than
+// the balance before it, less its amount and cost, would allow.
+type balanceGap struct {
+	before, after storage.Transaction
+	missing       float64
+}
+
+func (g balanceGap) String() string {
+	return fmt.Sprintf("• Ksh%.2f after %s on %s, down to Ksh%.2f at %s on %s: Ksh%.2f unaccounted for",
+		g.before.Balance, g.before.TransactionID, g.before.DateTime.Format("Jan 2"),
+		g.after.Balance, g.after.TransactionID, g.after.DateTime.Format("Jan 2"), g.missing)
+}
+
+// balanceGaps walks the balances of one M-PESA account in time order. Money
+// received raises the balance and is never logged, so only a balance that
+// fell further than the logged sends and payments explain is a gap.
+func balanceGaps(txs []storage.Transaction) []balanceGap {
+	sorted := append([]storage.Transaction(nil), txs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].DateTime.Equal(sorted[j].DateTime) {
+			return sorted[i].DateTime.Before(sorted[j].DateTime)
+		}
+		// M-PESA times stop at the minute; within one, spending lowers the balance
+		return sorted[i].Balance > sorted[j].Balance
+	})
+
+	var gaps []balanceGap
+	for i := 1; i < len(sorted); i++ {
+		before, after := sorted[i-1], sorted[i]
+		expected := before.Balance - after.Amount - after.Cost
+		if missing := expected - after.Balance; missing > balanceTolerance {
+			gaps = append(gaps, balanceGap{before: before, after: after, missing: missing})
+		}
+	}
+	return gaps
+}
@@ -0,0 +1,175 @@
+package discord
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/NgigiN/wallet/internal/permissions"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Discord rejects messages and embeds over these sizes, so long responses
+// are split before they are sent.
+const (
+	maxMessageLength    = 2000
+	maxEmbedFields      = 25
+	maxFieldValueLength = 1024
+	maxEmbedLength      = 6000
+	maxCustomIDLength   = 100
+)
+
+// embedColor is the M-PESA green used on every embed.
+const embedColor = 0x43B02A
+
+// summaryPageSize is how many transactions one page of a category summary
+// lists.
+const summaryPageSize = 10
+
+// summaryPageID prefixes the Previous/Next buttons of a category summary. It
+// is followed by "<page>:<requester>:<summary arguments>", so a page can be
+// rebuilt without keeping state between clicks.
+const summaryPageID = "summary_page:"
+
+// splitText cuts content into chunks of at most limit bytes, preferring to
+// break at line ends so list items stay whole.
+func splitText(content string, limit int) []string {
+	var chunks []string
+	for len(content) > limit {
+		cut := strings.LastIndex(content[:limit], "\n")
+		if cut <= 0 {
+			// One long line: cut at a rune boundary instead
+			cut = limit
+			for cut > 0 && !utf8.RuneStart(content[cut]) {
+				cut--
+			}
+		}
+		chunks = append(chunks, content[:cut])
+		content = strings.TrimPrefix(content[cut:], "\n")
+	}
+	return append(chunks, content)
+}
+
+// addListField adds lines as a field, continuing in further fields named
+// "<name> (cont.)" when they do not fit in one.
+func addListField(embed *discordgo.MessageEmbed, name string, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	for i, value := range splitText(strings.Join(lines, "\n"), maxFieldValueLength) {
+		fieldName := name
+		if i > 0 {
+			fieldName = name + " (cont.)"
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: fieldName, Value: value})
+	}
+}
+
+func embedLength(embed *discordgo.MessageEmbed) int {
+	length := len(embed.Title) + len(embed.Description)
+	if embed.Footer != nil {
+		length += len(embed.Footer.Text)
+	}
+	for _, field := range embed.Fields {
+		length += len(field.Name) + len(field.Value)
+	}
+	return length
+}
+
+// splitEmbed breaks an embed with too many or too long fields into several,
+// titled as continuations. The footer goes on the last one.
+func splitEmbed(embed *discordgo.MessageEmbed) []*discordgo.MessageEmbed {
+	if len(embed.Fields) <= maxEmbedFields && embedLength(embed) <= maxEmbedLength {
+		return []*discordgo.MessageEmbed{embed}
+	}
+
+	footer := embed.Footer
+	current := &discordgo.MessageEmbed{Title: embed.Title, Description: embed.Description, Color: embed.Color}
+	embeds := []*discordgo.MessageEmbed{current}
+	for _, field := range embed.Fields {
+		if len(current.Fields) == maxEmbedFields || embedLength(current)+len(field.Name)+len(field.Value) > maxEmbedLength-200 {
+			current = &discordgo.MessageEmbed{Title: embed.Title + " (continued)", Color: embed.Color}
+			embeds = append(embeds, current)
+		}
+		current.Fields = append(current.Fields, field)
+	}
+	current.Footer = footer
+	return embeds
+}
+
+// replyEmbed sends an embed, split over several messages if it is too big
+// for one. Components are attached to the last message.
+func replyEmbed(r Replier, embed *discordgo.MessageEmbed, components []discordgo.MessageComponent) {
+	embeds := splitEmbed(embed)
+	for i, part := range embeds {
+		message := &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{part}}
+		if i == len(embeds)-1 {
+			message.Components = components
+		}
+		r.ReplyComplex(message)
+	}
+}
+
+// pagerComponents are the Previous/Next buttons of page (counted from 0) of
+// pages. It returns nil when there is only one page, or when the state does
+// not fit in a custom ID, e.g. a scope of many members.
+func pagerComponents(state string, page, pages int) []discordgo.MessageComponent {
+	if pages <= 1 {
+		return nil
+	}
+	customID := func(page int) string {
+		return summaryPageID + strconv.Itoa(page) + ":" + state
+	}
+	if len(customID(pages)) > maxCustomIDLength {
+		return nil
+	}
+	return []discordgo.MessageComponent{discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+		discordgo.Button{Label: "◀ Previous", Style: discordgo.SecondaryButton, CustomID: customID(page - 1), Disabled: page == 0},
+		discordgo.Button{Label: "Next ▶", Style: discordgo.SecondaryButton, CustomID: customID(page + 1), Disabled: page >= pages-1},
+	}}}
+}
+
+// handleSummaryPage turns the page of a category summary. The arguments are
+// parsed again as the member who asked for the summary, so paging shows the
+// same ledgers to whoever clicks; only "all" depends on the clicker being an
+// admin.
+func (b *Bot) handleSummaryPage(s *discordgo.Session, i *discordgo.InteractionCreate, customID string) {
+	parts := strings.SplitN(strings.TrimPrefix(customID, summaryPageID), ":", 3)
+	if len(parts) != 3 {
+		respondEphemeral(s, i, "This summary can no longer be paged; run !summary again.")
+		return
+	}
+	page, err := strconv.Atoi(parts[0])
+	if err != nil {
+		respondEphemeral(s, i, "This summary can no longer be paged; run !summary again.")
+		return
+	}
+
+	query, err := b.parseSummaryArgs(strings.Fields(parts[2]), parts[1], b.allowed(interactionUser(i).ID, i.Member, permissions.Admin))
+	if err != nil {
+		respondEphemeral(s, i, fmt.Sprintf("Invalid summary: %v", err))
+		return
+	}
+	embed, components, text := b.categorySummaryPage(query, page)
+	if embed == nil {
+		respondEphemeral(s, i, text)
+		return
+	}
+	if components == nil {
+		// Discord only clears components when sent an empty list, not null
+		components = []discordgo.MessageComponent{}
+	}
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{embed},
+			Components: components,
+		},
+	})
+}
+
+// pageState is what a pager button remembers of a category summary.
+func pageState(requester string, args []string) string {
+	return requester + ":" + strings.Join(args, " ")
+}
@@ -0,0 +1,414 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/NgigiN/wallet/internal/permissions"
+	"github.com/NgigiN/wallet/internal/storage"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Replier sends the bot's answers. Handlers reply through it instead of the
+// session, so the same code can answer a channel message or an interaction.
+type Replier interface {
+	Reply(content string)
+	ReplyComplex(message *discordgo.MessageSend)
+}
+
+// channelReplier posts to a channel.
+type channelReplier struct {
+	session   *discordgo.Session
+	channelID string
+}
+
+// Reply posts content, over several messages if it is too long for one.
+func (r channelReplier) Reply(content string) {
+	for _, chunk := range splitText(content, maxMessageLength) {
+		r.ReplyComplex(&discordgo.MessageSend{Content: chunk})
+	}
+}
+
+func (r channelReplier) ReplyComplex(message *discordgo.MessageSend) {
+	if _, err := r.session.ChannelMessageSendComplex(r.channelID, message); err != nil {
+		log.Printf("failed to reply in channel %s: %v", r.channelID, err)
+	}
+}
+
+// interactionReplier answers a slash command or component interaction. An
+// interaction takes one response; further replies are sent as followups.
+type interactionReplier struct {
+	session     *discordgo.Session
+	interaction *discordgo.InteractionCreate
+	responded   bool
+}
+
+func (r *interactionReplier) Reply(content string) {
+	for _, chunk := range splitText(content, maxMessageLength) {
+		r.ReplyComplex(&discordgo.MessageSend{Content: chunk})
+	}
+}
+
+func (r *interactionReplier) ReplyComplex(message *discordgo.MessageSend) {
+	if r.responded {
+		_, err := r.session.FollowupMessageCreate(r.interaction.Interaction, true, &discordgo.WebhookParams{
+			Content:    message.Content,
+			Embeds:     message.Embeds,
+			Components: message.Components,
+			Files:      message.Files,
+		})
+		if err != nil {
+			log.Printf("failed to follow up on interaction: %v", err)
+		}
+		return
+	}
+
+	r.responded = true
+	err := r.session.InteractionRespond(r.interaction.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content:    message.Content,
+			Embeds:     message.Embeds,
+			Components: message.Components,
+			Files:      message.Files,
+		},
+	})
+	if err != nil {
+		log.Printf("failed to respond to interaction: %v", err)
+	}
+}
+
+// ParamType says how a command parameter is read from the message.
+type ParamType int
+
+const (
+	// WordParam is a single word.
+	WordParam ParamType = iota
+	// TransactionIDParam is a single M-PESA transaction ID, uppercased.
+	TransactionIDParam
+	// TextParam is the rest of the message, line breaks included.
+	TextParam
+)
+
+var transactionIDPattern = regexp.MustCompile(`^[A-Z0-9]{6,16}$`)
+
+// Param declares a positional command parameter.
+type Param struct {
+	Name string
+	Type ParamType
+	// Optional parameters may be left out, but only at the end.
+	Optional bool
+	// Variadic takes every remaining word; it must be the last parameter.
+	Variadic bool
+}
+
+// Args holds the arguments of a command.
+type Args struct {
+	// Words are the whitespace-separated arguments after the command name.
+	Words []string
+	// Text is everything after the command name, with line breaks kept.
+	Text   string
+	values map[string][]string
+}
+
+// String returns the value of a parameter, or "" if it was left out.
+func (a Args) String(name string) string {
+	if values := a.values[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// List returns every value of a variadic parameter.
+func (a Args) List(name string) []string {
+	return a.values[name]
+}
+
+// HandlerFunc runs a command once its arguments are parsed.
+type HandlerFunc func(ctx *Context)
+
+// Middleware wraps a handler, e.g. to check permissions before it runs.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Command is a prefix command such as !summary.
+type Command struct {
+	// Name is the command without the "!" prefix.
+	Name string
+	// Usage lists the accepted argument forms, one per line, e.g.
+	// "<TransactionID>".
+	Usage       []string
+	Description string
+	// Examples are full invocations, optionally followed by " - " and what
+	// they do.
+	Examples []string
+	// Params are parsed and checked before the command runs. Without any,
+	// the handler reads Args.Words and Args.Text itself.
+	Params []Param
+	// Capability is needed to run the command; empty means anyone may.
+	// Actions lowers it for some first arguments, e.g. "list" only needing
+	// view.
+	Capability permissions.Capability
+	Actions    map[string]permissions.Capability
+	Middleware []Middleware
+	Run        func(b *Bot, ctx *Context)
+}
+
+// capability returns what running the command with these arguments needs.
+func (c *Command) capability(args Args) permissions.Capability {
+	if len(args.Words) > 0 {
+		if capability, ok := c.Actions[strings.ToLower(args.Words[0])]; ok {
+			return capability
+		}
+	}
+	return c.Capability
+}
+
+// UsageText renders the usage and examples, as shown on invalid arguments.
+func (c *Command) UsageText() string {
+	var sb strings.Builder
+	switch len(c.Usage) {
+	case 0:
+		fmt.Fprintf(&sb, "Usage: !%s", c.Name)
+	case 1:
+		fmt.Fprintf(&sb, "Usage: !%s %s", c.Name, c.Usage[0])
+	default:
+		sb.WriteString("Usage:")
+		for _, usage := range c.Usage {
+			fmt.Fprintf(&sb, "\n!%s %s", c.Name, usage)
+		}
+	}
+	switch len(c.Examples) {
+	case 0:
+	case 1:
+		sb.WriteString("\nExample: " + c.Examples[0])
+	default:
+		sb.WriteString("\nExamples:")
+		for _, example := range c.Examples {
+			sb.WriteString("\n" + example)
+		}
+	}
+	return sb.String()
+}
+
+// parseArgs splits the text after the command name into its parameters.
+func (c *Command) parseArgs(text string) (Args, error) {
+	args := Args{Words: strings.Fields(text), Text: strings.TrimSpace(text), values: make(map[string][]string)}
+	if len(c.Params) == 0 {
+		return args, nil
+	}
+
+	rest := args.Text
+	for _, param := range c.Params {
+		if rest == "" {
+			if param.Optional {
+				break
+			}
+			return args, fmt.Errorf("missing %s", param.Name)
+		}
+		if param.Type == TextParam {
+			args.values[param.Name] = []string{rest}
+			rest = ""
+			break
+		}
+
+		var words []string
+		if param.Variadic {
+			words, rest = strings.Fields(rest), ""
+		} else {
+			word := strings.Fields(rest)[0]
+			words = []string{word}
+			rest = strings.TrimLeftFunc(strings.TrimLeftFunc(rest, unicode.IsSpace)[len(word):], unicode.IsSpace)
+		}
+		for i, word := range words {
+			if param.Type == TransactionIDParam {
+				word = strings.ToUpper(word)
+				if !transactionIDPattern.MatchString(word) {
+					return args, fmt.Errorf("%s is not a transaction ID", words[i])
+				}
+			}
+			args.values[param.Name] = append(args.values[param.Name], word)
+		}
+	}
+	if rest != "" {
+		return args, fmt.Errorf("unexpected %q", rest)
+	}
+	return args, nil
+}
+
+// Context is one command invocation. It embeds the Replier answering it.
+type Context struct {
+	Replier
+	Bot     *Bot
+	Session *discordgo.Session
+	Message *discordgo.MessageCreate
+	// Content is the message with invisible characters removed. For a slash
+	// command it is the prefix command it mirrors, and Message only carries
+	// its channel, author and member.
+	Content string
+	// Slash is set when the command came in as a slash command.
+	Slash bool
+	// Command is the router's fallback for M-PESA messages.
+	Command *Command
+	Args    Args
+}
+
+func (ctx *Context) UserID() string {
+	return ctx.Message.Author.ID
+}
+
+// Origin attributes a change to the caller for the audit log. A source
+// naming the command, e.g. "!edit", is recorded as "/edit" when it came in
+// as a slash command.
+func (ctx *Context) Origin(source string) storage.Origin {
+	if ctx.Slash {
+		source = "/" + strings.TrimPrefix(source, "!")
+	}
+	return storage.Origin{UserID: ctx.UserID(), Source: source}
+}
+
+// Allowed reports whether the caller has capability here.
+func (ctx *Context) Allowed(capability permissions.Capability) bool {
+	return ctx.Bot.allowed(ctx.UserID(), ctx.Message.Member, capability)
+}
+
+// ReplyUsage answers with the usage of the current command.
+func (ctx *Context) ReplyUsage() {
+	ctx.Reply(ctx.Command.UsageText())
+}
+
+// Router dispatches messages to commands, running middleware first.
+type Router struct {
+	commands   []*Command
+	byName     map[string]*Command
+	middleware []Middleware
+	// fallback handles everything that is not a command: M-PESA messages.
+	fallback *Command
+}
+
+func NewRouter() *Router {
+	return &Router{byName: make(map[string]*Command)}
+}
+
+// Use adds middleware run before every command, in order.
+func (r *Router) Use(middleware ...Middleware) {
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// Register adds commands. Names are matched case-insensitively.
+func (r *Router) Register(commands ...*Command) {
+	for _, command := range commands {
+		r.commands = append(r.commands, command)
+		r.byName[strings.ToLower(command.Name)] = command
+	}
+}
+
+// Fallback sets the handler for messages that are not commands.
+func (r *Router) Fallback(command *Command) {
+	r.fallback = command
+}
+
+// Commands lists the registered commands in registration order.
+func (r *Router) Commands() []*Command {
+	return r.commands
+}
+
+// Find returns the command a message invokes, or nil.
+func (r *Router) Find(content string) *Command {
+	fields := strings.Fields(content)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "!") {
+		return nil
+	}
+	return r.byName[strings.ToLower(strings.TrimPrefix(fields[0], "!"))]
+}
+
+// Dispatch parses the arguments and runs the command through its middleware.
+func (r *Router) Dispatch(ctx *Context) {
+	command := r.Find(ctx.Content)
+	text := ""
+	switch {
+	case command != nil:
+		name := strings.Fields(ctx.Content)[0]
+		text = strings.TrimSpace(ctx.Content[strings.Index(ctx.Content, name)+len(name):])
+	case strings.HasPrefix(ctx.Content, "!"):
+		name := strings.Fields(ctx.Content)[0]
+		ctx.Reply(fmt.Sprintf("Unknown command %s. Use !help to list the commands.", name))
+		return
+	case r.fallback == nil:
+		return
+	default:
+		command = r.fallback
+	}
+	ctx.Command = command
+
+	args, err := command.parseArgs(text)
+	if err != nil {
+		ctx.Reply(fmt.Sprintf("Invalid !%s: %v\n%s", command.Name, err, command.UsageText()))
+		return
+	}
+	ctx.Args = args
+
+	handler := func(ctx *Context) { command.Run(ctx.Bot, ctx) }
+	for i := len(command.Middleware) - 1; i >= 0; i-- {
+		handler = command.Middleware[i](handler)
+	}
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		handler = r.middleware[i](handler)
+	}
+	handler(ctx)
+}
+
+// requirePermission refuses commands the caller lacks the capability for.
+func requirePermission(next HandlerFunc) HandlerFunc {
+	return func(ctx *Context) {
+		capability := ctx.Command.capability(ctx.Args)
+		if capability != "" && !ctx.Allowed(capability) {
+			ctx.Reply(deniedText(capability))
+			return
+		}
+		next(ctx)
+	}
+}
+
+// logCommands logs each command with its caller, ledger and duration.
+func logCommands(next HandlerFunc) HandlerFunc {
+	return func(ctx *Context) {
+		start := time.Now()
+		next(ctx)
+		log.Printf("%s ran %s in ledger %s (%s)", ctx.UserID(), ctx.Command.Name, ctx.Bot.db.Ledger(), time.Since(start).Round(time.Millisecond))
+	}
+}
+
+// rateLimit lets each user run a command at most limit times per window.
+func rateLimit(limit int, window time.Duration) Middleware {
+	var mu sync.Mutex
+	recent := make(map[string][]time.Time)
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx *Context) {
+			now := time.Now()
+			mu.Lock()
+			var kept []time.Time
+			for _, t := range recent[ctx.UserID()] {
+				if now.Sub(t) < window {
+					kept = append(kept, t)
+				}
+			}
+			allowed := len(kept) < limit
+			if allowed {
+				kept = append(kept, now)
+			}
+			recent[ctx.UserID()] = kept
+			mu.Unlock()
+
+			if !allowed {
+				ctx.Reply(fmt.Sprintf("⏳ Slow down: !%s can be used %d times per %s.", ctx.Command.Name, limit, window))
+				return
+			}
+			next(ctx)
+		}
+	}
+}
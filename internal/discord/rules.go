@@ -0,0 +1,131 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/NgigiN/wallet/internal/mpesa"
+	"github.com/NgigiN/wallet/internal/rules"
+	"github.com/NgigiN/wallet/internal/storage"
+)
+
+// applyRule files a transaction saved without a category line using the
+// best matching rule. The reason is only filled in if none was given. It
+// returns the rule that was applied, if any.
+func (b *Bot) applyRule(tx *storage.Transaction) *storage.Rule {
+	list, err := b.db.ListRules()
+	if err != nil {
+		log.Printf("failed to load rules: %v", err)
+		return nil
+	}
+	rule := rules.Match(list, *tx)
+	if rule == nil || !b.isValidCategory(rule.Category) {
+		return nil
+	}
+
+	tx.Category = rule.Category
+	if tx.Reason == "" {
+		tx.Reason = rule.Reason
+	}
+	return rule
+}
+
+func (b *Bot) handleRuleCommand(ctx *Context) {
+	if len(ctx.Args.Words) == 0 {
+		ctx.ReplyUsage()
+		return
+	}
+	action := ctx.Args.Words[0]
+	args := strings.TrimSpace(ctx.Args.Text[len(action):])
+
+	switch strings.ToLower(action) {
+	case "list":
+		ctx.Reply(b.ruleListText())
+	case "add":
+		rule, err := rules.Parse(args)
+		if err != nil {
+			ctx.Reply(fmt.Sprintf("Invalid rule: %v\n%s", err, ctx.Command.UsageText()))
+			return
+		}
+		ctx.Reply(b.addRuleText(rule, ctx.UserID()))
+	case "delete":
+		ctx.Reply(b.deleteRuleText(args))
+	case "test":
+		ctx.Reply(b.testRuleText(args))
+	default:
+		ctx.ReplyUsage()
+	}
+}
+
+func (b *Bot) ruleListText() string {
+	list, err := b.db.ListRules()
+	if err != nil {
+		return fmt.Sprintf("Failed to list rules: %v", err)
+	}
+	if len(list) == 0 {
+		return "No rules yet. Add one with !rule add."
+	}
+
+	response := "📐 **Rules**\n\n"
+	for _, rule := range list {
+		response += fmt.Sprintf("**#%d** %s\n", rule.ID, rules.Describe(rule))
+	}
+	return response
+}
+
+func (b *Bot) addRuleText(rule storage.Rule, userID string) string {
+	category, suggestions := b.resolveCategory(rule.Category)
+	if category == "" {
+		return b.unresolvedCategoryText(rule.Category, suggestions)
+	}
+	rule.Category = category
+	rule.CreatedBy = userID
+
+	if err := b.db.CreateRule(&rule); err != nil {
+		return fmt.Sprintf("Failed to add rule: %v", err)
+	}
+	return fmt.Sprintf("✅ Added rule #%d: %s", rule.ID, rules.Describe(rule))
+}
+
+func (b *Bot) deleteRuleText(args string) string {
+	id, err := strconv.ParseUint(strings.TrimPrefix(args, "#"), 10, 64)
+	if err != nil {
+		return "Usage: !rule delete <id>"
+	}
+
+	if err := b.db.DeleteRule(uint(id)); err != nil {
+		if errors.Is(err, storage.ErrRuleNotFound) {
+			return fmt.Sprintf("Rule #%d not found", id)
+		}
+		return fmt.Sprintf("Failed to delete rule #%d: %v", id, err)
+	}
+	return fmt.Sprintf("🗑️ Deleted rule #%d", id)
+}
+
+// testRuleText reports how a message without a category line would be filed.
+func (b *Bot) testRuleText(message string) string {
+	parsed, err := mpesa.ParseMPesaMessage(message)
+	if err != nil {
+		return fmt.Sprintf("Invalid Mpesa Message: %v", err)
+	}
+
+	tx := storage.Transaction{
+		Recipient: parsed.Recipient,
+		Account:   parsed.Account,
+		Amount:    parsed.Amount,
+		DateTime:  parsed.DateTime,
+	}
+	rule := b.applyRule(&tx)
+	if rule == nil {
+		return fmt.Sprintf("No rule matches Ksh%.2f to %s; it would be saved as pending.", parsed.Amount, parsed.Recipient)
+	}
+
+	response := fmt.Sprintf("Rule #%d would file Ksh%.2f to %s in %s", rule.ID, parsed.Amount, parsed.Recipient, tx.Category)
+	if tx.Reason != "" {
+		response += fmt.Sprintf(" (%s)", tx.Reason)
+	}
+	return response
+}
@@ -0,0 +1,187 @@
+package discord
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NgigiN/wallet/internal/permissions"
+	"github.com/NgigiN/wallet/internal/storage"
+	"github.com/bwmarrin/discordgo"
+)
+
+// searchLimit caps how many matches !search lists.
+const searchLimit = 15
+
+const dateLayout = "2006-01-02"
+
+// parseFilterArgs reads search and export arguments such as
+// "mwania c:food from:2025-09-01 to:2025-09-30 min:100 max:500 type:paid tag:work".
+// Words without a key are matched against the recipient; "to" is inclusive.
+// A category also matches its subcategories. Results are limited to the
+// caller's ledger unless the arguments pick another scope, see parseScope.
+func (b *Bot) parseFilterArgs(args []string, callerID string, admin bool) (storage.TransactionFilter, error) {
+	var filter storage.TransactionFilter
+	var recipient []string
+
+	sc, args, err := b.parseScope(args, callerID, admin)
+	if err != nil {
+		return filter, err
+	}
+	filter = sc.filter(filter)
+
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, ":")
+		if !ok || value == "" {
+			recipient = append(recipient, arg)
+			continue
+		}
+
+		switch strings.ToLower(key) {
+		case "c", "category":
+			category := strings.ToLower(value)
+			if _, err := b.db.GetCategory(category); err != nil {
+				return filter, fmt.Errorf("invalid category: %s", category)
+			}
+			filter.Categories = b.categoryWithChildren(category)
+		case "from":
+			from, err := time.Parse(dateLayout, value)
+			if err != nil {
+				return filter, fmt.Errorf("invalid from date %q, use YYYY-MM-DD", value)
+			}
+			filter.From = from
+		case "to":
+			to, err := time.Parse(dateLayout, value)
+			if err != nil {
+				return filter, fmt.Errorf("invalid to date %q, use YYYY-MM-DD", value)
+			}
+			filter.To = to.AddDate(0, 0, 1)
+		case "min":
+			amount, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return filter, fmt.Errorf("invalid min amount %q", value)
+			}
+			filter.MinAmount = amount
+		case "max":
+			amount, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return filter, fmt.Errorf("invalid max amount %q", value)
+			}
+			filter.MaxAmount = amount
+		case "type":
+			filter.Type = strings.ToLower(value)
+		case "tag", "t":
+			filter.Tags = append(filter.Tags, value)
+		default:
+			recipient = append(recipient, arg)
+		}
+	}
+
+	filter.Recipient = strings.Join(recipient, " ")
+	return filter, nil
+}
+
+func (b *Bot) handleSearchCommand(ctx *Context) {
+	if len(ctx.Args.Words) == 0 {
+		ctx.ReplyUsage()
+		return
+	}
+
+	filter, err := b.parseFilterArgs(ctx.Args.Words, ctx.UserID(), ctx.Allowed(permissions.Admin))
+	if err != nil {
+		ctx.Reply(fmt.Sprintf("Invalid search: %v", err))
+		return
+	}
+	ctx.Reply(b.searchText(filter))
+}
+
+func (b *Bot) searchText(filter storage.TransactionFilter) string {
+	count, err := b.db.CountTransactions(filter)
+	if err != nil {
+		return fmt.Sprintf("Failed to search transactions: %v", err)
+	}
+	if count == 0 {
+		return "No matching transactions found."
+	}
+
+	filter.Limit = searchLimit
+	transactions, err := b.db.FindTransactions(filter)
+	if err != nil {
+		return fmt.Sprintf("Failed to search transactions: %v", err)
+	}
+
+	var total float64
+	response := "🔎 **Search Results**\n\n"
+	for _, tx := range transactions {
+		total += tx.Amount
+		response += fmt.Sprintf("• **%s** Ksh%.2f to %s (%s)\n  %s - %s\n",
+			tx.TransactionID, tx.Amount, tx.Recipient, tx.Category,
+			tx.DateTime.Format("Jan 2, 2006 3:04 PM"), tx.Reason)
+	}
+
+	if count > int64(len(transactions)) {
+		response += fmt.Sprintf("\nShowing %d of %d matches. Use !export for the full list.", len(transactions), count)
+	} else {
+		response += fmt.Sprintf("\n**Total**: Ksh%.2f (%d transactions)", total, count)
+	}
+	return response
+}
+
+func (b *Bot) handleExportCommand(ctx *Context) {
+	filter, err := b.parseFilterArgs(ctx.Args.Words, ctx.UserID(), ctx.Allowed(permissions.Admin))
+	if err != nil {
+		ctx.Reply(fmt.Sprintf("Invalid export: %v", err))
+		return
+	}
+
+	file, count, err := b.exportFile(filter)
+	if err != nil {
+		ctx.Reply(fmt.Sprintf("Failed to export transactions: %v", err))
+		return
+	}
+
+	ctx.ReplyComplex(&discordgo.MessageSend{
+		Content: fmt.Sprintf("📤 Exported %d transactions", count),
+		Files:   []*discordgo.File{file},
+	})
+}
+
+// exportFile renders the matching transactions, oldest first, as a CSV attachment.
+func (b *Bot) exportFile(filter storage.TransactionFilter) (*discordgo.File, int, error) {
+	filter.Ascending = true
+	transactions, err := b.db.FindTransactions(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"transaction_id", "date_time", "amount", "cost", "balance", "recipient", "category", "reason", "type", "tags"})
+	for _, tx := range transactions {
+		w.Write([]string{
+			tx.TransactionID,
+			tx.DateTime.Format("2006-01-02 15:04"),
+			strconv.FormatFloat(tx.Amount, 'f', 2, 64),
+			strconv.FormatFloat(tx.Cost, 'f', 2, 64),
+			strconv.FormatFloat(tx.Balance, 'f', 2, 64),
+			tx.Recipient,
+			tx.Category,
+			tx.Reason,
+			tx.Type,
+			tx.Tags,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, 0, err
+	}
+
+	return &discordgo.File{
+		Name:        "transactions.csv",
+		ContentType: "text/csv",
+		Reader:      &buf,
+	}, len(transactions), nil
+}
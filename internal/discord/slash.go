@@ -0,0 +1,262 @@
+package discord
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/NgigiN/wallet/internal/storage"
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxChoices is the most choices Discord accepts on an option or select menu.
+const maxChoices = 25
+
+// slashCommands describes the application commands registered on startup.
+// Each mirrors a prefix command, which keeps working as a fallback. The
+// category options list the given categories as choices; without any they
+// take free text.
+func slashCommands(categories []storage.Category) []*discordgo.ApplicationCommand {
+	categoryOption := func(description string, required bool) *discordgo.ApplicationCommandOption {
+		option := &discordgo.ApplicationCommandOption{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "category",
+			Description: description,
+			Required:    required,
+		}
+		// Discord allows at most 25 choices; past that the option takes free text
+		if len(categories) <= maxChoices {
+			for _, category := range categories {
+				option.Choices = append(option.Choices, &discordgo.ApplicationCommandOptionChoice{
+					Name:  strings.Title(category.Name),
+					Value: category.Name,
+				})
+			}
+		}
+		return option
+	}
+	transactionOption := &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        "transaction_id",
+		Description: "M-PESA transaction ID, e.g. TIL4XR5BBM",
+		Required:    true,
+	}
+	householdOption := &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        "household",
+		Description: "Combine the ledgers of a household you belong to",
+	}
+	dateOption := func(name, description string) *discordgo.ApplicationCommandOption {
+		return &discordgo.ApplicationCommandOption{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        name,
+			Description: description,
+		}
+	}
+
+	return []*discordgo.ApplicationCommand{
+		{
+			Name:        "summary",
+			Description: "Show totals per category, or the transactions of one category",
+			Options: []*discordgo.ApplicationCommandOption{
+				categoryOption("Category to show in detail", false),
+				{Type: discordgo.ApplicationCommandOptionString, Name: "period", Description: "today, week, month, year, YYYY-MM or YYYY-MM-DD..YYYY-MM-DD"},
+				householdOption,
+			},
+		},
+		{
+			Name:        "edit",
+			Description: "Change the category, reason or tags of a transaction",
+			Options: []*discordgo.ApplicationCommandOption{
+				transactionOption,
+				categoryOption("New category", false),
+				{Type: discordgo.ApplicationCommandOptionString, Name: "reason", Description: "New reason"},
+				{Type: discordgo.ApplicationCommandOptionString, Name: "tags", Description: "Comma-separated tags"},
+			},
+		},
+		{
+			Name:        "delete",
+			Description: "Move a transaction to the trash",
+			Options:     []*discordgo.ApplicationCommandOption{transactionOption},
+		},
+		{
+			Name:        "search",
+			Description: "Find transactions by recipient, category, date or amount",
+			Options: []*discordgo.ApplicationCommandOption{
+				{Type: discordgo.ApplicationCommandOptionString, Name: "recipient", Description: "Part of the recipient name"},
+				categoryOption("Only this category", false),
+				dateOption("from", "Start date, YYYY-MM-DD"),
+				dateOption("to", "End date (inclusive), YYYY-MM-DD"),
+				{Type: discordgo.ApplicationCommandOptionNumber, Name: "min", Description: "Minimum amount"},
+				{Type: discordgo.ApplicationCommandOptionNumber, Name: "max", Description: "Maximum amount"},
+				householdOption,
+			},
+		},
+		{
+			Name:        "export",
+			Description: "Download transactions as CSV",
+			Options: []*discordgo.ApplicationCommandOption{
+				categoryOption("Only this category", false),
+				dateOption("from", "Start date, YYYY-MM-DD"),
+				dateOption("to", "End date (inclusive), YYYY-MM-DD"),
+				householdOption,
+			},
+		},
+	}
+}
+
+// registerSlashCommands registers the application commands in the guilds of
+// the configured channels, where updates apply immediately.
+func (b *Bot) registerSlashCommands(s *discordgo.Session, r *discordgo.Ready) {
+	b.syncSlashCommands(s, r.User.ID)
+}
+
+// syncSlashCommands overwrites the registered commands, e.g. after the
+// category choices changed. Commands are registered per guild, so a guild
+// whose channels use different ledgers gets free-text categories.
+func (b *Bot) syncSlashCommands(s *discordgo.Session, appID string) {
+	ledgers := make(map[string]map[string]*Bot)
+	for _, lb := range b.channels.guildBots() {
+		channel, err := s.Channel(lb.channelID)
+		if err != nil {
+			log.Printf("failed to look up channel %s for slash commands: %v", lb.channelID, err)
+			continue
+		}
+		if ledgers[channel.GuildID] == nil {
+			ledgers[channel.GuildID] = make(map[string]*Bot)
+		}
+		ledgers[channel.GuildID][lb.db.Ledger()] = lb
+	}
+
+	for guildID, bots := range ledgers {
+		var categories []storage.Category
+		if len(bots) == 1 {
+			for _, lb := range bots {
+				categories = lb.activeCategories()
+			}
+		}
+		if _, err := s.ApplicationCommandBulkOverwrite(appID, guildID, slashCommands(categories)); err != nil {
+			log.Printf("failed to register slash commands in guild %s: %v", guildID, err)
+		}
+	}
+}
+
+func (b *Bot) handleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	userID := ""
+	if user := interactionUser(i); user != nil {
+		userID = user.ID
+	}
+	lb := b.botFor(i.ChannelID, i.GuildID, userID)
+	if lb == nil {
+		respondEphemeral(s, i, "This bot only works in its configured channels.")
+		return
+	}
+	if lb != b {
+		lb.handleInteraction(s, i)
+		return
+	}
+
+	if i.Type == discordgo.InteractionApplicationCommand {
+		// The router checks the permissions of the command
+		b.handleSlashCommand(s, i)
+		return
+	}
+	if capability := interactionCapability(i); !b.allowed(userID, i.Member, capability) {
+		respondEphemeral(s, i, deniedText(capability))
+		return
+	}
+	switch i.Type {
+	case discordgo.InteractionMessageComponent:
+		b.handleComponent(s, i)
+	case discordgo.InteractionModalSubmit:
+		b.handleModalSubmit(s, i)
+	}
+}
+
+// handleSlashCommand runs a slash command as the prefix command it mirrors,
+// so both share one command table, its permissions and its middleware.
+func (b *Bot) handleSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	data := i.ApplicationCommandData()
+	b.router.Dispatch(&Context{
+		Replier: &interactionReplier{session: s, interaction: i},
+		Bot:     b,
+		Session: s,
+		Message: &discordgo.MessageCreate{Message: &discordgo.Message{
+			ChannelID: i.ChannelID,
+			GuildID:   i.GuildID,
+			Author:    interactionUser(i),
+			Member:    i.Member,
+		}},
+		Content: slashContent(data),
+		Slash:   true,
+	})
+}
+
+// slashContent writes a slash command as the prefix command it mirrors, so
+// both paths parse and validate the arguments identically.
+func slashContent(data discordgo.ApplicationCommandInteractionData) string {
+	options := make(map[string]*discordgo.ApplicationCommandInteractionDataOption)
+	for _, option := range data.Options {
+		options[option.Name] = option
+	}
+	str := func(name string) string {
+		if option, ok := options[name]; ok {
+			return strings.TrimSpace(option.StringValue())
+		}
+		return ""
+	}
+	args := []string{"!" + data.Name}
+
+	switch data.Name {
+	case "summary":
+		for _, key := range []string{"category", "period"} {
+			if value := str(key); value != "" {
+				args = append(args, value)
+			}
+		}
+		if household := str("household"); household != "" {
+			args = append(args, "h:"+household)
+		}
+
+	case "edit":
+		// One change per line, so a reason may contain anything but a key
+		args = append(args, str("transaction_id"))
+		for _, key := range []string{"category", "reason", "tags"} {
+			if _, ok := options[key]; ok {
+				args = append(args, "\n"+key+": "+str(key))
+			}
+		}
+
+	case "delete":
+		args = append(args, str("transaction_id"))
+
+	case "search", "export":
+		args = append(args, strings.Fields(str("recipient"))...)
+		for _, key := range []string{"category", "from", "to", "household"} {
+			if value := str(key); value != "" {
+				args = append(args, key+":"+value)
+			}
+		}
+		for _, key := range []string{"min", "max"} {
+			if option, ok := options[key]; ok {
+				args = append(args, fmt.Sprintf("%s:%g", key, option.FloatValue()))
+			}
+		}
+	}
+	return strings.Join(args, " ")
+}
+
+// interactionUser returns the invoking user for guild and DM interactions.
+func interactionUser(i *discordgo.InteractionCreate) *discordgo.User {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User
+	}
+	return i.User
+}
+
+func respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Content: content, Flags: discordgo.MessageFlagsEphemeral},
+	})
+}
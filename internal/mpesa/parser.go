@@ -15,8 +15,15 @@ type ParsedTransaction struct {
 	DateTime      time.Time
 	Balance       float64
 	Cost          float64
+	Type          string // "sent" or "paid"
+	// Account is the paybill account number, e.g. "1082111" from
+	// "Co-operative Bank Money Transfer for account 1082111". Empty for
+	// transfers to people and till payments.
+	Account string
 }
 
+var accountPattern = regexp.MustCompile(`(?i)\s+for\s+account\s+(.+)$`)
+
 func ParseMPesaMessage(msg string) (*ParsedTransaction, error) {
 	// More permissive pattern to support variants observed in messages:
 	// - Optional extra spaces/periods
@@ -44,6 +51,10 @@ func ParseMPesaMessage(msg string) (*ParsedTransaction, error) {
 	recipient := strings.TrimSpace(strings.TrimSuffix(matches[4], "."))
 	// Normalize double spaces
 	recipient = strings.Join(strings.Fields(recipient), " ")
+	var account string
+	if m := accountPattern.FindStringSubmatch(recipient); m != nil {
+		account = m[1]
+	}
 
 	dateParts := strings.Split(matches[5], "/")
 	day, _ := strconv.Atoi(dateParts[0])
@@ -85,5 +96,7 @@ func ParseMPesaMessage(msg string) (*ParsedTransaction, error) {
 		DateTime:      dateTime,
 		Balance:       balance,
 		Cost:          cost,
+		Type:          strings.ToLower(matches[3]),
+		Account:       account,
 	}, nil
 }
@@ -0,0 +1,147 @@
+// Package permissions decides who may do what in each channel. A JSON file
+// grants capabilities to everyone, to Discord roles and to individual users,
+// per channel with a default for channels it does not list:
+//
+//	{
+//	  "default": {"everyone": ["log", "view"]},
+//	  "channels": {
+//	    "123456789012345678": {
+//	      "everyone": ["view"],
+//	      "roles": {"234567890123456789": ["log", "edit", "export"]},
+//	      "users": {"345678901234567890": ["admin"]}
+//	    }
+//	  }
+//	}
+package permissions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Capability is something a user may be allowed to do.
+type Capability string
+
+const (
+	// Log saves new transactions and files pending ones.
+	Log Capability = "log"
+	// View reads summaries, searches and history.
+	View Capability = "view"
+	// Edit changes saved transactions.
+	Edit Capability = "edit"
+	// Delete moves transactions to the trash and back.
+	Delete Capability = "delete"
+	// Export downloads transactions as CSV.
+	Export Capability = "export"
+	// Admin manages categories and rules, and implies every other capability.
+	Admin Capability = "admin"
+)
+
+// Capabilities lists every capability, in the order they are documented.
+var Capabilities = []Capability{Log, View, Edit, Delete, Export, Admin}
+
+// Policy grants capabilities in one channel. A user has the union of what
+// is granted to everyone, to each of their roles and to them by ID.
+type Policy struct {
+	Everyone []Capability            `json:"everyone"`
+	Roles    map[string][]Capability `json:"roles"`
+	Users    map[string][]Capability `json:"users"`
+}
+
+// Permissions holds the policy of each channel. A nil Permissions allows
+// everything, which keeps setups without a permissions file open.
+type Permissions struct {
+	Default  *Policy           `json:"default"`
+	Channels map[string]Policy `json:"channels"`
+}
+
+// Load reads permissions from a JSON file.
+func Load(path string) (*Permissions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read permissions: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse reads permissions from JSON, rejecting unknown capabilities.
+func Parse(data []byte) (*Permissions, error) {
+	var p Permissions
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse permissions: %w", err)
+	}
+
+	policies := make(map[string]Policy, len(p.Channels)+1)
+	for channelID, policy := range p.Channels {
+		policies["channel "+channelID] = policy
+	}
+	if p.Default != nil {
+		policies["default"] = *p.Default
+	}
+	for name, policy := range policies {
+		lists := [][]Capability{policy.Everyone}
+		for _, capabilities := range policy.Roles {
+			lists = append(lists, capabilities)
+		}
+		for _, capabilities := range policy.Users {
+			lists = append(lists, capabilities)
+		}
+		for _, capabilities := range lists {
+			for _, c := range capabilities {
+				if !c.valid() {
+					return nil, fmt.Errorf("unknown capability %q in %s", c, name)
+				}
+			}
+		}
+	}
+	return &p, nil
+}
+
+func (c Capability) valid() bool {
+	for _, known := range Capabilities {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Open reports whether a channel has no policy at all, so that everyone may
+// do everything there.
+func (p *Permissions) Open(channelID string) bool {
+	if p == nil {
+		return true
+	}
+	_, ok := p.Channels[channelID]
+	return !ok && p.Default == nil
+}
+
+// Allowed reports whether a user with the given roles may use capability in
+// a channel. Channels without a policy fall back to the default one, and
+// are open when there is none.
+func (p *Permissions) Allowed(channelID, userID string, roles []string, capability Capability) bool {
+	if p == nil {
+		return true
+	}
+	if p.Open(channelID) {
+		return true
+	}
+	policy, ok := p.Channels[channelID]
+	if !ok {
+		policy = *p.Default
+	}
+
+	lists := [][]Capability{policy.Everyone, policy.Users[userID]}
+	for _, role := range roles {
+		lists = append(lists, policy.Roles[role])
+	}
+	for _, capabilities := range lists {
+		for _, c := range capabilities {
+			if c == capability || c == Admin {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package permissions
+
+import (
+	"testing"
+)
+
+const testPermissions = `{
+	"default": {"everyone": ["view"]},
+	"channels": {
+		"family": {
+			"everyone": ["log", "view"],
+			"roles": {"parents": ["edit", "delete"]},
+			"users": {"alice": ["admin"]}
+		}
+	}
+}`
+
+func TestAllowed(t *testing.T) {
+	p, err := Parse([]byte(testPermissions))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		channelID  string
+		userID     string
+		roles      []string
+		capability Capability
+		want       bool
+	}{
+		{"everyone may log", "family", "bob", nil, Log, true},
+		{"everyone may not edit", "family", "bob", nil, Edit, false},
+		{"role grants edit", "family", "bob", []string{"kids", "parents"}, Edit, true},
+		{"role does not grant export", "family", "bob", []string{"parents"}, Export, false},
+		{"admin implies everything", "family", "alice", nil, Export, true},
+		{"default policy for other channels", "shop", "alice", nil, View, true},
+		{"default policy denies", "shop", "alice", nil, Log, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.Allowed(tt.channelID, tt.userID, tt.roles, tt.capability); got != tt.want {
+				t.Fatalf("Allowed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithoutPolicyEverythingIsAllowed(t *testing.T) {
+	var p *Permissions
+	if !p.Allowed("any", "anyone", nil, Admin) || !p.Open("any") {
+		t.Fatal("expected nil permissions to allow everything")
+	}
+
+	p, err := Parse([]byte(`{"channels": {"family": {"everyone": ["view"]}}}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !p.Allowed("shop", "anyone", nil, Delete) || !p.Open("shop") {
+		t.Fatal("expected channels without a policy or default to be open")
+	}
+	if p.Allowed("family", "anyone", nil, Delete) || p.Open("family") {
+		t.Fatal("expected the family policy to apply")
+	}
+}
+
+func TestParseRejectsUnknownCapabilities(t *testing.T) {
+	if _, err := Parse([]byte(`{"channels": {"family": {"roles": {"kids": ["spend"]}}}}`)); err == nil {
+		t.Fatal("expected an error for an unknown capability")
+	}
+	if _, err := Parse([]byte(`{"default": `)); err == nil {
+		t.Fatal("expected an error for invalid JSON")
+	}
+}
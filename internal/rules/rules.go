@@ -0,0 +1,182 @@
+// Package rules files transactions that arrive without a category, using
+// user-defined rules keyed on recipient, paybill account, amount range and
+// time of day.
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NgigiN/wallet/internal/storage"
+)
+
+const clockLayout = "15:04"
+
+// Match returns the rule that applies to tx, or nil. When several rules
+// match, the most specific one (most conditions set) wins, then the oldest.
+func Match(rules []storage.Rule, tx storage.Transaction) *storage.Rule {
+	var best *storage.Rule
+	for i := range rules {
+		rule := &rules[i]
+		if !Matches(*rule, tx) {
+			continue
+		}
+		if best == nil || specificity(*rule) > specificity(*best) {
+			best = rule
+		}
+	}
+	return best
+}
+
+// Matches reports whether every condition set on rule holds for tx.
+func Matches(rule storage.Rule, tx storage.Transaction) bool {
+	if rule.Recipient != "" && !strings.Contains(strings.ToLower(tx.Recipient), strings.ToLower(rule.Recipient)) {
+		return false
+	}
+	if rule.Account != "" && !strings.EqualFold(tx.Account, rule.Account) {
+		return false
+	}
+	if rule.MinAmount > 0 && tx.Amount < rule.MinAmount {
+		return false
+	}
+	if rule.MaxAmount > 0 && tx.Amount > rule.MaxAmount {
+		return false
+	}
+	if rule.TimeFrom != "" && rule.TimeTo != "" {
+		from, _ := minuteOfDay(rule.TimeFrom)
+		to, _ := minuteOfDay(rule.TimeTo)
+		at := tx.DateTime.Hour()*60 + tx.DateTime.Minute()
+		if from <= to {
+			if at < from || at >= to {
+				return false
+			}
+		} else if at < from && at >= to {
+			// The window wraps past midnight, e.g. 22:00-02:00
+			return false
+		}
+	}
+	return true
+}
+
+func specificity(rule storage.Rule) int {
+	n := 0
+	for _, set := range []bool{rule.Recipient != "", rule.Account != "", rule.MinAmount > 0, rule.MaxAmount > 0, rule.TimeFrom != ""} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+func minuteOfDay(clock string) (int, error) {
+	t, err := time.Parse(clockLayout, clock)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Parse reads a rule from "!rule add" arguments such as
+// "mama mboga min:50 max:500 time:06:00-10:00 c:food r:vegetables".
+// Words without a key are matched against the recipient, and the reason runs
+// to the end of the line. The category is returned as typed; callers resolve
+// it against the stored categories.
+func Parse(args string) (storage.Rule, error) {
+	var rule storage.Rule
+
+	// The reason may contain spaces, so it has to come last
+	fields := strings.Fields(args)
+	for i, field := range fields {
+		key, value, ok := strings.Cut(field, ":")
+		if key = strings.ToLower(key); ok && (key == "r" || key == "reason") {
+			rule.Reason = strings.TrimSpace(strings.Join(append([]string{value}, fields[i+1:]...), " "))
+			fields = fields[:i]
+			break
+		}
+	}
+
+	var recipient []string
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, ":")
+		if !ok || value == "" {
+			recipient = append(recipient, field)
+			continue
+		}
+
+		switch strings.ToLower(key) {
+		case "c", "category":
+			rule.Category = strings.ToLower(value)
+		case "account", "acc":
+			rule.Account = value
+		case "min":
+			amount, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return rule, fmt.Errorf("invalid min amount %q", value)
+			}
+			rule.MinAmount = amount
+		case "max":
+			amount, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return rule, fmt.Errorf("invalid max amount %q", value)
+			}
+			rule.MaxAmount = amount
+		case "time":
+			from, to, ok := strings.Cut(value, "-")
+			if !ok {
+				return rule, fmt.Errorf("invalid time window %q, use HH:MM-HH:MM", value)
+			}
+			if _, err := minuteOfDay(from); err != nil {
+				return rule, fmt.Errorf("invalid time window %q, use HH:MM-HH:MM", value)
+			}
+			if _, err := minuteOfDay(to); err != nil {
+				return rule, fmt.Errorf("invalid time window %q, use HH:MM-HH:MM", value)
+			}
+			rule.TimeFrom, rule.TimeTo = from, to
+		default:
+			recipient = append(recipient, field)
+		}
+	}
+	rule.Recipient = strings.Join(recipient, " ")
+
+	if rule.Category == "" {
+		return rule, fmt.Errorf("a rule needs a category, e.g. c:food")
+	}
+	if specificity(rule) == 0 {
+		return rule, fmt.Errorf("a rule needs at least one of recipient, account, min, max or time")
+	}
+	if rule.MinAmount > 0 && rule.MaxAmount > 0 && rule.MinAmount > rule.MaxAmount {
+		return rule, fmt.Errorf("min amount is above max amount")
+	}
+	return rule, nil
+}
+
+// Describe renders the conditions of a rule, e.g.
+// `recipient "mama mboga", Ksh50-500, 06:00-10:00 → food (vegetables)`.
+func Describe(rule storage.Rule) string {
+	var conditions []string
+	if rule.Recipient != "" {
+		conditions = append(conditions, fmt.Sprintf("recipient %q", rule.Recipient))
+	}
+	if rule.Account != "" {
+		conditions = append(conditions, "account "+rule.Account)
+	}
+	switch {
+	case rule.MinAmount > 0 && rule.MaxAmount > 0:
+		conditions = append(conditions, fmt.Sprintf("Ksh%g-%g", rule.MinAmount, rule.MaxAmount))
+	case rule.MinAmount > 0:
+		conditions = append(conditions, fmt.Sprintf("from Ksh%g", rule.MinAmount))
+	case rule.MaxAmount > 0:
+		conditions = append(conditions, fmt.Sprintf("up to Ksh%g", rule.MaxAmount))
+	}
+	if rule.TimeFrom != "" {
+		conditions = append(conditions, rule.TimeFrom+"-"+rule.TimeTo)
+	}
+
+	description := strings.Join(conditions, ", ") + " → " + rule.Category
+	if rule.Reason != "" {
+		description += fmt.Sprintf(" (%s)", rule.Reason)
+	}
+	return description
+}
@@ -0,0 +1,75 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NgigiN/wallet/internal/storage"
+)
+
+func at(hour, minute int) time.Time {
+	return time.Date(2025, time.September, 21, hour, minute, 0, 0, time.UTC)
+}
+
+func TestParse(t *testing.T) {
+	rule, err := Parse("Mama Mboga min:50 max:500 time:06:00-10:00 c:Food r: sukuma and tomatoes")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := storage.Rule{Recipient: "Mama Mboga", MinAmount: 50, MaxAmount: 500, TimeFrom: "06:00", TimeTo: "10:00", Category: "food", Reason: "sukuma and tomatoes"}
+	if rule != want {
+		t.Fatalf("got %+v, want %+v", rule, want)
+	}
+
+	// Without a colon, r and reason are part of the recipient
+	rule, err = Parse("r k enterprises reason c:shopping")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if want := (storage.Rule{Recipient: "r k enterprises reason", Category: "shopping"}); rule != want {
+		t.Fatalf("got %+v, want %+v", rule, want)
+	}
+
+	for _, args := range []string{
+		"mama mboga",            // no category
+		"c:food",                // no condition
+		"time:6-10 c:food",      // bad clock
+		"min:500 max:50 c:food", // inverted range
+	} {
+		if _, err := Parse(args); err == nil {
+			t.Errorf("expected %q to be rejected", args)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	rules := []storage.Rule{
+		{ID: 1, Recipient: "super metro", Category: "travel"},
+		{ID: 2, Recipient: "super metro", TimeFrom: "22:00", TimeTo: "02:00", Category: "entertainment"},
+		{ID: 3, Account: "1082111", Category: "savings"},
+		{ID: 4, MaxAmount: 100, TimeFrom: "06:00", TimeTo: "10:00", Category: "food"},
+	}
+
+	tests := []struct {
+		name string
+		tx   storage.Transaction
+		want uint
+	}{
+		{"recipient", storage.Transaction{Recipient: "SUPER METRO SACCO", Amount: 300, DateTime: at(7, 30)}, 1},
+		{"more specific wins", storage.Transaction{Recipient: "Super Metro Sacco", Amount: 300, DateTime: at(23, 15)}, 2},
+		{"window wraps midnight", storage.Transaction{Recipient: "Super Metro Sacco", Amount: 300, DateTime: at(1, 0)}, 2},
+		{"account", storage.Transaction{Recipient: "Co-operative Bank", Account: "1082111", Amount: 1000, DateTime: at(12, 0)}, 3},
+		{"amount and time", storage.Transaction{Recipient: "Kiosk", Amount: 60, DateTime: at(6, 0)}, 4},
+		{"window end is exclusive", storage.Transaction{Recipient: "Kiosk", Amount: 60, DateTime: at(10, 0)}, 0},
+		{"over max", storage.Transaction{Recipient: "Kiosk", Amount: 150, DateTime: at(7, 0)}, 0},
+	}
+	for _, tt := range tests {
+		var got uint
+		if rule := Match(rules, tt.tx); rule != nil {
+			got = rule.ID
+		}
+		if got != tt.want {
+			t.Errorf("%s: matched rule %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Package schedule decides when recurring posts such as digests are due. A
+// schedule is written as a comma-separated list of entries:
+//
+//	daily 08:00, weekly mon 08:00, monthly 1 09:00
+//
+// Times are wall-clock times in whatever zone the caller passes in; the bot
+// uses East Africa Time, like the M-PESA messages.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NgigiN/wallet/internal/storage"
+)
+
+const clockLayout = "15:04"
+
+// Entry is one recurring time: every day, every week on Weekday, or every
+// month on Day, at Hour:Minute.
+type Entry struct {
+	// Period is storage.Day, storage.Week or storage.Month.
+	Period  storage.Granularity
+	Weekday time.Weekday
+	// Day of the month, 1 to 28 so that every month has it.
+	Day    int
+	Hour   int
+	Minute int
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Parse reads a schedule. An empty string is an empty schedule.
+func Parse(spec string) ([]Entry, error) {
+	var entries []Entry
+	for _, item := range strings.Split(spec, ",") {
+		fields := strings.Fields(strings.ToLower(item))
+		if len(fields) == 0 {
+			continue
+		}
+		entry, err := parseEntry(fields)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule entry %q: %w", strings.TrimSpace(item), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func parseEntry(fields []string) (Entry, error) {
+	var entry Entry
+	var clock string
+	switch fields[0] {
+	case "daily":
+		if len(fields) != 2 {
+			return entry, fmt.Errorf("use daily HH:MM")
+		}
+		entry.Period, clock = storage.Day, fields[1]
+	case "weekly":
+		if len(fields) != 3 {
+			return entry, fmt.Errorf("use weekly <mon..sun> HH:MM")
+		}
+		weekday, ok := weekdays[fields[1][:min(3, len(fields[1]))]]
+		if !ok {
+			return entry, fmt.Errorf("unknown weekday %q", fields[1])
+		}
+		entry.Period, entry.Weekday, clock = storage.Week, weekday, fields[2]
+	case "monthly":
+		if len(fields) != 3 {
+			return entry, fmt.Errorf("use monthly <1-28> HH:MM")
+		}
+		day, err := strconv.Atoi(fields[1])
+		if err != nil || day < 1 || day > 28 {
+			return entry, fmt.Errorf("day of the month must be 1 to 28, got %q", fields[1])
+		}
+		entry.Period, entry.Day, clock = storage.Month, day, fields[2]
+	default:
+		return entry, fmt.Errorf("start with daily, weekly or monthly")
+	}
+
+	t, err := time.Parse(clockLayout, clock)
+	if err != nil {
+		return entry, fmt.Errorf("invalid time %q, use HH:MM", clock)
+	}
+	entry.Hour, entry.Minute = t.Hour(), t.Minute()
+	return entry, nil
+}
+
+// Last returns the most recent time the entry fired at or before now, in
+// now's location.
+func (e Entry) Last(now time.Time) time.Time {
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, e.Hour, e.Minute, 0, 0, now.Location())
+	}
+	switch e.Period {
+	case storage.Week:
+		back := (int(now.Weekday()) - int(e.Weekday) + 7) % 7
+		t := at(now.Year(), now.Month(), now.Day()-back)
+		if t.After(now) {
+			t = t.AddDate(0, 0, -7)
+		}
+		return t
+	case storage.Month:
+		t := at(now.Year(), now.Month(), e.Day)
+		if t.After(now) {
+			t = at(now.Year(), now.Month()-1, e.Day)
+		}
+		return t
+	default:
+		t := at(now.Year(), now.Month(), now.Day())
+		if t.After(now) {
+			t = t.AddDate(0, 0, -1)
+		}
+		return t
+	}
+}
+
+// Covered returns the start of the period a post firing at fired reports
+// on: the last complete day, week or month before it.
+func (e Entry) Covered(fired time.Time) time.Time {
+	start := storage.PeriodStart(fired, e.Period)
+	switch e.Period {
+	case storage.Week:
+		return start.AddDate(0, 0, -7)
+	case storage.Month:
+		return start.AddDate(0, -1, 0)
+	default:
+		return start.AddDate(0, 0, -1)
+	}
+}
+
+// String renders the entry the way Parse reads it.
+func (e Entry) String() string {
+	clock := fmt.Sprintf("%02d:%02d", e.Hour, e.Minute)
+	switch e.Period {
+	case storage.Week:
+		return fmt.Sprintf("weekly %s %s", strings.ToLower(e.Weekday.String()[:3]), clock)
+	case storage.Month:
+		return fmt.Sprintf("monthly %d %s", e.Day, clock)
+	default:
+		return "daily " + clock
+	}
+}
@@ -0,0 +1,68 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NgigiN/wallet/internal/storage"
+)
+
+func TestParse(t *testing.T) {
+	entries, err := Parse("daily 08:00, Weekly Monday 18:30,monthly 1 09:05")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %+v", entries)
+	}
+	for i, want := range []string{"daily 08:00", "weekly mon 18:30", "monthly 1 09:05"} {
+		if got := entries[i].String(); got != want {
+			t.Fatalf("entry %d is %q, want %q", i, got, want)
+		}
+	}
+
+	if entries, err := Parse(""); err != nil || len(entries) != 0 {
+		t.Fatalf("expected an empty schedule, got %+v, %v", entries, err)
+	}
+	for _, spec := range []string{"hourly 08:00", "daily 8", "weekly someday 08:00", "monthly 31 08:00", "monthly 08:00"} {
+		if _, err := Parse(spec); err == nil {
+			t.Fatalf("expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestLastAndCovered(t *testing.T) {
+	// Wednesday 17 September 2025, 07:00
+	now := time.Date(2025, time.September, 17, 7, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		spec    string
+		last    time.Time
+		covered time.Time
+	}{
+		{"daily 08:00", time.Date(2025, 9, 16, 8, 0, 0, 0, time.UTC), time.Date(2025, 9, 15, 0, 0, 0, 0, time.UTC)},
+		{"daily 06:00", time.Date(2025, 9, 17, 6, 0, 0, 0, time.UTC), time.Date(2025, 9, 16, 0, 0, 0, 0, time.UTC)},
+		{"weekly mon 08:00", time.Date(2025, 9, 15, 8, 0, 0, 0, time.UTC), time.Date(2025, 9, 8, 0, 0, 0, 0, time.UTC)},
+		{"weekly wed 08:00", time.Date(2025, 9, 10, 8, 0, 0, 0, time.UTC), time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)},
+		{"monthly 1 09:00", time.Date(2025, 9, 1, 9, 0, 0, 0, time.UTC), time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)},
+		{"monthly 20 09:00", time.Date(2025, 8, 20, 9, 0, 0, 0, time.UTC), time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		entries, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tt.spec, err)
+		}
+		entry := entries[0]
+		last := entry.Last(now)
+		if !last.Equal(tt.last) {
+			t.Fatalf("%s: last fired %v, want %v", tt.spec, last, tt.last)
+		}
+		if covered := entry.Covered(last); !covered.Equal(tt.covered) {
+			t.Fatalf("%s: covers %v, want %v", tt.spec, covered, tt.covered)
+		}
+	}
+
+	if entry := (Entry{Period: storage.Day, Hour: 7}); !entry.Last(now).Equal(now) {
+		t.Fatalf("expected an entry firing right now to count as fired")
+	}
+}
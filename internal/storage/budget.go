@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+func (d *Database) SetBudget(budget *Budget) error {
+	budget.Ledger = d.ledger
+	budget.Category = strings.ToLower(budget.Category)
+	err := d.db.Transaction(func(db *gorm.DB) error {
+		var existing Budget
+		err := db.Scopes(d.inLedger).Where("category = ? AND period = ?", budget.Category, budget.Period).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return db.Create(budget).Error
+		}
+		if err != nil {
+			return err
+		}
+		existing.Amount = budget.Amount
+		existing.CreatedBy = budget.CreatedBy
+		if err := db.Save(&existing).Error; err != nil {
+			return err
+		}
+		*budget = existing
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set budget: %w", err)
+	}
+	return nil
+}
+
+func (d *Database) ListBudgets() ([]Budget, error) {
+	var budgets []Budget
+	if err := d.db.Scopes(d.inLedger).Order("id ASC").Find(&budgets).Error; err != nil {
+		return nil, fmt.Errorf("failed to list budgets: %w", err)
+	}
+	return budgets, nil
+}
+
+func (d *Database) DeleteBudget(category string, period Granularity) error {
+	category = strings.ToLower(category)
+	result := d.db.Scopes(d.inLedger).Where("category = ? AND period = ?", category, period).Delete(&Budget{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete %s budget of %s: %w", period, category, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to delete %s budget of %s: %w", period, category, ErrBudgetNotFound)
+	}
+	return nil
+}
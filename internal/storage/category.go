@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// seedCategories creates DefaultCategories and DefaultAliases if no category
+// exists yet.
+func (d *Database) seedCategories() error {
+	var count int64
+	if err := d.db.Model(&Category{}).Scopes(d.inLedger).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	for _, name := range DefaultCategories {
+		if err := d.db.Create(&Category{Ledger: d.ledger, Name: name}).Error; err != nil {
+			return err
+		}
+	}
+	for alias, category := range DefaultAliases {
+		if err := d.AddCategoryAlias(alias, category); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Database) ListCategories(includeArchived bool) ([]Category, error) {
+	var categories []Category
+	query := d.db.Scopes(d.inLedger).Order("id ASC")
+	if !includeArchived {
+		query = query.Where("archived = ?", false)
+	}
+	if err := query.Find(&categories).Error; err != nil {
+		return nil, fmt.Errorf("failed to list categories: %w", err)
+	}
+	return categories, nil
+}
+
+func (d *Database) GetCategory(name string) (*Category, error) {
+	category, err := d.findCategory(d.db, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get category %s: %w", name, err)
+	}
+	return category, nil
+}
+
+func (d *Database) CreateCategory(name, parent string) (*Category, error) {
+	category := Category{Ledger: d.ledger, Name: strings.ToLower(name)}
+	err := d.db.Transaction(func(db *gorm.DB) error {
+		if parent != "" {
+			p, err := d.findCategory(db, parent)
+			if err != nil {
+				return fmt.Errorf("parent %s: %w", parent, err)
+			}
+			category.ParentID = &p.ID
+		}
+		if err := d.checkAliasFree(db, category.Name); err != nil {
+			return err
+		}
+		if err := db.Create(&category).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrDuplicateCategory
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create category %s: %w", name, err)
+	}
+	return &category, nil
+}
+
+func (d *Database) RenameCategory(oldName, newName string, origin Origin) error {
+	oldName, newName = strings.ToLower(oldName), strings.ToLower(newName)
+	err := d.db.Transaction(func(db *gorm.DB) error {
+		category, err := d.findCategory(db, oldName)
+		if err != nil {
+			return err
+		}
+		if err := d.checkAliasFree(db, newName); err != nil {
+			return err
+		}
+		if err := db.Model(category).Update("name", newName).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrDuplicateCategory
+			}
+			return err
+		}
+
+		if err := db.Model(&Rule{}).Scopes(d.inLedger).Where("category = ?", oldName).Update("category", newName).Error; err != nil {
+			return err
+		}
+		if err := db.Model(&Budget{}).Scopes(d.inLedger).Where("category = ?", oldName).Update("category", newName).Error; err != nil {
+			return err
+		}
+
+		var transactions []Transaction
+		if err := db.Unscoped().Scopes(d.inLedger).Where("category = ?", oldName).Find(&transactions).Error; err != nil {
+			return err
+		}
+		for i := range transactions {
+			tx := &transactions[i]
+			before := snapshot(tx)
+			tx.Category = newName
+			if err := db.Unscoped().Model(tx).Update("category", newName).Error; err != nil {
+				return err
+			}
+			if err := recordAudit(db, ActionUpdate, tx, origin, before); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rename category %s: %w", oldName, err)
+	}
+	return nil
+}
+
+func (d *Database) SetCategoryArchived(name string, archived bool) error {
+	err := d.db.Transaction(func(db *gorm.DB) error {
+		category, err := d.findCategory(db, name)
+		if err != nil {
+			return err
+		}
+		return db.Model(category).Update("archived", archived).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive category %s: %w", name, err)
+	}
+	return nil
+}
+
+func (d *Database) AddCategoryAlias(alias, category string) error {
+	alias = strings.ToLower(alias)
+	err := d.db.Transaction(func(db *gorm.DB) error {
+		target, err := d.findCategory(db, category)
+		if err != nil {
+			return err
+		}
+		if _, err := d.findCategory(db, alias); err == nil {
+			return ErrDuplicateCategory
+		}
+		if err := db.Create(&CategoryAlias{Ledger: d.ledger, Alias: alias, CategoryID: target.ID}).Error; err != nil {
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrDuplicateCategory
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add alias %s: %w", alias, err)
+	}
+	return nil
+}
+
+func (d *Database) RemoveCategoryAlias(alias string) error {
+	result := d.db.Scopes(d.inLedger).Where("alias = ?", strings.ToLower(alias)).Delete(&CategoryAlias{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to remove alias %s: %w", alias, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to remove alias %s: %w", alias, ErrAliasNotFound)
+	}
+	return nil
+}
+
+func (d *Database) ListCategoryAliases() (map[string]string, error) {
+	var rows []struct {
+		Alias string
+		Name  string
+	}
+	err := d.db.Model(&CategoryAlias{}).
+		Select("category_aliases.alias, categories.name").
+		Joins("JOIN categories ON categories.id = category_aliases.category_id").
+		Where("category_aliases.ledger = ?", d.ledger).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list aliases: %w", err)
+	}
+
+	aliases := make(map[string]string, len(rows))
+	for _, row := range rows {
+		aliases[row.Alias] = row.Name
+	}
+	return aliases, nil
+}
+
+// checkAliasFree returns ErrDuplicateCategory if name is already an alias.
+func (d *Database) checkAliasFree(db *gorm.DB, name string) error {
+	var count int64
+	if err := db.Model(&CategoryAlias{}).Scopes(d.inLedger).Where("alias = ?", name).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrDuplicateCategory
+	}
+	return nil
+}
+
+func (d *Database) findCategory(db *gorm.DB, name string) (*Category, error) {
+	var category Category
+	if err := db.Scopes(d.inLedger).Where("name = ?", strings.ToLower(name)).First(&category).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, err
+	}
+	return &category, nil
+}
+
+// CategoryTree returns, for each category name, the names of the category
+// itself and all of its descendants. Summaries use it to roll children up
+// into their parents.
+func CategoryTree(categories []Category) map[string][]string {
+	byID := make(map[uint]Category)
+	for _, c := range categories {
+		byID[c.ID] = c
+	}
+
+	tree := make(map[string][]string)
+	for _, c := range categories {
+		tree[c.Name] = append(tree[c.Name], c.Name)
+		// Walk up the ancestors, guarding against accidental cycles
+		seen := map[uint]bool{c.ID: true}
+		for parentID := c.ParentID; parentID != nil && !seen[*parentID]; {
+			parent, ok := byID[*parentID]
+			if !ok {
+				break
+			}
+			seen[parent.ID] = true
+			tree[parent.Name] = append(tree[parent.Name], c.Name)
+			parentID = parent.ParentID
+		}
+	}
+	return tree
+}
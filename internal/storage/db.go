@@ -1,39 +1,260 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// Database is the SQL Store, bound to one ledger.
 type Database struct {
-	db *gorm.DB
+	db     *gorm.DB
+	ledger string
+}
+
+// legacyIndexes were unique on their own column before rows were split into
+// ledgers; the composite indexes on the models replace them.
+var legacyIndexes = []struct {
+	model any
+	name  string
+}{
+	{&Transaction{}, "idx_transactions_transaction_id"},
+	{&Category{}, "idx_categories_name"},
+	{&CategoryAlias{}, "idx_category_aliases_alias"},
+}
+
+// ledgerTables hold rows that belong to a ledger.
+var ledgerTables = []string{"transactions", "audit_entries", "categories", "category_aliases", "rules", "budgets", "scheduled_posts", "reminders"}
+
+// Open connects to the database for the given driver. For SQLite the source
+// is a file path, for Postgres it is a DSN or connection URL.
+func Open(driver, source string) (*Database, error) {
+	switch driver {
+	case DriverSQLite:
+		return NewDatabase(source)
+	case DriverPostgres:
+		return NewPostgresDatabase(source)
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", driver)
+	}
 }
 
 func NewDatabase(dbPath string) (*Database, error) {
-	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+	return openDatabase(sqlite.Open(dbPath))
+}
+
+func NewPostgresDatabase(dsn string) (*Database, error) {
+	return openDatabase(postgres.Open(dsn))
+}
+
+func openDatabase(dialector gorm.Dialector) (*Database, error) {
+	// TranslateError maps driver-specific constraint errors to gorm.ErrDuplicatedKey
+	db, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
-	if err := db.AutoMigrate(&Transaction{}); err != nil {
+	if err := db.AutoMigrate(&Transaction{}, &AuditEntry{}, &Category{}, &CategoryAlias{}, &Rule{}, &Budget{}, &ScheduledPost{}, &Reminder{}, &Household{}, &HouseholdMember{}); err != nil {
 		return nil, fmt.Errorf("failed to migrate schema: %w", err)
 	}
 
-	return &Database{db: db}, nil
+	for _, index := range legacyIndexes {
+		if db.Migrator().HasIndex(index.model, index.name) {
+			if err := db.Migrator().DropIndex(index.model, index.name); err != nil {
+				return nil, fmt.Errorf("failed to drop index %s: %w", index.name, err)
+			}
+		}
+	}
+	for _, table := range ledgerTables {
+		// Rows from before ledgers existed belong to the default one
+		if err := db.Table(table).Where("ledger IS NULL OR ledger = ''").Update("ledger", DefaultLedger).Error; err != nil {
+			return nil, fmt.Errorf("failed to backfill ledger of %s: %w", table, err)
+		}
+	}
+
+	d := &Database{db: db, ledger: DefaultLedger}
+	if err := d.seedCategories(); err != nil {
+		return nil, fmt.Errorf("failed to seed categories: %w", err)
+	}
+	if err := d.backfillOwners(); err != nil {
+		return nil, fmt.Errorf("failed to backfill transaction owners: %w", err)
+	}
+	return d, nil
+}
+
+func (d *Database) Ledger() string {
+	return d.ledger
+}
+
+func (d *Database) ForLedger(ledger string) (Store, error) {
+	view := &Database{db: d.db, ledger: ledger}
+	if err := view.seedCategories(); err != nil {
+		return nil, fmt.Errorf("failed to seed categories of ledger %s: %w", ledger, err)
+	}
+	return view, nil
 }
 
-func (d *Database) SaveTransaction(tx *Transaction) error {
-	if err := d.db.Create(tx).Error; err != nil {
+// inLedger is a gorm scope limiting a query to the store's ledger.
+func (d *Database) inLedger(db *gorm.DB) *gorm.DB {
+	return db.Where("ledger = ?", d.ledger)
+}
+
+// backfillOwners assigns transactions saved before ledgers were per user to
+// whoever created them, according to the audit log. Rows older than the
+// audit log keep an empty owner; the bot counts them as everyone's in the
+// default ledger they belong to.
+func (d *Database) backfillOwners() error {
+	return d.db.Exec(`UPDATE transactions SET user_id = (
+		SELECT actor FROM audit_entries
+		WHERE audit_entries.transaction_id = transactions.transaction_id AND audit_entries.ledger = transactions.ledger
+			AND audit_entries.action = ?
+		ORDER BY audit_entries.id LIMIT 1
+	) WHERE (user_id IS NULL OR user_id = '') AND EXISTS (
+		SELECT 1 FROM audit_entries
+		WHERE audit_entries.transaction_id = transactions.transaction_id AND audit_entries.ledger = transactions.ledger
+			AND audit_entries.action = ?
+	)`, ActionCreate, ActionCreate).Error
+}
+
+func (d *Database) SaveTransaction(tx *Transaction, origin Origin) error {
+	if tx.UserID == "" {
+		tx.UserID = origin.UserID
+	}
+	tx.Ledger = d.ledger
+	err := d.db.Transaction(func(db *gorm.DB) error {
+		if err := db.Create(tx).Error; err != nil {
+			return err
+		}
+		return recordAudit(db, ActionCreate, tx, origin, "")
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return fmt.Errorf("failed to save transaction %s: %w", tx.TransactionID, ErrDuplicateTransaction)
+		}
 		return fmt.Errorf("failed to save transaction: %w", err)
 	}
 	return nil
 }
 
+func (d *Database) UpdateTransaction(transactionID string, update TransactionUpdate, origin Origin) (*Transaction, error) {
+	var tx Transaction
+	err := d.db.Transaction(func(db *gorm.DB) error {
+		if err := db.Scopes(d.inLedger).Where("transaction_id = ?", transactionID).First(&tx).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTransactionNotFound
+			}
+			return err
+		}
+
+		before := snapshot(&tx)
+		update.apply(&tx)
+		if err := db.Model(&tx).Select("category", "reason", "tags").Updates(&tx).Error; err != nil {
+			return err
+		}
+
+		return recordAudit(db, ActionUpdate, &tx, origin, before)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update transaction %s: %w", transactionID, err)
+	}
+	return &tx, nil
+}
+
+func (d *Database) DeleteTransaction(transactionID string, origin Origin) (*Transaction, error) {
+	var tx Transaction
+	err := d.db.Transaction(func(db *gorm.DB) error {
+		if err := db.Scopes(d.inLedger).Where("transaction_id = ?", transactionID).First(&tx).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTransactionNotFound
+			}
+			return err
+		}
+
+		before := snapshot(&tx)
+		if err := db.Delete(&tx).Error; err != nil {
+			return err
+		}
+		// Reload through Unscoped to capture the DeletedAt stamp in the audit entry
+		if err := db.Unscoped().First(&tx, tx.ID).Error; err != nil {
+			return err
+		}
+		return recordAudit(db, ActionDelete, &tx, origin, before)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete transaction %s: %w", transactionID, err)
+	}
+	return &tx, nil
+}
+
+func (d *Database) RestoreTransaction(transactionID string, origin Origin) (*Transaction, error) {
+	var tx Transaction
+	err := d.db.Transaction(func(db *gorm.DB) error {
+		query := db.Unscoped().Scopes(d.inLedger).Where("transaction_id = ? AND deleted_at IS NOT NULL", transactionID)
+		if err := query.First(&tx).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTransactionNotFound
+			}
+			return err
+		}
+
+		before := snapshot(&tx)
+		if err := db.Unscoped().Model(&tx).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		tx.DeletedAt = gorm.DeletedAt{}
+		return recordAudit(db, ActionRestore, &tx, origin, before)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore transaction %s: %w", transactionID, err)
+	}
+	return &tx, nil
+}
+
+func (d *Database) GetDeletedTransactions(filter TransactionFilter) ([]Transaction, error) {
+	var transactions []Transaction
+	query := d.applyFilter(d.db.Model(&Transaction{}).Unscoped(), filter).Where("deleted_at IS NOT NULL").Order("deleted_at DESC")
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if err := query.Find(&transactions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get deleted transactions: %w", err)
+	}
+	return transactions, nil
+}
+
+func (d *Database) GetAuditEntries(transactionID string) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	query := d.db.Scopes(d.inLedger).Where("transaction_id = ?", transactionID).Order("created_at ASC").Order("id ASC")
+	if err := query.Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to get audit entries: %w", err)
+	}
+	return entries, nil
+}
+
+func recordAudit(db *gorm.DB, action string, tx *Transaction, origin Origin, before string) error {
+	return db.Create(&AuditEntry{
+		Ledger:        tx.Ledger,
+		TransactionID: tx.TransactionID,
+		Action:        action,
+		Actor:         origin.UserID,
+		Source:        origin.Source,
+		Before:        before,
+		After:         snapshot(tx),
+	}).Error
+}
+
 func (d *Database) GetTransactionsByCategory(category string) ([]Transaction, error) {
 	var transactions []Transaction
-	query := d.db.Where("category = ?", strings.ToLower(category)).Order("date_time DESC")
+	query := d.db.Scopes(d.inLedger).Where("category = ?", strings.ToLower(category)).Order("date_time DESC")
 	if err := query.Find(&transactions).Error; err != nil {
 		return nil, fmt.Errorf("failed to get transactions by category: %w", err)
 	}
@@ -42,7 +263,7 @@ func (d *Database) GetTransactionsByCategory(category string) ([]Transaction, er
 
 func (d *Database) GetAllTransactions() ([]Transaction, error) {
 	var transactions []Transaction
-	if err := d.db.Order("date_time DESC").Find(&transactions).Error; err != nil {
+	if err := d.db.Scopes(d.inLedger).Order("date_time DESC").Find(&transactions).Error; err != nil {
 		return nil, fmt.Errorf("failed to get all transactions: %w", err)
 	}
 	return transactions, nil
@@ -54,7 +275,7 @@ func (d *Database) GetCategorySummary() (map[string]float64, error) {
 		Total    float64
 	}
 
-	if err := d.db.Model(&Transaction{}).Select("category, SUM(amount) as total").Group("category").Scan(&results).Error; err != nil {
+	if err := d.db.Model(&Transaction{}).Scopes(d.inLedger).Select("category, SUM(amount) as total").Group("category").Scan(&results).Error; err != nil {
 		return nil, fmt.Errorf("failed to get category summary: %w", err)
 	}
 
@@ -65,3 +286,166 @@ func (d *Database) GetCategorySummary() (map[string]float64, error) {
 
 	return summary, nil
 }
+
+// FindTransactions returns the page of transactions matching the filter.
+func (d *Database) FindTransactions(filter TransactionFilter) ([]Transaction, error) {
+	var transactions []Transaction
+	query := d.applyFilter(d.db.Model(&Transaction{}), filter)
+
+	direction := "DESC"
+	if filter.Ascending {
+		direction = "ASC"
+	}
+	// Tie-break on id so pagination is stable
+	query = query.Order(filter.sortColumn() + " " + direction).Order("id " + direction)
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+
+	if err := query.Find(&transactions).Error; err != nil {
+		return nil, fmt.Errorf("failed to find transactions: %w", err)
+	}
+	return transactions, nil
+}
+
+// CountTransactions returns how many transactions match the filter, ignoring
+// its pagination fields.
+func (d *Database) CountTransactions(filter TransactionFilter) (int64, error) {
+	var count int64
+	if err := d.applyFilter(d.db.Model(&Transaction{}), filter).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count transactions: %w", err)
+	}
+	return count, nil
+}
+
+// SummarizeByCategory totals the amounts of matching transactions per category.
+func (d *Database) SummarizeByCategory(filter TransactionFilter) (map[string]float64, error) {
+	var results []struct {
+		Category string
+		Total    float64
+	}
+
+	query := d.applyFilter(d.db.Model(&Transaction{}), filter)
+	if err := query.Select("category, SUM(amount) as total").Group("category").Scan(&results).Error; err != nil {
+		return nil, fmt.Errorf("failed to summarize by category: %w", err)
+	}
+
+	summary := make(map[string]float64)
+	for _, result := range results {
+		summary[result.Category] = result.Total
+	}
+	return summary, nil
+}
+
+// AggregateByPeriod totals matching transactions per day, week, month or year,
+// oldest period first. Grouping happens in the database.
+func (d *Database) AggregateByPeriod(filter TransactionFilter, granularity Granularity) ([]PeriodTotal, error) {
+	var results []struct {
+		Period string
+		Total  float64
+		Cost   float64
+		Count  int64
+	}
+
+	period := d.periodExpression(granularity)
+	query := d.applyFilter(d.db.Model(&Transaction{}), filter).
+		Select(period + " as period, SUM(amount) as total, SUM(cost) as cost, COUNT(*) as count").
+		Group(period).
+		Order("period ASC")
+	if err := query.Scan(&results).Error; err != nil {
+		return nil, fmt.Errorf("failed to aggregate transactions: %w", err)
+	}
+
+	totals := make([]PeriodTotal, 0, len(results))
+	for _, result := range results {
+		start, err := time.Parse("2006-01-02", result.Period)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse period %q: %w", result.Period, err)
+		}
+		totals = append(totals, PeriodTotal{Period: start, Total: result.Total, Cost: result.Cost, Count: result.Count})
+	}
+	return totals, nil
+}
+
+// likeEscaper makes LIKE wildcards in user input match literally, with
+// backslash as the ESCAPE character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (d *Database) applyFilter(query *gorm.DB, filter TransactionFilter) *gorm.DB {
+	query = d.inLedger(query)
+	if !filter.From.IsZero() {
+		query = query.Where("date_time >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("date_time < ?", filter.To)
+	}
+	if filter.Category != "" {
+		query = query.Where("category = ?", strings.ToLower(filter.Category))
+	}
+	if len(filter.Categories) > 0 {
+		lowered := make([]string, len(filter.Categories))
+		for i, category := range filter.Categories {
+			lowered[i] = strings.ToLower(category)
+		}
+		query = query.Where("category IN ?", lowered)
+	}
+	if filter.Recipient != "" {
+		query = query.Where(`LOWER(recipient) LIKE ? ESCAPE '\'`, "%"+likeEscaper.Replace(strings.ToLower(filter.Recipient))+"%")
+	}
+	if filter.MinAmount > 0 {
+		query = query.Where("amount >= ?", filter.MinAmount)
+	}
+	if filter.MaxAmount > 0 {
+		query = query.Where("amount <= ?", filter.MaxAmount)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", strings.ToLower(filter.Type))
+	}
+	if len(filter.UserIDs) > 0 {
+		query = query.Where("user_id IN ?", filter.UserIDs)
+	}
+	if filter.MessageID != "" {
+		query = query.Where("message_id = ?", filter.MessageID)
+	}
+	if filter.TransactionID != "" {
+		query = query.Where("transaction_id = ?", filter.TransactionID)
+	}
+	for _, tag := range filter.Tags {
+		// Tags are stored comma-separated; wrap in commas to match whole tags only
+		query = query.Where(`(',' || tags || ',') LIKE ? ESCAPE '\'`, "%,"+likeEscaper.Replace(strings.ToLower(strings.TrimSpace(tag)))+",%")
+	}
+	return query
+}
+
+// periodExpression returns SQL yielding the period start as YYYY-MM-DD.
+// Periods are UTC in every store: SQLite's date functions convert to UTC, and
+// Postgres would otherwise truncate in the session's time zone.
+func (d *Database) periodExpression(granularity Granularity) string {
+	if d.db.Dialector.Name() == DriverPostgres {
+		unit := "day"
+		switch granularity {
+		case Week:
+			unit = "week"
+		case Month:
+			unit = "month"
+		case Year:
+			unit = "year"
+		}
+		return "to_char(date_trunc('" + unit + "', date_time AT TIME ZONE 'UTC'), 'YYYY-MM-DD')"
+	}
+
+	switch granularity {
+	case Week:
+		// Next Sunday (or today if Sunday) minus six days is the ISO week's Monday
+		return "date(date_time, 'weekday 0', '-6 days')"
+	case Month:
+		return "strftime('%Y-%m-01', date_time)"
+	case Year:
+		return "strftime('%Y-01-01', date_time)"
+	default:
+		return "date(date_time)"
+	}
+}
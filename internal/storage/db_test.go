@@ -0,0 +1,812 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	db, err := NewDatabase(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	return db
+}
+
+// testStores returns every Store implementation so behaviour can be checked
+// for parity.
+func testStores(t *testing.T) map[string]Store {
+	return map[string]Store{
+		"sqlite": newTestDatabase(t),
+		"memory": NewMemoryStore(),
+	}
+}
+
+// postgresStore returns a fresh ledger in the Postgres database named by
+// TEST_POSTGRES_DSN, or nil without one. The session runs in another time
+// zone than UTC, so time zone handling is exercised.
+func postgresStore(t *testing.T) Store {
+	t.Helper()
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		return nil
+	}
+	db, err := NewPostgresDatabase(dsn)
+	if err != nil {
+		t.Fatalf("failed to open postgres: %v", err)
+	}
+	sqlDB, err := db.db.DB()
+	if err != nil {
+		t.Fatalf("failed to open postgres: %v", err)
+	}
+	// One connection, so the session setting applies to every query
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.db.Exec("SET TIME ZONE 'Africa/Nairobi'").Error; err != nil {
+		t.Fatalf("failed to set the time zone: %v", err)
+	}
+	store, err := db.ForLedger(fmt.Sprintf("test-%d", time.Now().UnixNano()))
+	if err != nil {
+		t.Fatalf("failed to open ledger: %v", err)
+	}
+	return store
+}
+
+func at(day, hour int) time.Time {
+	return time.Date(2025, time.September, day, hour, 0, 0, 0, time.UTC)
+}
+
+func seed(t *testing.T, store TransactionStore) {
+	t.Helper()
+	txs := []Transaction{
+		{TransactionID: "T1", Amount: 25, Cost: 0, Recipient: "Caroline Mwania", DateTime: at(1, 9), Category: "food", Type: "sent", Tags: "water"},
+		{TransactionID: "T2", Amount: 40, Cost: 7, Recipient: "Divinah Nyabuto", DateTime: at(2, 19), Category: "food", Type: "sent", Tags: "lunch,work"},
+		{TransactionID: "T3", Amount: 300, Cost: 0, Recipient: "Super Metro Sacco", DateTime: at(8, 7), Category: "travel", Type: "paid", Tags: "work"},
+		{TransactionID: "T4", Amount: 1000, Cost: 13, Recipient: "Co-operative Bank", DateTime: at(15, 12), Category: "savings", Type: "sent"},
+		{TransactionID: "T5", Amount: 80, Cost: 0, Recipient: "Caroline Mwania", DateTime: time.Date(2025, time.October, 1, 8, 0, 0, 0, time.UTC), Category: "food", Type: "paid"},
+	}
+	for i := range txs {
+		if err := store.SaveTransaction(&txs[i], Origin{UserID: "user-1", Source: "test"}); err != nil {
+			t.Fatalf("failed to seed %s: %v", txs[i].TransactionID, err)
+		}
+	}
+}
+
+func ids(txs []Transaction) []string {
+	var out []string
+	for _, tx := range txs {
+		out = append(out, tx.TransactionID)
+	}
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSaveTransactionDuplicate(t *testing.T) {
+	for name, store := range testStores(t) {
+		tx := Transaction{TransactionID: "TIL4XR5BBM", Amount: 25, Category: "food", DateTime: time.Now()}
+		if err := store.SaveTransaction(&tx, Origin{}); err != nil {
+			t.Fatalf("%s: first save failed: %v", name, err)
+		}
+		dup := Transaction{TransactionID: "TIL4XR5BBM", Amount: 25, Category: "food", DateTime: time.Now()}
+		err := store.SaveTransaction(&dup, Origin{})
+		if !errors.Is(err, ErrDuplicateTransaction) {
+			t.Fatalf("%s: expected ErrDuplicateTransaction, got %v", name, err)
+		}
+	}
+}
+
+func TestFindTransactions(t *testing.T) {
+	cases := []struct {
+		name   string
+		filter TransactionFilter
+		want   []string
+	}{
+		{"all newest first", TransactionFilter{}, []string{"T5", "T4", "T3", "T2", "T1"}},
+		{"date range", TransactionFilter{From: at(2, 0), To: at(15, 0)}, []string{"T3", "T2"}},
+		{"category", TransactionFilter{Category: "Food"}, []string{"T5", "T2", "T1"}},
+		{"recipient substring", TransactionFilter{Recipient: "mwania"}, []string{"T5", "T1"}},
+		{"recipient wildcards are literal", TransactionFilter{Recipient: "co_op"}, nil},
+		{"recipient percent is literal", TransactionFilter{Recipient: "%"}, nil},
+		{"amount range", TransactionFilter{MinAmount: 40, MaxAmount: 300}, []string{"T5", "T3", "T2"}},
+		{"type", TransactionFilter{Type: "paid"}, []string{"T5", "T3"}},
+		{"tags", TransactionFilter{Tags: []string{"work"}}, []string{"T3", "T2"}},
+		{"all tags required", TransactionFilter{Tags: []string{"work", "lunch"}}, []string{"T2"}},
+		{"tag wildcards are literal", TransactionFilter{Tags: []string{"wor_"}}, nil},
+		{"sort by amount ascending", TransactionFilter{SortBy: SortByAmount, Ascending: true}, []string{"T1", "T2", "T5", "T3", "T4"}},
+		{"paginated", TransactionFilter{Limit: 2, Offset: 1}, []string{"T4", "T3"}},
+	}
+
+	for name, store := range testStores(t) {
+		seed(t, store)
+		for _, c := range cases {
+			got, err := store.FindTransactions(c.filter)
+			if err != nil {
+				t.Fatalf("%s/%s: %v", name, c.name, err)
+			}
+			if !equal(ids(got), c.want) {
+				t.Fatalf("%s/%s: want %v got %v", name, c.name, c.want, ids(got))
+			}
+		}
+
+		count, err := store.CountTransactions(TransactionFilter{Category: "food", Limit: 1})
+		if err != nil || count != 3 {
+			t.Fatalf("%s: expected count 3, got %d (%v)", name, count, err)
+		}
+	}
+}
+
+func TestAggregates(t *testing.T) {
+	for name, store := range testStores(t) {
+		seed(t, store)
+
+		summary, err := store.SummarizeByCategory(TransactionFilter{To: at(30, 0)})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if summary["food"] != 65 || summary["travel"] != 300 || summary["savings"] != 1000 {
+			t.Fatalf("%s: unexpected summary %v", name, summary)
+		}
+
+		months, err := store.AggregateByPeriod(TransactionFilter{}, Month)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(months) != 2 || months[0].Total != 1365 || months[0].Cost != 20 || months[0].Count != 4 || months[1].Total != 80 {
+			t.Fatalf("%s: unexpected monthly totals %+v", name, months)
+		}
+
+		// Sep 1 2025 is a Monday, so T1 and T2 share a week
+		weeks, err := store.AggregateByPeriod(TransactionFilter{Category: "food"}, Week)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(weeks) != 2 || !weeks[0].Period.Equal(at(1, 0)) || weeks[0].Total != 65 {
+			t.Fatalf("%s: unexpected weekly totals %+v", name, weeks)
+		}
+		if want := time.Date(2025, time.September, 29, 0, 0, 0, 0, time.UTC); !weeks[1].Period.Equal(want) {
+			t.Fatalf("%s: expected second week to start %v, got %v", name, want, weeks[1].Period)
+		}
+
+		days, err := store.AggregateByPeriod(TransactionFilter{From: at(1, 0), To: at(3, 0)}, Day)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(days) != 2 || !days[1].Period.Equal(at(2, 0)) || days[1].Total != 40 {
+			t.Fatalf("%s: unexpected daily totals %+v", name, days)
+		}
+
+		years, err := store.AggregateByPeriod(TransactionFilter{}, Year)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(years) != 1 || !years[0].Period.Equal(time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)) || years[0].Total != 1445 {
+			t.Fatalf("%s: unexpected yearly totals %+v", name, years)
+		}
+
+		// Comparing periods is two bounded summaries
+		september := PeriodStart(at(15, 0), Month)
+		current, err := store.SummarizeByCategory(TransactionFilter{From: PeriodEnd(september, Month), To: PeriodEnd(PeriodEnd(september, Month), Month)})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		previous, err := store.SummarizeByCategory(TransactionFilter{From: september, To: PeriodEnd(september, Month)})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(current) != 1 || current["food"] != 80 || previous["food"] != 65 || previous["savings"] != 1000 {
+			t.Fatalf("%s: unexpected period summaries %v and %v", name, current, previous)
+		}
+	}
+}
+
+func TestPeriodsAreUTC(t *testing.T) {
+	stores := testStores(t)
+	if store := postgresStore(t); store != nil {
+		stores["postgres"] = store
+	}
+	eat := time.FixedZone("EAT", 3*60*60)
+
+	for name, store := range stores {
+		// 01:00 EAT on Sep 1 is still Aug 31 in UTC
+		txs := []Transaction{
+			{TransactionID: "T1", Amount: 10, DateTime: time.Date(2025, time.September, 1, 1, 0, 0, 0, eat), Category: "food"},
+			{TransactionID: "T2", Amount: 20, DateTime: time.Date(2025, time.September, 1, 12, 0, 0, 0, eat), Category: "food"},
+		}
+		for i := range txs {
+			if err := store.SaveTransaction(&txs[i], Origin{UserID: "user-1"}); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		}
+
+		for granularity, want := range map[Granularity][]time.Time{
+			Day:   {time.Date(2025, time.August, 31, 0, 0, 0, 0, time.UTC), time.Date(2025, time.September, 1, 0, 0, 0, 0, time.UTC)},
+			Month: {time.Date(2025, time.August, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, time.September, 1, 0, 0, 0, 0, time.UTC)},
+		} {
+			totals, err := store.AggregateByPeriod(TransactionFilter{}, granularity)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if len(totals) != 2 || !totals[0].Period.Equal(want[0]) || !totals[1].Period.Equal(want[1]) || totals[0].Total != 10 {
+				t.Fatalf("%s: unexpected %s totals %+v", name, granularity, totals)
+			}
+		}
+	}
+}
+
+func TestDeleteAndRestore(t *testing.T) {
+	for name, store := range testStores(t) {
+		seed(t, store)
+
+		if _, err := store.DeleteTransaction("T2", Origin{UserID: "user-1", Source: "test"}); err != nil {
+			t.Fatalf("%s: delete failed: %v", name, err)
+		}
+		if _, err := store.DeleteTransaction("T2", Origin{UserID: "user-1", Source: "test"}); !errors.Is(err, ErrTransactionNotFound) {
+			t.Fatalf("%s: expected second delete to report not found, got %v", name, err)
+		}
+
+		live, _ := store.FindTransactions(TransactionFilter{Category: "food"})
+		if !equal(ids(live), []string{"T5", "T1"}) {
+			t.Fatalf("%s: deleted row still visible: %v", name, ids(live))
+		}
+		summary, _ := store.SummarizeByCategory(TransactionFilter{})
+		if summary["food"] != 105 {
+			t.Fatalf("%s: deleted row counted in summary: %v", name, summary)
+		}
+
+		// The unique index still covers trashed rows
+		again := Transaction{TransactionID: "T2", Amount: 40, DateTime: at(2, 19)}
+		if err := store.SaveTransaction(&again, Origin{}); !errors.Is(err, ErrDuplicateTransaction) {
+			t.Fatalf("%s: expected duplicate for trashed row, got %v", name, err)
+		}
+
+		trash, err := store.GetDeletedTransactions(TransactionFilter{Limit: 10})
+		if err != nil || !equal(ids(trash), []string{"T2"}) || !trash[0].DeletedAt.Valid {
+			t.Fatalf("%s: unexpected trash %v (%v)", name, ids(trash), err)
+		}
+		if trash, _ := store.GetDeletedTransactions(TransactionFilter{TransactionID: "T1"}); len(trash) != 0 {
+			t.Fatalf("%s: filter ignored in trash: %v", name, ids(trash))
+		}
+
+		restored, err := store.RestoreTransaction("T2", Origin{UserID: "user-1", Source: "test"})
+		if err != nil || restored.DeletedAt.Valid {
+			t.Fatalf("%s: restore failed: %+v (%v)", name, restored, err)
+		}
+		if count, _ := store.CountTransactions(TransactionFilter{}); count != 5 {
+			t.Fatalf("%s: expected 5 live rows after restore, got %d", name, count)
+		}
+		if _, err := store.RestoreTransaction("T2", Origin{UserID: "user-1", Source: "test"}); !errors.Is(err, ErrTransactionNotFound) {
+			t.Fatalf("%s: expected restoring a live row to fail, got %v", name, err)
+		}
+	}
+}
+
+func TestAuditTrail(t *testing.T) {
+	for name, store := range testStores(t) {
+		seed(t, store)
+
+		travel := "travel"
+		origin := Origin{UserID: "user-2", Source: "!edit"}
+		if _, err := store.UpdateTransaction("T1", TransactionUpdate{Category: &travel}, origin); err != nil {
+			t.Fatalf("%s: update failed: %v", name, err)
+		}
+		if _, err := store.DeleteTransaction("T1", Origin{UserID: "user-2", Source: "!delete"}); err != nil {
+			t.Fatalf("%s: delete failed: %v", name, err)
+		}
+
+		entries, err := store.GetAuditEntries("T1")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var actions []string
+		for _, entry := range entries {
+			actions = append(actions, entry.Action)
+		}
+		if !equal(actions, []string{ActionCreate, ActionUpdate, ActionDelete}) {
+			t.Fatalf("%s: unexpected actions %v", name, actions)
+		}
+
+		update := entries[1]
+		if update.Actor != "user-2" || update.Source != "!edit" {
+			t.Fatalf("%s: unexpected origin %+v", name, update)
+		}
+		before, _ := Snapshot(update.Before)
+		after, _ := Snapshot(update.After)
+		if before.Category != "food" || after.Category != "travel" {
+			t.Fatalf("%s: unexpected snapshots %+v -> %+v", name, before, after)
+		}
+		if created, _ := Snapshot(entries[0].Before); created != nil {
+			t.Fatalf("%s: create entry should have no before snapshot", name)
+		}
+	}
+}
+
+func TestCategories(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			seed(t, store)
+
+			if _, err := store.CreateCategory("Groceries", "food"); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+			if _, err := store.CreateCategory("groceries", ""); !errors.Is(err, ErrDuplicateCategory) {
+				t.Fatalf("expected ErrDuplicateCategory, got %v", err)
+			}
+			if _, err := store.CreateCategory("snacks", "missing"); !errors.Is(err, ErrCategoryNotFound) {
+				t.Fatalf("expected ErrCategoryNotFound for parent, got %v", err)
+			}
+
+			all, err := store.ListCategories(true)
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			if got := CategoryTree(all)["food"]; !equal(got, []string{"food", "groceries"}) {
+				t.Fatalf("food tree = %v", got)
+			}
+
+			if err := store.RenameCategory("food", "meals", Origin{UserID: "user-1", Source: "test"}); err != nil {
+				t.Fatalf("rename: %v", err)
+			}
+			txs, err := store.FindTransactions(TransactionFilter{Category: "meals", Ascending: true})
+			if err != nil {
+				t.Fatalf("find: %v", err)
+			}
+			if got := ids(txs); !equal(got, []string{"T1", "T2", "T5"}) {
+				t.Fatalf("renamed transactions = %v", got)
+			}
+			if entries, _ := store.GetAuditEntries("T1"); len(entries) != 2 || entries[1].Actor != "user-1" {
+				t.Fatalf("expected rename to be audited, got %+v", entries)
+			}
+			if err := store.RenameCategory("meals", "travel", Origin{}); !errors.Is(err, ErrDuplicateCategory) {
+				t.Fatalf("expected ErrDuplicateCategory on rename, got %v", err)
+			}
+
+			if err := store.SetCategoryArchived("travel", true); err != nil {
+				t.Fatalf("archive: %v", err)
+			}
+			active, _ := store.ListCategories(false)
+			for _, c := range active {
+				if c.Name == "travel" {
+					t.Fatal("archived category listed as active")
+				}
+			}
+			if c, err := store.GetCategory("TRAVEL"); err != nil || !c.Archived {
+				t.Fatalf("expected archived travel, got %+v, %v", c, err)
+			}
+		})
+	}
+}
+
+func TestCategoryAliases(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			aliases, err := store.ListCategoryAliases()
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			if aliases["fuel"] != "travel" {
+				t.Fatalf("expected default alias fuel -> travel, got %v", aliases)
+			}
+
+			if err := store.AddCategoryAlias("Matatu", "travel"); err != nil {
+				t.Fatalf("add: %v", err)
+			}
+			if err := store.AddCategoryAlias("matatu", "food"); !errors.Is(err, ErrDuplicateCategory) {
+				t.Fatalf("expected ErrDuplicateCategory for existing alias, got %v", err)
+			}
+			if err := store.AddCategoryAlias("food", "travel"); !errors.Is(err, ErrDuplicateCategory) {
+				t.Fatalf("expected ErrDuplicateCategory for category name, got %v", err)
+			}
+			if _, err := store.CreateCategory("matatu", ""); !errors.Is(err, ErrDuplicateCategory) {
+				t.Fatalf("expected category name to clash with alias, got %v", err)
+			}
+
+			// Aliases follow their category through a rename
+			if err := store.RenameCategory("travel", "transit", Origin{}); err != nil {
+				t.Fatalf("rename: %v", err)
+			}
+			aliases, _ = store.ListCategoryAliases()
+			if aliases["matatu"] != "transit" {
+				t.Fatalf("expected matatu -> transit, got %v", aliases)
+			}
+
+			if err := store.RemoveCategoryAlias("matatu"); err != nil {
+				t.Fatalf("remove: %v", err)
+			}
+			if err := store.RemoveCategoryAlias("matatu"); !errors.Is(err, ErrAliasNotFound) {
+				t.Fatalf("expected ErrAliasNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRules(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			first := Rule{Recipient: "mama mboga", Category: "Food", Reason: "vegetables"}
+			second := Rule{Account: "1082111", Category: "savings"}
+			for _, rule := range []*Rule{&first, &second} {
+				if err := store.CreateRule(rule); err != nil {
+					t.Fatalf("create: %v", err)
+				}
+			}
+
+			if err := store.RenameCategory("food", "groceries", Origin{}); err != nil {
+				t.Fatalf("rename: %v", err)
+			}
+			rules, err := store.ListRules()
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			if len(rules) != 2 || rules[0].ID != first.ID || rules[0].Category != "groceries" {
+				t.Fatalf("expected renamed category on the first rule, got %+v", rules)
+			}
+
+			if err := store.DeleteRule(first.ID); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+			if err := store.DeleteRule(first.ID); !errors.Is(err, ErrRuleNotFound) {
+				t.Fatalf("expected ErrRuleNotFound, got %v", err)
+			}
+			if rules, _ := store.ListRules(); len(rules) != 1 || rules[0].ID != second.ID {
+				t.Fatalf("expected only the second rule left, got %+v", rules)
+			}
+		})
+	}
+}
+
+func TestHouseholds(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, tx := range []Transaction{
+				{TransactionID: "A1", Amount: 10, Category: "food", UserID: "alice"},
+				{TransactionID: "B1", Amount: 20, Category: "food"},
+				{TransactionID: "C1", Amount: 40, Category: "food", UserID: "carol"},
+			} {
+				if err := store.SaveTransaction(&tx, Origin{UserID: "bob", Source: "test"}); err != nil {
+					t.Fatalf("save: %v", err)
+				}
+			}
+
+			if _, err := store.CreateHousehold("Family", "alice"); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+			if _, err := store.CreateHousehold("family", "bob"); !errors.Is(err, ErrDuplicateHousehold) {
+				t.Fatalf("expected ErrDuplicateHousehold, got %v", err)
+			}
+			if err := store.AcceptHouseholdInvite("family", "bob"); !errors.Is(err, ErrInviteNotFound) {
+				t.Fatalf("expected ErrInviteNotFound before an invitation, got %v", err)
+			}
+			if err := store.InviteHouseholdMember("family", "bob"); err != nil {
+				t.Fatalf("invite: %v", err)
+			}
+			if err := store.InviteHouseholdMember("family", "bob"); err != nil {
+				t.Fatalf("inviting twice should be a no-op: %v", err)
+			}
+
+			household, err := store.GetHousehold("FAMILY")
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			if got := household.MemberIDs(); !equal(got, []string{"alice"}) || !equal(household.InvitedIDs(), []string{"bob"}) {
+				t.Fatalf("expected bob to be invited only, got members %v and invited %v", got, household.InvitedIDs())
+			}
+			if households, _ := store.ListHouseholds("bob"); len(households) != 0 {
+				t.Fatalf("expected an invitation not to make bob a member, got %+v", households)
+			}
+			if invites, _ := store.ListHouseholdInvites("bob"); len(invites) != 1 || invites[0].Name != "family" {
+				t.Fatalf("expected bob's invitation to be listed, got %+v", invites)
+			}
+
+			if err := store.AcceptHouseholdInvite("family", "bob"); err != nil {
+				t.Fatalf("accept: %v", err)
+			}
+			household, _ = store.GetHousehold("family")
+			if got := household.MemberIDs(); !equal(got, []string{"alice", "bob"}) {
+				t.Fatalf("members = %v", got)
+			}
+			if err := store.InviteHouseholdMember("family", "bob"); err != nil {
+				t.Fatalf("invite member: %v", err)
+			}
+			if household, _ = store.GetHousehold("family"); len(household.InvitedIDs()) != 0 {
+				t.Fatalf("expected inviting a member to be a no-op, got invited %v", household.InvitedIDs())
+			}
+
+			// B1 has no explicit owner, so it belongs to whoever saved it
+			summary, err := store.SummarizeByCategory(TransactionFilter{UserIDs: household.MemberIDs()})
+			if err != nil {
+				t.Fatalf("summarize: %v", err)
+			}
+			if summary["food"] != 30 {
+				t.Fatalf("household food total = %v, want 30", summary["food"])
+			}
+
+			if households, _ := store.ListHouseholds("bob"); len(households) != 1 {
+				t.Fatalf("expected bob in one household, got %+v", households)
+			}
+			if err := store.RemoveHouseholdMember("family", "bob"); err != nil {
+				t.Fatalf("remove: %v", err)
+			}
+			if households, _ := store.ListHouseholds("bob"); len(households) != 0 {
+				t.Fatalf("expected bob to have left, got %+v", households)
+			}
+			if _, err := store.GetHousehold("nope"); !errors.Is(err, ErrHouseholdNotFound) {
+				t.Fatalf("expected ErrHouseholdNotFound, got %v", err)
+			}
+		})
+	}
+}
+
+func TestBackfillOwnersFromAudit(t *testing.T) {
+	db := newTestDatabase(t)
+	tx := Transaction{TransactionID: "OLD1", Amount: 10, Category: "food"}
+	if err := db.SaveTransaction(&tx, Origin{UserID: "alice", Source: "test"}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	// Simulate a row saved before transactions had owners
+	db.db.Model(&Transaction{}).Where("transaction_id = ?", "OLD1").Update("user_id", "")
+
+	if err := db.backfillOwners(); err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+	txs, _ := db.FindTransactions(TransactionFilter{UserIDs: []string{"alice"}})
+	if len(txs) != 1 {
+		t.Fatalf("expected OLD1 to be assigned to alice, got %+v", txs)
+	}
+}
+
+func TestLedgersAreIsolated(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			business, err := store.ForLedger("business")
+			if err != nil {
+				t.Fatalf("for ledger: %v", err)
+			}
+			if business.Ledger() != "business" || store.Ledger() != DefaultLedger {
+				t.Fatalf("ledgers = %s, %s", store.Ledger(), business.Ledger())
+			}
+
+			origin := Origin{UserID: "alice", Source: "test"}
+			// M-PESA IDs are unique per account, but the same SMS may be
+			// forwarded to two ledgers
+			for _, s := range []Store{store, business} {
+				if err := s.SaveTransaction(&Transaction{TransactionID: "X1", Amount: 10, Category: "food"}, origin); err != nil {
+					t.Fatalf("save in %s: %v", s.Ledger(), err)
+				}
+			}
+			if err := business.SaveTransaction(&Transaction{TransactionID: "X2", Amount: 5, Category: "food"}, origin); err != nil {
+				t.Fatalf("save: %v", err)
+			}
+
+			all, err := store.GetAllTransactions()
+			if err != nil || len(all) != 1 {
+				t.Fatalf("default ledger has %d transactions (%v), want 1", len(all), err)
+			}
+			if summary, _ := business.SummarizeByCategory(TransactionFilter{}); summary["food"] != 15 {
+				t.Fatalf("business food total = %v, want 15", summary["food"])
+			}
+
+			if _, err := business.DeleteTransaction("X1", origin); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+			if count, _ := store.CountTransactions(TransactionFilter{}); count != 1 {
+				t.Fatalf("deleting in one ledger touched the other")
+			}
+
+			// Each ledger starts from the default categories and evolves alone
+			if _, err := business.CreateCategory("payroll", ""); err != nil {
+				t.Fatalf("create category: %v", err)
+			}
+			if _, err := store.GetCategory("payroll"); !errors.Is(err, ErrCategoryNotFound) {
+				t.Fatalf("expected payroll to stay in business, got %v", err)
+			}
+			if _, err := store.CreateCategory("payroll", ""); err != nil {
+				t.Fatalf("same name in another ledger: %v", err)
+			}
+			if aliases, _ := business.ListCategoryAliases(); aliases["fare"] != "travel" {
+				t.Fatalf("business aliases = %v", aliases)
+			}
+
+			if err := business.CreateRule(&Rule{Recipient: "kplc", Category: "bills"}); err != nil {
+				t.Fatalf("create rule: %v", err)
+			}
+			if rules, _ := store.ListRules(); len(rules) != 0 {
+				t.Fatalf("default ledger sees rules %+v", rules)
+			}
+		})
+	}
+}
+
+func TestLegacyRowsJoinDefaultLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	db, err := NewDatabase(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	tx := Transaction{TransactionID: "OLD1", Amount: 10, Category: "food"}
+	if err := db.SaveTransaction(&tx, Origin{UserID: "alice", Source: "test"}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	// Simulate a row saved before transactions had ledgers
+	db.db.Exec("UPDATE transactions SET ledger = NULL")
+
+	reopened, err := NewDatabase(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if txs, _ := reopened.GetAllTransactions(); len(txs) != 1 {
+		t.Fatalf("expected OLD1 in the default ledger, got %+v", txs)
+	}
+}
+
+func TestFindByMessage(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, tx := range []Transaction{
+				{TransactionID: "A1", Amount: 10, MessageID: "m1"},
+				{TransactionID: "A2", Amount: 20, MessageID: "m1"},
+				{TransactionID: "B1", Amount: 30, MessageID: "m2"},
+			} {
+				if err := store.SaveTransaction(&tx, Origin{UserID: "alice", Source: "test"}); err != nil {
+					t.Fatalf("save: %v", err)
+				}
+			}
+			txs, err := store.FindTransactions(TransactionFilter{MessageID: "m1", SortBy: SortByAmount, Ascending: true})
+			if err != nil {
+				t.Fatalf("find: %v", err)
+			}
+			if got := ids(txs); !equal(got, []string{"A1", "A2"}) {
+				t.Fatalf("got %v", got)
+			}
+			txs, err = store.FindTransactions(TransactionFilter{TransactionID: "B1"})
+			if err != nil {
+				t.Fatalf("find: %v", err)
+			}
+			if got := ids(txs); !equal(got, []string{"B1"}) {
+				t.Fatalf("got %v", got)
+			}
+		})
+	}
+}
+
+func TestBudgets(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			food := Budget{Category: "Food", Period: Month, Amount: 500, CreatedBy: "user-1"}
+			if err := store.SetBudget(&food); err != nil {
+				t.Fatalf("set: %v", err)
+			}
+			if err := store.SetBudget(&Budget{Category: "travel", Period: Week, Amount: 300}); err != nil {
+				t.Fatalf("set: %v", err)
+			}
+
+			// Setting it again replaces the amount instead of adding a budget
+			raised := Budget{Category: "food", Period: Month, Amount: 800, CreatedBy: "user-2"}
+			if err := store.SetBudget(&raised); err != nil {
+				t.Fatalf("replace: %v", err)
+			}
+			if raised.ID != food.ID {
+				t.Fatalf("expected budget %d to be replaced, got %d", food.ID, raised.ID)
+			}
+
+			if err := store.RenameCategory("food", "groceries", Origin{}); err != nil {
+				t.Fatalf("rename: %v", err)
+			}
+			budgets, err := store.ListBudgets()
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			if len(budgets) != 2 || budgets[0].Category != "groceries" || budgets[0].Amount != 800 || budgets[1].Period != Week {
+				t.Fatalf("unexpected budgets %+v", budgets)
+			}
+
+			// Budgets belong to their ledger
+			other, _ := store.ForLedger("other")
+			if budgets, _ := other.ListBudgets(); len(budgets) != 0 {
+				t.Fatalf("expected no budgets in another ledger, got %+v", budgets)
+			}
+
+			if err := store.DeleteBudget("travel", Month); !errors.Is(err, ErrBudgetNotFound) {
+				t.Fatalf("expected ErrBudgetNotFound, got %v", err)
+			}
+			if err := store.DeleteBudget("Travel", Week); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+			if budgets, _ := store.ListBudgets(); len(budgets) != 1 {
+				t.Fatalf("expected one budget left, got %+v", budgets)
+			}
+		})
+	}
+}
+
+func TestScheduledPosts(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			last, err := store.LastScheduledPost("digest:day")
+			if err != nil || !last.IsZero() {
+				t.Fatalf("expected no post yet, got %v, %v", last, err)
+			}
+
+			for _, day := range []int{2, 3, 1, 3} {
+				if err := store.RecordScheduledPost("digest:day", at(day, 0)); err != nil {
+					t.Fatalf("record: %v", err)
+				}
+			}
+			if err := store.RecordScheduledPost("digest:week", at(8, 0)); err != nil {
+				t.Fatalf("record: %v", err)
+			}
+
+			if last, _ := store.LastScheduledPost("digest:day"); !last.Equal(at(3, 0)) {
+				t.Fatalf("expected the latest daily post to cover %v, got %v", at(3, 0), last)
+			}
+			other, _ := store.ForLedger("other")
+			if last, _ := other.LastScheduledPost("digest:day"); !last.IsZero() {
+				t.Fatalf("expected posts to belong to their ledger, got %v", last)
+			}
+		})
+	}
+}
+
+func TestReminders(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.GetReminder("user-1"); !errors.Is(err, ErrReminderNotFound) {
+				t.Fatalf("expected ErrReminderNotFound, got %v", err)
+			}
+
+			first := Reminder{UserID: "user-1", ChannelID: "channel-1", Days: 3, Since: at(1, 9)}
+			if err := store.SetReminder(&first); err != nil {
+				t.Fatalf("set: %v", err)
+			}
+			update := Reminder{UserID: "user-1", ChannelID: "channel-2", Days: 5, DM: true, Since: at(9, 9)}
+			if err := store.SetReminder(&update); err != nil {
+				t.Fatalf("set again: %v", err)
+			}
+			if update.ID != first.ID || !update.Since.Equal(at(1, 9)) {
+				t.Fatalf("expected the reminder to be updated in place keeping Since, got %+v", update)
+			}
+
+			got, err := store.GetReminder("user-1")
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			if got.ChannelID != "channel-2" || got.Days != 5 || !got.DM {
+				t.Fatalf("unexpected reminder %+v", got)
+			}
+
+			other, _ := store.ForLedger("dm:user-2")
+			if err := other.SetReminder(&Reminder{UserID: "user-2", ChannelID: "dm-2", Days: 1}); err != nil {
+				t.Fatalf("set in other ledger: %v", err)
+			}
+			if _, err := other.GetReminder("user-1"); !errors.Is(err, ErrReminderNotFound) {
+				t.Fatalf("expected reminders to belong to their ledger, got %v", err)
+			}
+			all, err := store.ListReminders()
+			if err != nil || len(all) != 2 || all[0].Ledger != DefaultLedger || all[1].Ledger != "dm:user-2" {
+				t.Fatalf("expected the reminders of every ledger, got %+v, %v", all, err)
+			}
+
+			if err := store.DeleteReminder("user-1"); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+			if err := store.DeleteReminder("user-1"); !errors.Is(err, ErrReminderNotFound) {
+				t.Fatalf("expected ErrReminderNotFound, got %v", err)
+			}
+		})
+	}
+}
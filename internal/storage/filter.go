@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"strings"
+	"time"
+)
+
+// Granularity is the bucket size used by period aggregates.
+type Granularity string
+
+const (
+	Day   Granularity = "day"
+	Week  Granularity = "week"
+	Month Granularity = "month"
+	Year  Granularity = "year"
+)
+
+// Sort fields accepted by TransactionFilter.SortBy.
+const (
+	SortByDate      = "date_time"
+	SortByAmount    = "amount"
+	SortByRecipient = "recipient"
+	SortByCategory  = "category"
+)
+
+// TransactionFilter narrows down transaction queries. Zero values mean "no
+// constraint", so TransactionFilter{} matches every row, newest first.
+type TransactionFilter struct {
+	// From is inclusive and To is exclusive.
+	From time.Time
+	To   time.Time
+
+	Category string
+	// Categories matches any of the listed categories, e.g. a parent and its
+	// children.
+	Categories []string
+	// Recipient matches case-insensitively anywhere in the recipient name.
+	Recipient string
+	MinAmount float64
+	MaxAmount float64
+	// Type is the M-PESA direction, e.g. "sent" or "paid".
+	Type string
+	// Tags must all be present on a transaction for it to match.
+	Tags []string
+	// UserIDs limits the query to the ledgers of these Discord users.
+	UserIDs []string
+	// MessageID limits the query to transactions logged from one Discord
+	// message.
+	MessageID string
+	// TransactionID limits the query to one transaction.
+	TransactionID string
+
+	Limit  int
+	Offset int
+	// SortBy is one of the SortBy* constants, defaulting to SortByDate.
+	SortBy    string
+	Ascending bool
+}
+
+// PeriodTotal is one bucket of an aggregate query.
+type PeriodTotal struct {
+	Period time.Time
+	Total  float64
+	Cost   float64
+	Count  int64
+}
+
+// PeriodStart truncates t to the start of its day, ISO week (Monday), month
+// or year.
+func PeriodStart(t time.Time, g Granularity) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch g {
+	case Week:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case Month:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	case Year:
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return day
+	}
+}
+
+// PeriodEnd returns the start of the period following the one starting at
+// start, i.e. the exclusive end to use as TransactionFilter.To.
+func PeriodEnd(start time.Time, g Granularity) time.Time {
+	switch g {
+	case Week:
+		return start.AddDate(0, 0, 7)
+	case Month:
+		return start.AddDate(0, 1, 0)
+	case Year:
+		return start.AddDate(1, 0, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// JoinTags normalises tags into the comma-separated form stored on a
+// transaction.
+func JoinTags(tags []string) string {
+	var cleaned []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		cleaned = append(cleaned, tag)
+	}
+	return strings.Join(cleaned, ",")
+}
+
+// SplitTags is the inverse of JoinTags.
+func SplitTags(tags string) []string {
+	if tags == "" {
+		return nil
+	}
+	return strings.Split(tags, ",")
+}
+
+func (f TransactionFilter) sortColumn() string {
+	switch f.SortBy {
+	case SortByAmount, SortByRecipient, SortByCategory:
+		return f.SortBy
+	default:
+		return SortByDate
+	}
+}
+
+// matches reports whether tx satisfies every constraint in the filter. It is
+// the in-memory counterpart of Database.applyFilter.
+func (f TransactionFilter) matches(tx Transaction) bool {
+	if !f.From.IsZero() && tx.DateTime.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !tx.DateTime.Before(f.To) {
+		return false
+	}
+	if f.Category != "" && tx.Category != strings.ToLower(f.Category) {
+		return false
+	}
+	if len(f.Categories) > 0 {
+		found := false
+		for _, category := range f.Categories {
+			if tx.Category == strings.ToLower(category) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Recipient != "" && !strings.Contains(strings.ToLower(tx.Recipient), strings.ToLower(f.Recipient)) {
+		return false
+	}
+	if f.MinAmount > 0 && tx.Amount < f.MinAmount {
+		return false
+	}
+	if f.MaxAmount > 0 && tx.Amount > f.MaxAmount {
+		return false
+	}
+	if f.Type != "" && tx.Type != strings.ToLower(f.Type) {
+		return false
+	}
+	if len(f.UserIDs) > 0 {
+		found := false
+		for _, userID := range f.UserIDs {
+			if tx.UserID == userID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.MessageID != "" && tx.MessageID != f.MessageID {
+		return false
+	}
+	if f.TransactionID != "" && tx.TransactionID != f.TransactionID {
+		return false
+	}
+	if len(f.Tags) > 0 {
+		have := make(map[string]bool)
+		for _, tag := range SplitTags(tx.Tags) {
+			have[tag] = true
+		}
+		for _, tag := range f.Tags {
+			if !have[strings.ToLower(strings.TrimSpace(tag))] {
+				return false
+			}
+		}
+	}
+	return true
+}
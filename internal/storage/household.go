@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (d *Database) CreateHousehold(name, createdBy string) (*Household, error) {
+	household := Household{
+		Name:      strings.ToLower(name),
+		CreatedBy: createdBy,
+		Members:   []HouseholdMember{{UserID: createdBy}},
+	}
+	if err := d.db.Create(&household).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, fmt.Errorf("failed to create household %s: %w", name, ErrDuplicateHousehold)
+		}
+		return nil, fmt.Errorf("failed to create household %s: %w", name, err)
+	}
+	return &household, nil
+}
+
+func (d *Database) GetHousehold(name string) (*Household, error) {
+	household, err := findHousehold(d.db.Preload("Members", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}), name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get household %s: %w", name, err)
+	}
+	return household, nil
+}
+
+func (d *Database) ListHouseholds(userID string) ([]Household, error) {
+	households, err := d.householdsOf(userID, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list households: %w", err)
+	}
+	return households, nil
+}
+
+func (d *Database) ListHouseholdInvites(userID string) ([]Household, error) {
+	households, err := d.householdsOf(userID, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list household invitations: %w", err)
+	}
+	return households, nil
+}
+
+// householdsOf returns the households userID is a member of, or invited to
+// when pending is set.
+func (d *Database) householdsOf(userID string, pending bool) ([]Household, error) {
+	var households []Household
+	err := d.db.
+		Preload("Members", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Where("id IN (?)", d.db.Model(&HouseholdMember{}).Select("household_id").Where("user_id = ? AND pending = ?", userID, pending)).
+		Order("name ASC").
+		Find(&households).Error
+	return households, err
+}
+
+func (d *Database) InviteHouseholdMember(name, userID string) error {
+	household, err := findHousehold(d.db, name)
+	if err != nil {
+		return fmt.Errorf("failed to invite member to household %s: %w", name, err)
+	}
+	member := HouseholdMember{HouseholdID: household.ID, UserID: userID, Pending: true}
+	if err := d.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&member).Error; err != nil {
+		return fmt.Errorf("failed to invite member to household %s: %w", name, err)
+	}
+	return nil
+}
+
+func (d *Database) AcceptHouseholdInvite(name, userID string) error {
+	household, err := findHousehold(d.db, name)
+	if err != nil {
+		return fmt.Errorf("failed to join household %s: %w", name, err)
+	}
+	var member HouseholdMember
+	err = d.db.Where("household_id = ? AND user_id = ?", household.ID, userID).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to join household %s: %w", name, ErrInviteNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to join household %s: %w", name, err)
+	}
+	if err := d.db.Model(&member).Update("pending", false).Error; err != nil {
+		return fmt.Errorf("failed to join household %s: %w", name, err)
+	}
+	return nil
+}
+
+func (d *Database) RemoveHouseholdMember(name, userID string) error {
+	household, err := findHousehold(d.db, name)
+	if err != nil {
+		return fmt.Errorf("failed to remove member from household %s: %w", name, err)
+	}
+	if err := d.db.Where("household_id = ? AND user_id = ?", household.ID, userID).Delete(&HouseholdMember{}).Error; err != nil {
+		return fmt.Errorf("failed to remove member from household %s: %w", name, err)
+	}
+	return nil
+}
+
+func findHousehold(db *gorm.DB, name string) (*Household, error) {
+	var household Household
+	if err := db.Where("name = ?", strings.ToLower(name)).First(&household).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrHouseholdNotFound
+		}
+		return nil, err
+	}
+	return &household, nil
+}
@@ -0,0 +1,822 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MemoryStore is an in-process Store. It mirrors the behaviour of the SQL
+// store closely enough for handler tests, including rejecting duplicate
+// transaction IDs (even of deleted rows) and soft deletes.
+type MemoryStore struct {
+	// memoryLedger holds the data of the ledger this view is bound to.
+	*memoryLedger
+	ledger string
+	shared *memoryShared
+}
+
+type memoryLedger struct {
+	mu           sync.RWMutex
+	nextID       uint
+	transactions []Transaction
+	audit        []AuditEntry
+	categories   []Category
+	aliases      []CategoryAlias
+	rules        []Rule
+	nextRuleID   uint
+	budgets      []Budget
+	nextBudgetID uint
+	posts        []ScheduledPost
+}
+
+// memoryShared is what all ledgers of a MemoryStore have in common.
+type memoryShared struct {
+	mu         sync.RWMutex
+	ledgers    map[string]*memoryLedger
+	households []Household
+	// reminders are listed across ledgers, so they are kept here.
+	reminders      []Reminder
+	nextReminderID uint
+}
+
+// NewMemoryStore returns an empty store bound to DefaultLedger.
+func NewMemoryStore() *MemoryStore {
+	m := &MemoryStore{shared: &memoryShared{ledgers: make(map[string]*memoryLedger), nextReminderID: 1}}
+	return m.forLedger(DefaultLedger)
+}
+
+func (m *MemoryStore) Ledger() string {
+	return m.ledger
+}
+
+func (m *MemoryStore) ForLedger(ledger string) (Store, error) {
+	return m.forLedger(ledger), nil
+}
+
+func (m *MemoryStore) forLedger(ledger string) *MemoryStore {
+	m.shared.mu.Lock()
+	data, ok := m.shared.ledgers[ledger]
+	if !ok {
+		data = &memoryLedger{nextID: 1, nextRuleID: 1, nextBudgetID: 1}
+		m.shared.ledgers[ledger] = data
+	}
+	m.shared.mu.Unlock()
+
+	view := &MemoryStore{memoryLedger: data, ledger: ledger, shared: m.shared}
+	if !ok {
+		for _, name := range DefaultCategories {
+			view.CreateCategory(name, "")
+		}
+		for alias, category := range DefaultAliases {
+			view.AddCategoryAlias(alias, category)
+		}
+	}
+	return view
+}
+
+func (m *MemoryStore) SaveTransaction(tx *Transaction, origin Origin) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.transactions {
+		if existing.TransactionID == tx.TransactionID {
+			return fmt.Errorf("failed to save transaction %s: %w", tx.TransactionID, ErrDuplicateTransaction)
+		}
+	}
+
+	if tx.UserID == "" {
+		tx.UserID = origin.UserID
+	}
+	tx.Ledger = m.ledger
+	now := time.Now()
+	tx.ID = m.nextID
+	tx.CreatedAt = now
+	tx.UpdatedAt = now
+	m.nextID++
+	m.transactions = append(m.transactions, *tx)
+	m.record(ActionCreate, tx, origin, "")
+	return nil
+}
+
+func (m *MemoryStore) UpdateTransaction(transactionID string, update TransactionUpdate, origin Origin) (*Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx := m.find(transactionID, false)
+	if tx == nil {
+		return nil, fmt.Errorf("failed to update transaction %s: %w", transactionID, ErrTransactionNotFound)
+	}
+	before := snapshot(tx)
+	update.apply(tx)
+	tx.UpdatedAt = time.Now()
+	m.record(ActionUpdate, tx, origin, before)
+	updated := *tx
+	return &updated, nil
+}
+
+func (m *MemoryStore) DeleteTransaction(transactionID string, origin Origin) (*Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx := m.find(transactionID, false)
+	if tx == nil {
+		return nil, fmt.Errorf("failed to delete transaction %s: %w", transactionID, ErrTransactionNotFound)
+	}
+	before := snapshot(tx)
+	tx.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	m.record(ActionDelete, tx, origin, before)
+	deleted := *tx
+	return &deleted, nil
+}
+
+func (m *MemoryStore) RestoreTransaction(transactionID string, origin Origin) (*Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx := m.find(transactionID, true)
+	if tx == nil {
+		return nil, fmt.Errorf("failed to restore transaction %s: %w", transactionID, ErrTransactionNotFound)
+	}
+	before := snapshot(tx)
+	tx.DeletedAt = gorm.DeletedAt{}
+	m.record(ActionRestore, tx, origin, before)
+	restored := *tx
+	return &restored, nil
+}
+
+func (m *MemoryStore) GetDeletedTransactions(filter TransactionFilter) ([]Transaction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var transactions []Transaction
+	for _, tx := range m.transactions {
+		if tx.DeletedAt.Valid && filter.matches(tx) {
+			transactions = append(transactions, tx)
+		}
+	}
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].DeletedAt.Time.After(transactions[j].DeletedAt.Time)
+	})
+	if filter.Limit > 0 && filter.Limit < len(transactions) {
+		transactions = transactions[:filter.Limit]
+	}
+	return transactions, nil
+}
+
+// find returns the stored transaction with the given ID, either a live one or,
+// when deleted is true, one in the trash.
+func (m *MemoryStore) find(transactionID string, deleted bool) *Transaction {
+	for i := range m.transactions {
+		tx := &m.transactions[i]
+		if tx.TransactionID == transactionID && tx.DeletedAt.Valid == deleted {
+			return tx
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetAuditEntries(transactionID string) ([]AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var entries []AuditEntry
+	for _, entry := range m.audit {
+		if entry.TransactionID == transactionID {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (m *MemoryStore) record(action string, tx *Transaction, origin Origin, before string) {
+	m.audit = append(m.audit, AuditEntry{
+		ID:            uint(len(m.audit) + 1),
+		CreatedAt:     time.Now(),
+		Ledger:        m.ledger,
+		TransactionID: tx.TransactionID,
+		Action:        action,
+		Actor:         origin.UserID,
+		Source:        origin.Source,
+		Before:        before,
+		After:         snapshot(tx),
+	})
+}
+
+// live returns the transactions that have not been soft-deleted.
+func (m *MemoryStore) live() []Transaction {
+	var transactions []Transaction
+	for _, tx := range m.transactions {
+		if !tx.DeletedAt.Valid {
+			transactions = append(transactions, tx)
+		}
+	}
+	return transactions
+}
+
+func (m *MemoryStore) GetTransactionsByCategory(category string) ([]Transaction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	category = strings.ToLower(category)
+	var transactions []Transaction
+	for _, tx := range m.live() {
+		if tx.Category == category {
+			transactions = append(transactions, tx)
+		}
+	}
+	sortByDateDesc(transactions)
+	return transactions, nil
+}
+
+func (m *MemoryStore) GetAllTransactions() ([]Transaction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	transactions := m.live()
+	sortByDateDesc(transactions)
+	return transactions, nil
+}
+
+func (m *MemoryStore) GetCategorySummary() (map[string]float64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	summary := make(map[string]float64)
+	for _, tx := range m.live() {
+		summary[tx.Category] += tx.Amount
+	}
+	return summary, nil
+}
+
+func sortByDateDesc(transactions []Transaction) {
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].DateTime.After(transactions[j].DateTime)
+	})
+}
+
+func (m *MemoryStore) FindTransactions(filter TransactionFilter) ([]Transaction, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	transactions := m.filtered(filter)
+	column := filter.sortColumn()
+	sort.SliceStable(transactions, func(i, j int) bool {
+		a, b := transactions[i], transactions[j]
+		if filter.Ascending {
+			a, b = b, a
+		}
+		switch column {
+		case SortByAmount:
+			if a.Amount != b.Amount {
+				return a.Amount > b.Amount
+			}
+		case SortByRecipient:
+			if a.Recipient != b.Recipient {
+				return a.Recipient > b.Recipient
+			}
+		case SortByCategory:
+			if a.Category != b.Category {
+				return a.Category > b.Category
+			}
+		default:
+			if !a.DateTime.Equal(b.DateTime) {
+				return a.DateTime.After(b.DateTime)
+			}
+		}
+		return a.ID > b.ID
+	})
+
+	if filter.Offset > 0 {
+		if filter.Offset >= len(transactions) {
+			return nil, nil
+		}
+		transactions = transactions[filter.Offset:]
+	}
+	if filter.Limit > 0 && filter.Limit < len(transactions) {
+		transactions = transactions[:filter.Limit]
+	}
+	return transactions, nil
+}
+
+func (m *MemoryStore) CountTransactions(filter TransactionFilter) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return int64(len(m.filtered(filter))), nil
+}
+
+func (m *MemoryStore) SummarizeByCategory(filter TransactionFilter) (map[string]float64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	summary := make(map[string]float64)
+	for _, tx := range m.filtered(filter) {
+		summary[tx.Category] += tx.Amount
+	}
+	return summary, nil
+}
+
+func (m *MemoryStore) AggregateByPeriod(filter TransactionFilter, granularity Granularity) ([]PeriodTotal, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	byPeriod := make(map[time.Time]*PeriodTotal)
+	for _, tx := range m.filtered(filter) {
+		start := PeriodStart(tx.DateTime.UTC(), granularity)
+		total, ok := byPeriod[start]
+		if !ok {
+			total = &PeriodTotal{Period: start}
+			byPeriod[start] = total
+		}
+		total.Total += tx.Amount
+		total.Cost += tx.Cost
+		total.Count++
+	}
+
+	totals := make([]PeriodTotal, 0, len(byPeriod))
+	for _, total := range byPeriod {
+		totals = append(totals, *total)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Period.Before(totals[j].Period) })
+	return totals, nil
+}
+
+func (m *MemoryStore) filtered(filter TransactionFilter) []Transaction {
+	var transactions []Transaction
+	for _, tx := range m.live() {
+		if filter.matches(tx) {
+			transactions = append(transactions, tx)
+		}
+	}
+	return transactions
+}
+
+func (m *MemoryStore) ListCategories(includeArchived bool) ([]Category, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var categories []Category
+	for _, c := range m.categories {
+		if includeArchived || !c.Archived {
+			categories = append(categories, c)
+		}
+	}
+	return categories, nil
+}
+
+func (m *MemoryStore) GetCategory(name string) (*Category, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	c := m.findCategory(name)
+	if c == nil {
+		return nil, fmt.Errorf("failed to get category %s: %w", name, ErrCategoryNotFound)
+	}
+	category := *c
+	return &category, nil
+}
+
+func (m *MemoryStore) CreateCategory(name, parent string) (*Category, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	category := Category{Ledger: m.ledger, Name: strings.ToLower(name)}
+	if m.findCategory(category.Name) != nil || m.findAlias(category.Name) >= 0 {
+		return nil, fmt.Errorf("failed to create category %s: %w", name, ErrDuplicateCategory)
+	}
+	if parent != "" {
+		p := m.findCategory(parent)
+		if p == nil {
+			return nil, fmt.Errorf("failed to create category %s: parent %s: %w", name, parent, ErrCategoryNotFound)
+		}
+		category.ParentID = &p.ID
+	}
+
+	category.ID = uint(len(m.categories) + 1)
+	category.CreatedAt = time.Now()
+	category.UpdatedAt = category.CreatedAt
+	m.categories = append(m.categories, category)
+	return &category, nil
+}
+
+func (m *MemoryStore) RenameCategory(oldName, newName string, origin Origin) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	oldName, newName = strings.ToLower(oldName), strings.ToLower(newName)
+	c := m.findCategory(oldName)
+	if c == nil {
+		return fmt.Errorf("failed to rename category %s: %w", oldName, ErrCategoryNotFound)
+	}
+	if m.findCategory(newName) != nil || m.findAlias(newName) >= 0 {
+		return fmt.Errorf("failed to rename category %s: %w", oldName, ErrDuplicateCategory)
+	}
+	c.Name = newName
+	c.UpdatedAt = time.Now()
+	for i := range m.rules {
+		if m.rules[i].Category == oldName {
+			m.rules[i].Category = newName
+		}
+	}
+	for i := range m.budgets {
+		if m.budgets[i].Category == oldName {
+			m.budgets[i].Category = newName
+		}
+	}
+
+	for i := range m.transactions {
+		tx := &m.transactions[i]
+		if tx.Category != oldName {
+			continue
+		}
+		before := snapshot(tx)
+		tx.Category = newName
+		m.record(ActionUpdate, tx, origin, before)
+	}
+	return nil
+}
+
+func (m *MemoryStore) SetCategoryArchived(name string, archived bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := m.findCategory(name)
+	if c == nil {
+		return fmt.Errorf("failed to archive category %s: %w", name, ErrCategoryNotFound)
+	}
+	c.Archived = archived
+	c.UpdatedAt = time.Now()
+	return nil
+}
+
+func (m *MemoryStore) AddCategoryAlias(alias, category string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	alias = strings.ToLower(alias)
+	target := m.findCategory(category)
+	if target == nil {
+		return fmt.Errorf("failed to add alias %s: %w", alias, ErrCategoryNotFound)
+	}
+	if m.findCategory(alias) != nil || m.findAlias(alias) >= 0 {
+		return fmt.Errorf("failed to add alias %s: %w", alias, ErrDuplicateCategory)
+	}
+	m.aliases = append(m.aliases, CategoryAlias{
+		ID:         uint(len(m.aliases) + 1),
+		CreatedAt:  time.Now(),
+		Ledger:     m.ledger,
+		Alias:      alias,
+		CategoryID: target.ID,
+	})
+	return nil
+}
+
+func (m *MemoryStore) RemoveCategoryAlias(alias string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.findAlias(alias)
+	if i < 0 {
+		return fmt.Errorf("failed to remove alias %s: %w", alias, ErrAliasNotFound)
+	}
+	m.aliases = append(m.aliases[:i], m.aliases[i+1:]...)
+	return nil
+}
+
+func (m *MemoryStore) ListCategoryAliases() (map[string]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	aliases := make(map[string]string, len(m.aliases))
+	for _, a := range m.aliases {
+		for _, c := range m.categories {
+			if c.ID == a.CategoryID {
+				aliases[a.Alias] = c.Name
+			}
+		}
+	}
+	return aliases, nil
+}
+
+// findAlias returns the index of alias in m.aliases, or -1.
+func (m *MemoryStore) findAlias(alias string) int {
+	alias = strings.ToLower(alias)
+	for i, a := range m.aliases {
+		if a.Alias == alias {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *MemoryStore) findCategory(name string) *Category {
+	name = strings.ToLower(name)
+	for i := range m.categories {
+		if m.categories[i].Name == name {
+			return &m.categories[i]
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) CreateRule(rule *Rule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rule.ID = m.nextRuleID
+	m.nextRuleID++
+	rule.CreatedAt = time.Now()
+	rule.Ledger = m.ledger
+	rule.Category = strings.ToLower(rule.Category)
+	m.rules = append(m.rules, *rule)
+	return nil
+}
+
+func (m *MemoryStore) ListRules() ([]Rule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]Rule(nil), m.rules...), nil
+}
+
+func (m *MemoryStore) DeleteRule(id uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, rule := range m.rules {
+		if rule.ID == id {
+			m.rules = append(m.rules[:i], m.rules[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to delete rule %d: %w", id, ErrRuleNotFound)
+}
+
+func (m *MemoryStore) SetBudget(budget *Budget) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	budget.Ledger = m.ledger
+	budget.Category = strings.ToLower(budget.Category)
+	for i := range m.budgets {
+		existing := &m.budgets[i]
+		if existing.Category == budget.Category && existing.Period == budget.Period {
+			existing.Amount = budget.Amount
+			existing.CreatedBy = budget.CreatedBy
+			existing.UpdatedAt = time.Now()
+			*budget = *existing
+			return nil
+		}
+	}
+
+	budget.ID = m.nextBudgetID
+	m.nextBudgetID++
+	budget.CreatedAt = time.Now()
+	budget.UpdatedAt = budget.CreatedAt
+	m.budgets = append(m.budgets, *budget)
+	return nil
+}
+
+func (m *MemoryStore) ListBudgets() ([]Budget, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]Budget(nil), m.budgets...), nil
+}
+
+func (m *MemoryStore) DeleteBudget(category string, period Granularity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	category = strings.ToLower(category)
+	for i, budget := range m.budgets {
+		if budget.Category == category && budget.Period == period {
+			m.budgets = append(m.budgets[:i], m.budgets[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to delete %s budget of %s: %w", period, category, ErrBudgetNotFound)
+}
+
+func (m *MemoryStore) LastScheduledPost(key string) (time.Time, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var last time.Time
+	for _, post := range m.posts {
+		if post.Key == key && post.Covered.After(last) {
+			last = post.Covered
+		}
+	}
+	return last, nil
+}
+
+func (m *MemoryStore) RecordScheduledPost(key string, covered time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, post := range m.posts {
+		if post.Key == key && post.Covered.Equal(covered) {
+			return nil
+		}
+	}
+	m.posts = append(m.posts, ScheduledPost{CreatedAt: time.Now(), Ledger: m.ledger, Key: key, Covered: covered})
+	return nil
+}
+
+func (m *MemoryStore) SetReminder(reminder *Reminder) error {
+	m.shared.mu.Lock()
+	defer m.shared.mu.Unlock()
+
+	reminder.Ledger = m.ledger
+	if existing := m.findReminder(reminder.UserID); existing != nil {
+		existing.ChannelID = reminder.ChannelID
+		existing.Days = reminder.Days
+		existing.DM = reminder.DM
+		existing.UpdatedAt = time.Now()
+		*reminder = *existing
+		return nil
+	}
+
+	reminder.ID = m.shared.nextReminderID
+	m.shared.nextReminderID++
+	reminder.CreatedAt = time.Now()
+	reminder.UpdatedAt = reminder.CreatedAt
+	m.shared.reminders = append(m.shared.reminders, *reminder)
+	return nil
+}
+
+func (m *MemoryStore) GetReminder(userID string) (*Reminder, error) {
+	m.shared.mu.RLock()
+	defer m.shared.mu.RUnlock()
+
+	reminder := m.findReminder(userID)
+	if reminder == nil {
+		return nil, fmt.Errorf("failed to get reminder of %s: %w", userID, ErrReminderNotFound)
+	}
+	found := *reminder
+	return &found, nil
+}
+
+func (m *MemoryStore) DeleteReminder(userID string) error {
+	m.shared.mu.Lock()
+	defer m.shared.mu.Unlock()
+
+	for i, reminder := range m.shared.reminders {
+		if reminder.Ledger == m.ledger && reminder.UserID == userID {
+			m.shared.reminders = append(m.shared.reminders[:i], m.shared.reminders[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to delete reminder of %s: %w", userID, ErrReminderNotFound)
+}
+
+func (m *MemoryStore) ListReminders() ([]Reminder, error) {
+	m.shared.mu.RLock()
+	defer m.shared.mu.RUnlock()
+
+	return append([]Reminder(nil), m.shared.reminders...), nil
+}
+
+// findReminder returns the user's reminder in this ledger. The caller must
+// hold the shared lock.
+func (m *MemoryStore) findReminder(userID string) *Reminder {
+	for i := range m.shared.reminders {
+		if m.shared.reminders[i].Ledger == m.ledger && m.shared.reminders[i].UserID == userID {
+			return &m.shared.reminders[i]
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) CreateHousehold(name, createdBy string) (*Household, error) {
+	m.shared.mu.Lock()
+	defer m.shared.mu.Unlock()
+
+	name = strings.ToLower(name)
+	if m.findHousehold(name) != nil {
+		return nil, fmt.Errorf("failed to create household %s: %w", name, ErrDuplicateHousehold)
+	}
+	household := Household{
+		ID:        uint(len(m.shared.households) + 1),
+		CreatedAt: time.Now(),
+		Name:      name,
+		CreatedBy: createdBy,
+		Members:   []HouseholdMember{{UserID: createdBy}},
+	}
+	m.shared.households = append(m.shared.households, household)
+	return copyHousehold(household), nil
+}
+
+func (m *MemoryStore) GetHousehold(name string) (*Household, error) {
+	m.shared.mu.RLock()
+	defer m.shared.mu.RUnlock()
+
+	h := m.findHousehold(name)
+	if h == nil {
+		return nil, fmt.Errorf("failed to get household %s: %w", name, ErrHouseholdNotFound)
+	}
+	return copyHousehold(*h), nil
+}
+
+func (m *MemoryStore) ListHouseholds(userID string) ([]Household, error) {
+	m.shared.mu.RLock()
+	defer m.shared.mu.RUnlock()
+
+	return m.householdsOf(userID, false), nil
+}
+
+func (m *MemoryStore) ListHouseholdInvites(userID string) ([]Household, error) {
+	m.shared.mu.RLock()
+	defer m.shared.mu.RUnlock()
+
+	return m.householdsOf(userID, true), nil
+}
+
+// householdsOf returns the households userID is a member of, or invited to
+// when pending is set. The caller must hold the shared lock.
+func (m *MemoryStore) householdsOf(userID string, pending bool) []Household {
+	var households []Household
+	for _, h := range m.shared.households {
+		for _, member := range h.Members {
+			if member.UserID == userID && member.Pending == pending {
+				households = append(households, *copyHousehold(h))
+				break
+			}
+		}
+	}
+	sort.Slice(households, func(i, j int) bool { return households[i].Name < households[j].Name })
+	return households
+}
+
+func (m *MemoryStore) InviteHouseholdMember(name, userID string) error {
+	m.shared.mu.Lock()
+	defer m.shared.mu.Unlock()
+
+	h := m.findHousehold(name)
+	if h == nil {
+		return fmt.Errorf("failed to invite member to household %s: %w", name, ErrHouseholdNotFound)
+	}
+	for _, member := range h.Members {
+		if member.UserID == userID {
+			return nil
+		}
+	}
+	h.Members = append(h.Members, HouseholdMember{HouseholdID: h.ID, UserID: userID, Pending: true})
+	return nil
+}
+
+func (m *MemoryStore) AcceptHouseholdInvite(name, userID string) error {
+	m.shared.mu.Lock()
+	defer m.shared.mu.Unlock()
+
+	h := m.findHousehold(name)
+	if h == nil {
+		return fmt.Errorf("failed to join household %s: %w", name, ErrHouseholdNotFound)
+	}
+	for i := range h.Members {
+		if h.Members[i].UserID == userID {
+			h.Members[i].Pending = false
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to join household %s: %w", name, ErrInviteNotFound)
+}
+
+func (m *MemoryStore) RemoveHouseholdMember(name, userID string) error {
+	m.shared.mu.Lock()
+	defer m.shared.mu.Unlock()
+
+	h := m.findHousehold(name)
+	if h == nil {
+		return fmt.Errorf("failed to remove member from household %s: %w", name, ErrHouseholdNotFound)
+	}
+	for i, member := range h.Members {
+		if member.UserID == userID {
+			h.Members = append(h.Members[:i], h.Members[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) findHousehold(name string) *Household {
+	name = strings.ToLower(name)
+	for i := range m.shared.households {
+		if m.shared.households[i].Name == name {
+			return &m.shared.households[i]
+		}
+	}
+	return nil
+}
+
+// copyHousehold detaches the member slice from the store's copy.
+func copyHousehold(h Household) *Household {
+	h.Members = append([]HouseholdMember(nil), h.Members...)
+	return &h
+}
@@ -1,15 +1,23 @@
 package storage
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"gorm.io/gorm"
 )
 
-// Transaction represents a stored financial transaction.
+// DefaultLedger is the ledger of a single-channel setup and of rows saved
+// before ledgers existed.
+const DefaultLedger = "default"
+
+// Transaction represents a stored financial transaction. Transaction IDs are
+// unique within a ledger.
 type Transaction struct {
 	gorm.Model
-	TransactionID string `gorm:"uniqueIndex"`
+	Ledger        string `gorm:"uniqueIndex:idx_ledger_transaction_id,priority:1"`
+	TransactionID string `gorm:"uniqueIndex:idx_ledger_transaction_id,priority:2"`
 	Amount        float64
 	Recipient     string
 	DateTime      time.Time
@@ -17,4 +25,216 @@ type Transaction struct {
 	Cost          float64
 	Category      string
 	Reason        string
+	// Type is the M-PESA direction, "sent" or "paid".
+	Type string
+	// Tags is a comma-separated list, see JoinTags.
+	Tags string
+	// Account is the paybill account number, if any.
+	Account string
+	// UserID is the Discord user whose ledger the transaction belongs to.
+	UserID string `gorm:"index"`
+	// MessageID is the Discord message the transaction was logged from, so
+	// editing or deleting that message can follow through.
+	MessageID string `gorm:"index"`
+}
+
+// Category is a user-managed spending category. ParentID links it under
+// another category for roll-up summaries. Archived categories keep their
+// transactions but can no longer be assigned.
+type Category struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Ledger    string `gorm:"uniqueIndex:idx_ledger_category_name,priority:1"`
+	Name      string `gorm:"uniqueIndex:idx_ledger_category_name,priority:2"`
+	ParentID  *uint
+	Archived  bool
+}
+
+// DefaultCategories are created when the categories table is empty.
+var DefaultCategories = []string{"food", "travel", "savings", "church", "investments"}
+
+// CategoryAlias is an alternative name that resolves to a category, e.g.
+// "fuel" for travel. Aliases and category names share one namespace.
+type CategoryAlias struct {
+	ID         uint `gorm:"primaryKey"`
+	CreatedAt  time.Time
+	Ledger     string `gorm:"uniqueIndex:idx_ledger_alias,priority:1"`
+	Alias      string `gorm:"uniqueIndex:idx_ledger_alias,priority:2"`
+	CategoryID uint   `gorm:"index"`
+}
+
+// Rule files transactions that arrive without a category. Conditions left
+// empty or zero are ignored; package rules does the matching.
+type Rule struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	Ledger    string `gorm:"index"`
+	// Recipient matches case-insensitively anywhere in the recipient name.
+	Recipient string
+	Account   string
+	MinAmount float64
+	MaxAmount float64
+	// TimeFrom and TimeTo are "15:04" clock times. TimeTo is exclusive and
+	// the window may wrap past midnight.
+	TimeFrom  string
+	TimeTo    string
+	Category  string
+	Reason    string
+	CreatedBy string
+}
+
+// Budget caps what a ledger spends on a category, its subcategories
+// included, per week, month or year. A ledger has at most one budget per
+// category and period.
+type Budget struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Ledger    string      `gorm:"uniqueIndex:idx_ledger_budget,priority:1"`
+	Category  string      `gorm:"uniqueIndex:idx_ledger_budget,priority:2"`
+	Period    Granularity `gorm:"uniqueIndex:idx_ledger_budget,priority:3"`
+	Amount    float64
+	CreatedBy string
+}
+
+// ScheduledPost records that a recurring post, such as the daily digest,
+// went out for a period, so a restart neither repeats nor skips it. Key
+// names the post, e.g. "digest:day".
+type ScheduledPost struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	Ledger    string `gorm:"uniqueIndex:idx_ledger_scheduled_post,priority:1"`
+	Key       string `gorm:"uniqueIndex:idx_ledger_scheduled_post,priority:2"`
+	// Covered is the start of the period the post reported on.
+	Covered time.Time `gorm:"uniqueIndex:idx_ledger_scheduled_post,priority:3"`
+}
+
+// Reminder opts a user in to being nudged when they log nothing for Days
+// days, or when their balances show that transactions were missed. A user
+// has at most one reminder per ledger.
+type Reminder struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Ledger    string `gorm:"uniqueIndex:idx_ledger_reminder,priority:1"`
+	UserID    string `gorm:"uniqueIndex:idx_ledger_reminder,priority:2"`
+	// ChannelID is where the reminder was set up and, unless DM is set,
+	// where the nudges are posted.
+	ChannelID string
+	Days      int
+	DM        bool
+	// Since is when the user opted in, in M-PESA time like
+	// Transaction.DateTime. Nothing before it triggers a nudge.
+	Since time.Time
+}
+
+// Household groups Discord users whose ledgers can be viewed together.
+type Household struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	Name      string `gorm:"uniqueIndex"`
+	CreatedBy string
+	Members   []HouseholdMember
+}
+
+// HouseholdMember puts a Discord user in a household. A user who was
+// invited stays Pending, and cannot be seen by the household, until they
+// accept.
+type HouseholdMember struct {
+	ID          uint   `gorm:"primaryKey"`
+	HouseholdID uint   `gorm:"uniqueIndex:idx_household_member"`
+	UserID      string `gorm:"uniqueIndex:idx_household_member"`
+	// Pending defaults to false so members from before invitations existed
+	// stay members.
+	Pending bool `gorm:"default:false"`
+}
+
+// MemberIDs returns the Discord user IDs of the members who accepted.
+func (h Household) MemberIDs() []string {
+	var ids []string
+	for _, m := range h.Members {
+		if !m.Pending {
+			ids = append(ids, m.UserID)
+		}
+	}
+	return ids
+}
+
+// InvitedIDs returns the Discord user IDs of the pending invitations.
+func (h Household) InvitedIDs() []string {
+	var ids []string
+	for _, m := range h.Members {
+		if m.Pending {
+			ids = append(ids, m.UserID)
+		}
+	}
+	return ids
+}
+
+// DefaultAliases are created together with DefaultCategories.
+var DefaultAliases = map[string]string{
+	"transport": "travel",
+	"fare":      "travel",
+	"fuel":      "travel",
+	"lunch":     "food",
+	"supper":    "food",
+	"tithe":     "church",
+	"offering":  "church",
+	"saving":    "savings",
+}
+
+// Audit actions recorded in AuditEntry.Action.
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+)
+
+// Origin identifies who made a change and through which command.
+type Origin struct {
+	// UserID is the Discord user ID.
+	UserID string
+	// Source is the command or event that caused the change, e.g. "message",
+	// "batch" or "!edit".
+	Source string
+}
+
+// AuditEntry records one create, update, delete or restore of a stored
+// transaction. Entries are append-only: nothing in this package updates or
+// removes them. Before and After hold JSON snapshots of the transaction and
+// are empty when the row did not exist.
+type AuditEntry struct {
+	ID            uint `gorm:"primaryKey"`
+	CreatedAt     time.Time
+	Ledger        string
+	TransactionID string `gorm:"index"`
+	Action        string
+	// Actor is the Discord user ID that made the change.
+	Actor  string
+	Source string
+	Before string
+	After  string
+}
+
+// Snapshot decodes a JSON snapshot taken for an audit entry.
+func Snapshot(data string) (*Transaction, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var tx Transaction
+	if err := json.Unmarshal([]byte(data), &tx); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	return &tx, nil
+}
+
+// snapshot serialises a transaction for an audit entry.
+func snapshot(tx *Transaction) string {
+	data, err := json.Marshal(tx)
+	if err != nil {
+		return ""
+	}
+	return string(data)
 }
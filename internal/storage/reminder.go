@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+func (d *Database) SetReminder(reminder *Reminder) error {
+	reminder.Ledger = d.ledger
+	err := d.db.Transaction(func(db *gorm.DB) error {
+		var existing Reminder
+		err := db.Scopes(d.inLedger).Where("user_id = ?", reminder.UserID).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return db.Create(reminder).Error
+		}
+		if err != nil {
+			return err
+		}
+		existing.ChannelID = reminder.ChannelID
+		existing.Days = reminder.Days
+		existing.DM = reminder.DM
+		if err := db.Save(&existing).Error; err != nil {
+			return err
+		}
+		*reminder = existing
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set reminder of %s: %w", reminder.UserID, err)
+	}
+	return nil
+}
+
+func (d *Database) GetReminder(userID string) (*Reminder, error) {
+	var reminder Reminder
+	err := d.db.Scopes(d.inLedger).Where("user_id = ?", userID).First(&reminder).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get reminder of %s: %w", userID, ErrReminderNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reminder of %s: %w", userID, err)
+	}
+	return &reminder, nil
+}
+
+func (d *Database) DeleteReminder(userID string) error {
+	result := d.db.Scopes(d.inLedger).Where("user_id = ?", userID).Delete(&Reminder{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete reminder of %s: %w", userID, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to delete reminder of %s: %w", userID, ErrReminderNotFound)
+	}
+	return nil
+}
+
+func (d *Database) ListReminders() ([]Reminder, error) {
+	var reminders []Reminder
+	if err := d.db.Order("id ASC").Find(&reminders).Error; err != nil {
+		return nil, fmt.Errorf("failed to list reminders: %w", err)
+	}
+	return reminders, nil
+}
@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+func (d *Database) CreateRule(rule *Rule) error {
+	rule.Ledger = d.ledger
+	rule.Category = strings.ToLower(rule.Category)
+	if err := d.db.Create(rule).Error; err != nil {
+		return fmt.Errorf("failed to create rule: %w", err)
+	}
+	return nil
+}
+
+func (d *Database) ListRules() ([]Rule, error) {
+	var rules []Rule
+	if err := d.db.Scopes(d.inLedger).Order("id ASC").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to list rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (d *Database) DeleteRule(id uint) error {
+	result := d.db.Scopes(d.inLedger).Delete(&Rule{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete rule %d: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to delete rule %d: %w", id, ErrRuleNotFound)
+	}
+	return nil
+}
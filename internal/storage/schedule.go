@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+func (d *Database) LastScheduledPost(key string) (time.Time, error) {
+	var posts []ScheduledPost
+	if err := d.db.Scopes(d.inLedger).Where("key = ?", key).Order("covered DESC").Limit(1).Find(&posts).Error; err != nil {
+		return time.Time{}, fmt.Errorf("failed to look up scheduled post %s: %w", key, err)
+	}
+	if len(posts) == 0 {
+		return time.Time{}, nil
+	}
+	return posts[0].Covered, nil
+}
+
+func (d *Database) RecordScheduledPost(key string, covered time.Time) error {
+	post := ScheduledPost{Ledger: d.ledger, Key: key, Covered: covered}
+	if err := d.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&post).Error; err != nil {
+		return fmt.Errorf("failed to record scheduled post %s: %w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package storage
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrDuplicateTransaction is returned by SaveTransaction when a transaction
+// with the same TransactionID has already been stored.
+var ErrDuplicateTransaction = errors.New("transaction already exists")
+
+// ErrTransactionNotFound is returned when no stored transaction has the
+// requested TransactionID.
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+// ErrCategoryNotFound is returned when no category has the requested name.
+var ErrCategoryNotFound = errors.New("category not found")
+
+// ErrDuplicateCategory is returned when creating or renaming to a name that
+// is already taken.
+var ErrDuplicateCategory = errors.New("category already exists")
+
+// ErrAliasNotFound is returned when removing an alias that does not exist.
+var ErrAliasNotFound = errors.New("alias not found")
+
+// ErrRuleNotFound is returned when no rule has the requested ID.
+var ErrRuleNotFound = errors.New("rule not found")
+
+// ErrBudgetNotFound is returned when a category has no budget for the
+// requested period.
+var ErrBudgetNotFound = errors.New("budget not found")
+
+// ErrReminderNotFound is returned when a user has no reminder in the ledger.
+var ErrReminderNotFound = errors.New("reminder not found")
+
+// ErrHouseholdNotFound is returned when no household has the requested name.
+var ErrHouseholdNotFound = errors.New("household not found")
+
+// ErrInviteNotFound is returned when accepting an invitation to a household
+// the user was not invited to.
+var ErrInviteNotFound = errors.New("invitation not found")
+
+// ErrDuplicateHousehold is returned when creating a household whose name is
+// already taken.
+var ErrDuplicateHousehold = errors.New("household already exists")
+
+// TransactionUpdate lists the user-editable fields of a transaction. Nil
+// fields are left unchanged.
+type TransactionUpdate struct {
+	Category *string
+	Reason   *string
+	Tags     *string
+}
+
+func (u TransactionUpdate) apply(tx *Transaction) {
+	if u.Category != nil {
+		tx.Category = strings.ToLower(*u.Category)
+	}
+	if u.Reason != nil {
+		tx.Reason = *u.Reason
+	}
+	if u.Tags != nil {
+		tx.Tags = *u.Tags
+	}
+}
+
+// TransactionStore is the persistence contract the Discord bot depends on.
+// Database is the SQL implementation (SQLite or Postgres); MemoryStore keeps
+// everything in process and is used by tests.
+//
+// Every mutation takes the Origin of the change and appends an AuditEntry in
+// the same database transaction.
+type TransactionStore interface {
+	SaveTransaction(tx *Transaction, origin Origin) error
+	GetTransactionsByCategory(category string) ([]Transaction, error)
+	GetAllTransactions() ([]Transaction, error)
+	GetCategorySummary() (map[string]float64, error)
+
+	FindTransactions(filter TransactionFilter) ([]Transaction, error)
+	CountTransactions(filter TransactionFilter) (int64, error)
+	SummarizeByCategory(filter TransactionFilter) (map[string]float64, error)
+	AggregateByPeriod(filter TransactionFilter, granularity Granularity) ([]PeriodTotal, error)
+
+	// UpdateTransaction applies the update, keeping the previous values in
+	// the audit log.
+	UpdateTransaction(transactionID string, update TransactionUpdate, origin Origin) (*Transaction, error)
+	// DeleteTransaction soft-deletes a transaction, moving it to the trash.
+	DeleteTransaction(transactionID string, origin Origin) (*Transaction, error)
+	// RestoreTransaction brings a transaction back out of the trash.
+	RestoreTransaction(transactionID string, origin Origin) (*Transaction, error)
+	// GetDeletedTransactions lists the trash matching the filter, most
+	// recently deleted first. Of its pagination and sorting fields, only
+	// Limit applies.
+	GetDeletedTransactions(filter TransactionFilter) ([]Transaction, error)
+	// GetAuditEntries returns the history of a transaction, oldest first.
+	GetAuditEntries(transactionID string) ([]AuditEntry, error)
+}
+
+// CategoryStore manages the user-defined categories. Names are stored
+// lowercase and looked up case-insensitively.
+type CategoryStore interface {
+	// ListCategories returns categories in creation order.
+	ListCategories(includeArchived bool) ([]Category, error)
+	GetCategory(name string) (*Category, error)
+	// CreateCategory adds a category, optionally under an existing parent.
+	CreateCategory(name, parent string) (*Category, error)
+	// RenameCategory renames a category and moves its transactions along,
+	// auditing each moved transaction under the given origin.
+	RenameCategory(oldName, newName string, origin Origin) error
+	SetCategoryArchived(name string, archived bool) error
+
+	// AddCategoryAlias makes alias resolve to category. It fails with
+	// ErrDuplicateCategory if the alias is already a category or alias.
+	AddCategoryAlias(alias, category string) error
+	RemoveCategoryAlias(alias string) error
+	// ListCategoryAliases maps each alias to its category name.
+	ListCategoryAliases() (map[string]string, error)
+}
+
+// RuleStore keeps the auto-categorization rules. Renaming a category also
+// renames it in the rules that assign it.
+type RuleStore interface {
+	CreateRule(rule *Rule) error
+	// ListRules returns rules in creation order.
+	ListRules() ([]Rule, error)
+	DeleteRule(id uint) error
+}
+
+// BudgetStore keeps the spending caps of a ledger. Renaming a category also
+// renames it in its budgets.
+type BudgetStore interface {
+	// SetBudget creates the budget of a category and period, or replaces the
+	// amount of the existing one.
+	SetBudget(budget *Budget) error
+	// ListBudgets returns budgets in creation order.
+	ListBudgets() ([]Budget, error)
+	DeleteBudget(category string, period Granularity) error
+}
+
+// ScheduleStore remembers which recurring posts went out.
+type ScheduleStore interface {
+	// LastScheduledPost returns the latest period a post was recorded for,
+	// or the zero time if it never went out.
+	LastScheduledPost(key string) (time.Time, error)
+	// RecordScheduledPost marks the post for the period starting at covered
+	// as sent. Recording the same period twice is a no-op.
+	RecordScheduledPost(key string, covered time.Time) error
+}
+
+// ReminderStore keeps the users who opted in to reminders. Unlike the rest
+// of a ledger's data, reminders are listed across all ledgers, so the
+// scheduler can reach private ledgers nobody wrote to since a restart.
+type ReminderStore interface {
+	// SetReminder creates the user's reminder or replaces its channel, days
+	// and delivery, keeping Since.
+	SetReminder(reminder *Reminder) error
+	GetReminder(userID string) (*Reminder, error)
+	DeleteReminder(userID string) error
+	// ListReminders returns the reminders of every ledger in creation order.
+	ListReminders() ([]Reminder, error)
+}
+
+// HouseholdStore groups users for shared ledger views. Names are stored
+// lowercase; the creator becomes the first member. Others join by accepting
+// an invitation, so nobody's ledger is shared without their consent.
+type HouseholdStore interface {
+	CreateHousehold(name, createdBy string) (*Household, error)
+	// GetHousehold returns the household with its members and invitations.
+	GetHousehold(name string) (*Household, error)
+	// ListHouseholds returns the households userID is a member of.
+	ListHouseholds(userID string) ([]Household, error)
+	// ListHouseholdInvites returns the households userID is invited to.
+	ListHouseholdInvites(userID string) ([]Household, error)
+	// InviteHouseholdMember is a no-op if the user is already a member or
+	// invited.
+	InviteHouseholdMember(name, userID string) error
+	// AcceptHouseholdInvite makes an invited user a member. It fails with
+	// ErrInviteNotFound if they were not invited, and is a no-op for members.
+	AcceptHouseholdInvite(name, userID string) error
+	// RemoveHouseholdMember removes a member or withdraws an invitation.
+	RemoveHouseholdMember(name, userID string) error
+}
+
+// Store is everything the bot persists. A Store is bound to one ledger:
+// transactions, categories, aliases, rules, budgets, scheduled posts and
+// reminders belong to a ledger, while households are shared by all of them.
+type Store interface {
+	TransactionStore
+	CategoryStore
+	RuleStore
+	BudgetStore
+	ScheduleStore
+	ReminderStore
+	HouseholdStore
+
+	// Ledger returns the name of the ledger this store is bound to.
+	Ledger() string
+	// ForLedger returns a view of the same database bound to another ledger,
+	// seeding its default categories the first time it is used.
+	ForLedger(ledger string) (Store, error)
+}
+
+var (
+	_ Store = (*Database)(nil)
+	_ Store = (*MemoryStore)(nil)
+)